@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/jeremyschlatter/gorename-global/rename"
+)
+
+// writeAPIDiff writes the change that the renames in files make to each
+// package's exported API to path, or prints it if path is "-", for
+// -api-diff. Like the apidiff tool's report, it lists each package's
+// incompatible changes, the old names removed, and then its compatible
+// ones, the new names added. With -compat, the old names stay, so the
+// change is compatible.
+func writeAPIDiff(path string, files []*rename.File) error {
+	dirs, byDir := apiRenames(files)
+	var buf bytes.Buffer
+	for i, dir := range dirs {
+		pkg := importPath(dir)
+		if pkg == "" {
+			pkg = dir
+		}
+		if i > 0 {
+			buf.WriteString("\n")
+		}
+		fmt.Fprintf(&buf, "Package %s\n", pkg)
+		rs := byDir[dir]
+		oldTypes := oldTypeNames(rs)
+		var removed, added []string
+		for _, r := range rs {
+			oldRecv := r.Recv
+			if o, ok := oldTypes[r.Recv]; ok {
+				oldRecv = o
+			}
+			removed = append(removed, fmt.Sprintf("- %s: removed, renamed to %s", symbol(oldRecv, r.Old), symbol(r.Recv, r.New)))
+			added = append(added, fmt.Sprintf("- %s: added", symbol(r.Recv, r.New)))
+		}
+		sort.Strings(removed)
+		sort.Strings(added)
+		if len(removed) > 0 && !*compat {
+			buf.WriteString("Incompatible changes:\n")
+			for _, l := range removed {
+				buf.WriteString(l + "\n")
+			}
+		}
+		buf.WriteString("Compatible changes:\n")
+		for _, l := range added {
+			buf.WriteString(l + "\n")
+		}
+	}
+	if len(dirs) == 0 {
+		buf.WriteString("No exported identifiers were renamed.\n")
+	}
+	if path == "-" {
+		_, err := os.Stdout.Write(buf.Bytes())
+		return err
+	}
+	return fsys.WriteFile(path, buf.Bytes(), 0666)
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/jeremyschlatter/gorename-global/rename"
+)
+
+// checkBatch stops the run if -batch is set with flags that need every
+// file at once: -auto, whose conflicts and generated names span packages;
+// a qualified -from, renames limited by kind or package, and
+// -resolve-dot-imports, which look up declarations in other packages; the
+// flags that find the renames to make from the files; and the steps that
+// run on all the renamed files after they are written.
+func checkBatch(renames []renameRule) {
+	if !*batch {
+		return
+	}
+	limited := false
+	for _, r := range renames {
+		limited = limited || r.Kind != "" || r.Package != ""
+	}
+	if auto.set || strings.Contains(*from, ".") || limited || *resolveDots || *enumPrefix != "" || *prefixExported != "" || *unexportName != "" || *exportName != "" ||
+		*deprecateOnly || previewing || reviewing || *verify != "" || *regenerate || *compat || *migration != "" || *apiMapPath != "" || *apiDiff != "" ||
+		*gitConflicts || *renameDirs {
+		usage()
+	}
+	// The semver check needs to know before anything is written whether
+	// any exported identifier will be renamed.
+	if !*breaking && !*check && releasedV1() {
+		exitOnErr([]error{errors.New("-batch writes each batch before renaming the next, so in a module released at v1 or later, whose exported names -breaking must allow to change, it needs -breaking")})
+	}
+}
+
+// renameBatches loads the packages named by args in batches that fit in
+// -max-memory, and renames each with renameFiles and writes it before
+// loading the next, so that its files can be let go.
+func renameBatches(ctx context.Context, args []string, renameFiles func([]*rename.File) error) {
+	n := 0
+	loadBatches(ctx, args, memoryMax, func(files []*rename.File) {
+		if len(files) == 0 {
+			return
+		}
+		n++
+		logger.Debug("batch", "n", n, "files", len(files))
+		if err := renameFiles(files); err != nil {
+			exitOnErr([]error{err})
+		}
+		checkSemver(files)
+		writeFiles(ctx, files, nil)
+		exitIfInterrupted(ctx)
+		findMentions(files)
+	})
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBatch(t *testing.T) {
+	// Each file is big enough, parsed, to fill -max-memory=1 by itself,
+	// so that with -j 1 each package is a batch of its own.
+	filler := strings.Repeat("// Filler, to make the file take up room.\n", 1500)
+	files := map[string]string{
+		"src/ex/a/a.go": "package a\n\n" + filler + "\ntype OldThing struct{}\n\nfunc NewOldThing() *OldThing { return &OldThing{} }\n",
+		"src/ex/b/b.go": "package b\n\nimport \"ex/a\"\n\n" + filler + "\nvar Default = a.NewOldThing()\n\nfunc Use(t *a.OldThing) {}\n",
+		"src/ex/c/c.go": "package c\n\nimport (\n\t\"ex/a\"\n\t\"ex/b\"\n)\n\n" + filler + "\nfunc Run() { b.Use(new(a.OldThing)) }\n",
+	}
+	args := []string{"-j=1", "-max-memory=1", "-from", "OldThing", "-to", "Thing", "./..."}
+
+	var outs [2]string
+	var trees [2]map[string]string
+	for i, batched := range []bool{false, true} {
+		gopath := t.TempDir()
+		writeTree(t, gopath, files)
+		t.Setenv("GOPATH", gopath)
+		args := args
+		if batched {
+			args = append([]string{"-batch", "-log-level=debug"}, args...)
+		}
+		out, errOut, code := runMain(t, filepath.Join(gopath, "src", "ex"), args...)
+		if code != exitChanged {
+			t.Fatalf("%s: exit status %d, want %d:\n%s%s", args, code, exitChanged, out, errOut)
+		}
+		if batched {
+			if n := strings.Count(errOut, "msg=batch"); n != 3 {
+				t.Errorf("%s ran in %d batches, want 3:\n%s", args, n, errOut)
+			}
+		}
+		outs[i], trees[i] = out, readTree(t, gopath)
+	}
+	if outs[1] != outs[0] {
+		t.Errorf("summary with -batch:\n%s\nwithout:\n%s", outs[1], outs[0])
+	}
+	if !reflect.DeepEqual(trees[1], trees[0]) {
+		for name := range trees[0] {
+			if trees[1][name] != trees[0][name] {
+				t.Errorf("%s differs with -batch", name)
+			}
+		}
+	}
+	if got := trees[0]["src/ex/c/c.go"]; !strings.Contains(got, "new(a.Thing)") {
+		t.Errorf("src/ex/c/c.go wasn't renamed:\n%s", got[len(got)-100:])
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"go/build"
+	"os/exec"
+	"strings"
+)
+
+// setupBuild makes build.Default see the files the go command would build:
+// it takes GOOS, GOARCH, and CGO_ENABLED from 'go env', which also knows
+// about settings made with 'go env -w', and the build tags from -tags in
+// GOFLAGS. The other flags in GOFLAGS, such as -mod, reach the go command
+// that go/build runs to find packages in module mode by themselves.
+func setupBuild() {
+	out, err := exec.Command("go", "env", "GOOS", "GOARCH", "CGO_ENABLED", "GOFLAGS").Output()
+	if err != nil {
+		logger.Debug("using the default build environment", "error", err)
+		return
+	}
+	env := strings.Split(strings.TrimRight(string(out), "\n"), "\n")
+	if len(env) != 4 {
+		return
+	}
+	build.Default.GOOS = env[0]
+	build.Default.GOARCH = env[1]
+	build.Default.CgoEnabled = env[2] == "1"
+	for _, f := range strings.Fields(env[3]) {
+		name, value, ok := strings.Cut(strings.TrimLeft(f, "-"), "=")
+		if ok && name == "tags" {
+			build.Default.BuildTags = strings.FieldsFunc(value, func(r rune) bool { return r == ',' })
+		}
+	}
+	logger.Debug("build environment", "goos", build.Default.GOOS, "goarch", build.Default.GOARCH, "tags", build.Default.BuildTags)
+}
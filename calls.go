@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/types"
+	"strings"
+)
+
+// A callPattern is the call that -calls limits renaming to, such as
+// New(_, _): a call of -from whose arguments are each the same expression as
+// the pattern's, or anything for _. A last argument of _... matches any
+// number of them, including none.
+type callPattern struct {
+	args []string // the arguments, as types.ExprString prints them, or "_"
+	more bool     // ends with _...
+}
+
+// parseCallPattern parses the -calls pattern s, which must call the name
+// from.
+func parseCallPattern(s, from string) (*callPattern, error) {
+	e, err := parser.ParseExpr(s)
+	if err != nil {
+		return nil, fmt.Errorf("-calls %q: %v", s, err)
+	}
+	call, ok := e.(*ast.CallExpr)
+	if !ok {
+		return nil, fmt.Errorf("-calls %q is not a call, such as New(_, _)", s)
+	}
+	name := from[strings.LastIndex(from, ".")+1:]
+	if fn := types.ExprString(call.Fun); fn != name && !strings.HasSuffix(fn, "."+name) {
+		return nil, fmt.Errorf("-calls %q does not call %s", s, from)
+	}
+	p := &callPattern{}
+	for i, a := range call.Args {
+		if call.Ellipsis.IsValid() && i == len(call.Args)-1 {
+			if id, ok := a.(*ast.Ident); !ok || id.Name != "_" {
+				return nil, fmt.Errorf("-calls %q may only end with _..., for any more arguments", s)
+			}
+			p.more = true
+			break
+		}
+		p.args = append(p.args, types.ExprString(a))
+	}
+	return p, nil
+}
+
+// filter is the Options.Filter for p: it leaves alone every identifier but
+// those called, possibly through a package name or type arguments, with
+// matching arguments.
+func (p *callPattern) filter(id *ast.Ident, ancestors []ast.Node) bool {
+	var cur ast.Node = id
+	for _, a := range ancestors {
+		switch a := a.(type) {
+		case *ast.SelectorExpr:
+			if a.Sel == cur {
+				cur = a
+				continue
+			}
+		case *ast.IndexExpr:
+			if a.X == cur {
+				cur = a
+				continue
+			}
+		case *ast.IndexListExpr:
+			if a.X == cur {
+				cur = a
+				continue
+			}
+		case *ast.ParenExpr:
+			cur = a
+			continue
+		case *ast.CallExpr:
+			return a.Fun == cur && p.matches(a)
+		}
+		return false
+	}
+	return false
+}
+
+// matches reports whether the arguments of call match p's.
+func (p *callPattern) matches(call *ast.CallExpr) bool {
+	if len(call.Args) < len(p.args) || len(call.Args) > len(p.args) && !p.more || call.Ellipsis.IsValid() && !p.more {
+		return false
+	}
+	for i, want := range p.args {
+		if want != "_" && types.ExprString(call.Args[i]) != want {
+			return false
+		}
+	}
+	return true
+}
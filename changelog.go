@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jeremyschlatter/gorename-global/rename"
+)
+
+// changelogEntry returns the -changelog-entry for the run: the date, the
+// renames made, and the packages they were made in.
+func changelogEntry() []byte {
+	var pairs []pair
+	for _, p := range changeLog.m {
+		pairs = append(pairs, p)
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		a, b := pairs[i], pairs[j]
+		return a.From < b.From || a.From == b.From && a.To < b.To
+	})
+	seen := make(map[string]bool)
+	var pkgs []string
+	for _, r := range results.m {
+		switch r.Status {
+		case statusRenamed, statusWouldRename, statusDeprecated:
+		default:
+			continue
+		}
+		dir := filepath.Dir(r.Path)
+		if seen[dir] {
+			continue
+		}
+		seen[dir] = true
+		p := importPath(dir)
+		if p == "" {
+			p = filepath.ToSlash(dir)
+		}
+		pkgs = append(pkgs, p)
+	}
+	sort.Strings(pkgs)
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "## %s\n\n### Renamed\n\n", time.Now().Format("2006-01-02"))
+	if len(pairs) == 0 {
+		buf.WriteString("- Nothing was renamed.\n")
+	}
+	for _, p := range pairs {
+		fmt.Fprintf(&buf, "- `%s` is now `%s`\n", p.From, p.To)
+	}
+	if len(pkgs) > 0 {
+		buf.WriteString("\nIn ")
+		for i, p := range pkgs {
+			if i > 0 {
+				buf.WriteString(", ")
+			}
+			fmt.Fprintf(&buf, "`%s`", p)
+		}
+		buf.WriteString(".\n")
+	}
+	return buf.Bytes()
+}
+
+// writeChangelog adds the -changelog-entry for the run to the changelog at
+// path, before its latest entry, the first level 2 heading, or at the end
+// if it has none. A missing changelog is created. If path is "-", the
+// entry is printed instead.
+func writeChangelog(path string, _ []*rename.File) error {
+	entry := changelogEntry()
+	if path == "-" {
+		_, err := os.Stdout.Write(entry)
+		return err
+	}
+	old, err := fs.ReadFile(fsys, path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if len(old) == 0 {
+		old = []byte("# Changelog\n")
+	}
+	text := string(old)
+	at := len(text)
+	if strings.HasPrefix(text, "## ") {
+		at = 0
+	} else if i := strings.Index(text, "\n## "); i >= 0 {
+		at = i + 1
+	}
+	var buf bytes.Buffer
+	buf.WriteString(text[:at])
+	if at > 0 && !strings.HasSuffix(text[:at], "\n\n") {
+		if !strings.HasSuffix(text[:at], "\n") {
+			buf.WriteString("\n")
+		}
+		buf.WriteString("\n")
+	}
+	buf.Write(entry)
+	if at < len(text) {
+		buf.WriteString("\n")
+		buf.WriteString(text[at:])
+	}
+	return fsys.WriteFile(path, buf.Bytes(), 0666)
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/jeremyschlatter/gorename-global/rename"
+)
+
+// A checkpointLine is a line of a -resume checkpoint file, which is JSON
+// lines. The first gives the arguments of the run, and each one after it
+// a package directory whose files were all written, with the renames made
+// in it, so that a later run can skip the package but still count them.
+type checkpointLine struct {
+	Args    []string `json:"args,omitempty"`
+	Dir     string   `json:"dir,omitempty"`
+	Changed []pair   `json:"changed,omitempty"`
+}
+
+// progress is the open -resume checkpoint, if any.
+var progress struct {
+	sync.Mutex
+	path string
+	f    *os.File
+	done map[string]bool // by absolute directory
+
+	// files holds the files that writeFiles has in each directory, left
+	// how many of them it has yet to finish with, and failed the directories
+	// with one that couldn't be written.
+	files  map[string][]*rename.File
+	left   map[string]int
+	failed map[string]bool
+}
+
+// openCheckpoint opens the checkpoint at path, creating it if need be, and
+// takes the packages it lists as done, and their renames as made. One made
+// by a run with other arguments is an error, since its packages may not be
+// done as this run would do them.
+func openCheckpoint(path string) error {
+	args := os.Args[1:]
+	progress.path = path
+	progress.done = make(map[string]bool)
+	b, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	sc := bufio.NewScanner(strings.NewReader(string(b)))
+	sc.Buffer(nil, 1<<24)
+	for n := 1; sc.Scan(); n++ {
+		var l checkpointLine
+		if err := json.Unmarshal(sc.Bytes(), &l); err != nil {
+			// The last line may be cut short by a crash.
+			logger.Warn("ignoring unreadable checkpoint line", "path", path, "line", n, "error", err)
+			continue
+		}
+		if n == 1 {
+			if strings.Join(l.Args, "\x00") != strings.Join(args, "\x00") {
+				return fmt.Errorf("%s is the checkpoint of another run, of %q; remove it to start over", path, strings.Join(l.Args, " "))
+			}
+			continue
+		}
+		progress.done[l.Dir] = true
+		for _, p := range l.Changed {
+			k := [2]string{p.From, p.To}
+			c := changeLog.m[k]
+			c.From, c.To = p.From, p.To
+			c.Occurrences += p.Occurrences
+			c.Files += p.Files
+			changeLog.m[k] = c
+		}
+	}
+	if len(progress.done) > 0 {
+		logger.Info("resuming", "checkpoint", path, "done", len(progress.done))
+	}
+	if progress.f, err = os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666); err != nil {
+		return err
+	}
+	if len(b) == 0 {
+		return writeCheckpoint(checkpointLine{Args: args})
+	}
+	return nil
+}
+
+// resumed reports whether the file at path is in a package that the
+// checkpoint lists as done.
+func resumed(path string) bool {
+	if progress.f == nil {
+		return false
+	}
+	dir, err := filepath.Abs(filepath.Dir(path))
+	return err == nil && progress.done[dir]
+}
+
+// startCheckpoint notes the files that writeFiles is to write, by
+// directory, so that each package can be checkpointed once they all are.
+func startCheckpoint(files []*rename.File) {
+	if progress.f == nil {
+		return
+	}
+	if progress.files == nil {
+		progress.files = make(map[string][]*rename.File)
+		progress.left = make(map[string]int)
+		progress.failed = make(map[string]bool)
+	}
+	for _, f := range files {
+		if dir, err := filepath.Abs(filepath.Dir(f.Path)); err == nil {
+			progress.files[dir] = append(progress.files[dir], f)
+			progress.left[dir]++
+		}
+	}
+}
+
+// checkpointFile notes that writeFiles is done with f, as ok says, and
+// checkpoints its package if that was the last of its files and none
+// failed.
+func checkpointFile(f *rename.File, ok bool) {
+	if progress.f == nil {
+		return
+	}
+	dir, err := filepath.Abs(filepath.Dir(f.Path))
+	if err != nil {
+		return
+	}
+	progress.Lock()
+	defer progress.Unlock()
+	if !ok {
+		progress.failed[dir] = true
+	}
+	if progress.left[dir]--; progress.left[dir] > 0 {
+		return
+	}
+	fs := progress.files[dir]
+	delete(progress.files, dir) // letting a -batch go
+	if progress.failed[dir] {
+		return
+	}
+	l := checkpointLine{Dir: dir}
+	changed := make(map[[2]string]pair)
+	for _, f := range fs {
+		for old, n := range f.Renames() {
+			k := [2]string{old, n}
+			p := changed[k]
+			p.From, p.To = old, n
+			p.Occurrences += f.Occurrences()[old]
+			p.Files++
+			changed[k] = p
+		}
+	}
+	for _, p := range changed {
+		l.Changed = append(l.Changed, p)
+	}
+	sort.Slice(l.Changed, func(i, j int) bool {
+		a, b := l.Changed[i], l.Changed[j]
+		return a.From < b.From || a.From == b.From && a.To < b.To
+	})
+	if err := writeCheckpoint(l); err != nil {
+		logger.Warn("checkpointing failed", "path", progress.path, "error", err)
+	}
+}
+
+// writeCheckpoint appends l to the checkpoint.
+func writeCheckpoint(l checkpointLine) error {
+	b, err := json.Marshal(l)
+	if err != nil {
+		return err
+	}
+	_, err = progress.f.Write(append(b, '\n'))
+	return err
+}
+
+// closeCheckpoint closes the checkpoint, and removes it if the run
+// succeeded, leaving nothing to resume.
+func closeCheckpoint(failed bool) {
+	if progress.f == nil {
+		return
+	}
+	progress.f.Close()
+	if !failed {
+		os.Remove(progress.path)
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestResume(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"a/a.go": "package a\n\nfunc Old() {}\n",
+		"b/b.go": "package b\n\nfunc Old() {}\n",
+	})
+	// A run that was interrupted after finishing package a, whose file has
+	// since been put back as it was, to show that it isn't done again.
+	args := []string{"-resume=checkpoint.json", "-from", "Old", "-to", "New", "./..."}
+	var ckpt []byte
+	for _, l := range []checkpointLine{
+		{Args: args},
+		{Dir: filepath.Join(dir, "a"), Changed: []pair{{From: "Old", To: "New", Occurrences: 1, Files: 1}}},
+	} {
+		b, err := json.Marshal(l)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ckpt = append(append(ckpt, b...), '\n')
+	}
+	path := filepath.Join(dir, "checkpoint.json")
+	if err := os.WriteFile(path, ckpt, 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	out, errOut, code := runMain(t, dir, args...)
+	if code != exitChanged {
+		t.Fatalf("exit status %d, want %d:\n%s%s", code, exitChanged, out, errOut)
+	}
+	if !strings.Contains(out, "Old -> New (2 occurrences in 2 files)") {
+		t.Errorf("the summary doesn't count the renames made before resuming:\n%s", out)
+	}
+	got := readTree(t, dir)
+	want := map[string]string{
+		"a/a.go": "package a\n\nfunc Old() {}\n",
+		"b/b.go": "package b\n\nfunc New() {}\n",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("files after resuming, with the checkpoint removed:\n%q\nwant:\n%q", got, want)
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+)
+
+// A theme is the SGR parameters, such as "1;31" for bold red, that color
+// each part of the output. An empty one leaves that part plain, so the zero
+// theme is no color at all.
+type theme struct {
+	removed string // lines and names taken out
+	added   string // lines and names put in
+	heading string // of summary sections and files
+	failure string // errors
+	warning string // warnings
+}
+
+// defaultTheme is changed by GORENAME_GLOBAL_COLORS, a colon-separated
+// list of part=parameters settings, such as "removed=35:heading=4".
+var defaultTheme = theme{removed: "31", added: "32", heading: "1", failure: "1;31", warning: "33"}
+
+// The themes for standard output, standard error, and the terminal the
+// review command opens, set by setupColor.
+var outTheme, errTheme, ttyTheme theme
+
+// setupColor sets the themes as -color says: always, never, or auto, which
+// colors a stream only if it is a terminal and NO_COLOR is not set.
+func setupColor(mode string) {
+	if mode != "auto" && mode != "always" && mode != "never" {
+		usage()
+	}
+	t, err := parseTheme(defaultTheme, os.Getenv("GORENAME_GLOBAL_COLORS"))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "GORENAME_GLOBAL_COLORS:", err)
+		os.Exit(exitUsage)
+	}
+	outTheme, errTheme, ttyTheme = theme{}, theme{}, theme{}
+	if colored(mode, os.Stdout) {
+		outTheme = t
+	}
+	if colored(mode, os.Stderr) {
+		errTheme = t
+	}
+	if colored(mode, nil) {
+		ttyTheme = t
+	}
+}
+
+// colored reports whether -color=mode colors f, or, if f is nil, a
+// terminal.
+func colored(mode string, f *os.File) bool {
+	switch mode {
+	case "always":
+		return true
+	case "never":
+		return false
+	}
+	if os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	if f == nil {
+		return true
+	}
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// parseTheme returns t with the settings in s, as in GORENAME_GLOBAL_COLORS,
+// applied.
+func parseTheme(t theme, s string) (theme, error) {
+	for _, kv := range strings.Split(s, ":") {
+		if kv == "" {
+			continue
+		}
+		part, sgr, ok := strings.Cut(kv, "=")
+		if !ok || strings.Trim(sgr, "0123456789;") != "" {
+			return t, fmt.Errorf("%q is not of the form part=parameters, such as removed=31", kv)
+		}
+		switch part {
+		case "removed":
+			t.removed = sgr
+		case "added":
+			t.added = sgr
+		case "heading":
+			t.heading = sgr
+		case "failure":
+			t.failure = sgr
+		case "warning":
+			t.warning = sgr
+		default:
+			return t, fmt.Errorf("%q is not removed, added, heading, failure, or warning", part)
+		}
+	}
+	return t, nil
+}
+
+// paint returns s in the color sgr, or as it is if sgr is empty.
+func paint(sgr, s string) string {
+	if sgr == "" {
+		return s
+	}
+	return "\x1b[" + sgr + "m" + s + "\x1b[0m"
+}
+
+// A colorHandler colors the text diagnostics of its Handler by level, with
+// errTheme.
+type colorHandler struct {
+	slog.Handler
+}
+
+// colorMu keeps other diagnostics from being written between the color of
+// one and its reset.
+var colorMu sync.Mutex
+
+func (h colorHandler) Handle(ctx context.Context, r slog.Record) error {
+	sgr := ""
+	switch {
+	case r.Level >= slog.LevelError:
+		sgr = errTheme.failure
+	case r.Level >= slog.LevelWarn:
+		sgr = errTheme.warning
+	}
+	if sgr == "" {
+		return h.Handler.Handle(ctx, r)
+	}
+	colorMu.Lock()
+	defer colorMu.Unlock()
+	fmt.Fprint(os.Stderr, "\x1b["+sgr+"m")
+	defer fmt.Fprint(os.Stderr, "\x1b[0m")
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h colorHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return colorHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h colorHandler) WithGroup(name string) slog.Handler {
+	return colorHandler{h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,381 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/jeremyschlatter/gorename-global/rename"
+)
+
+// A command is one of the subcommands that split up the top-level flags,
+// each taking only those that apply to it, besides the shared ones that
+// control how files are found, written, and reported. Its flags set the
+// top-level ones, so that -record, -manifest, and -pr-repo pass them on as
+// for a plain run.
+type command struct {
+	name string
+	args string // after the flags, in the usage line
+	doc  string
+
+	flags   []string          // the top-level flags it takes, besides the shared ones
+	aliases []alias           // flags of its own, standing for top-level ones
+	implies map[string]string // the top-level flags it sets, and their values
+
+	// run is given the arguments after the flags.
+	run func(args []string)
+
+	// local, if set, adds flags of its own that set no top-level flag,
+	// such as the receivers command's -type, to fs, and returns what to
+	// run in place of run.
+	local func(fs *flag.FlagSet) func(args []string)
+}
+
+// An alias is a command's name for a top-level flag, which always takes a
+// value, as -rules=lint does for -auto=lint.
+type alias struct {
+	name, flag, usage string
+}
+
+// The flags that say what to rename.
+var (
+	ruleFlags    = []string{"from", "to", "to-template", "e", "map", "word", "plurals", "plural-overrides", "companions", "ignore-case", "matcher-cmd", "enum-prefix", "prefix-exported", "unexport", "export", "merge", "calls", "positions"}
+	allRules     = append([]string{"auto", "exported-only", "spelling", "hungarian-prefixes", "lint-config"}, ruleFlags...)
+	pkgsSynopsis = "[pkg... | file.go...]"
+)
+
+var commands []*command
+
+// The commands are set up by init, since running them refers back to them
+// for usage.
+func init() {
+	commands = []*command{
+		{
+			name:  "rename",
+			args:  pkgsSynopsis,
+			doc:   "Rename the identifiers that -from and -to, -to-template, -e, -map, -enum-prefix, -prefix-exported, -unexport, -export, -positions, or -matcher-cmd say to.",
+			flags: ruleFlags,
+			run:   func(args []string) { renameMain(args, nil) },
+		},
+		{
+			name:    "auto",
+			args:    pkgsSynopsis,
+			doc:     "Rename the identifiers that the lint rules, or those given by -rules, flag.",
+			flags:   []string{"exported-only", "matcher-cmd", "spelling", "hungarian-prefixes", "lint-config"},
+			aliases: []alias{{"rules", "auto", "the comma-separated `rules` to apply, of " + strings.Join(rename.AllRules, ", ") + ", lint, or all, rather than the lint rules"}},
+			implies: map[string]string{"auto": "true"},
+			run:     func(args []string) { renameMain(args, nil) },
+		},
+		{
+			name:    "check",
+			args:    pkgsSynopsis,
+			doc:     "Report what rename or auto would change, without changing any files.",
+			flags:   allRules,
+			implies: map[string]string{"check": "true"},
+			run:     func(args []string) { renameMain(args, nil) },
+		},
+		{
+			name:    "list",
+			args:    pkgsSynopsis,
+			doc:     "List each identifier that rename or auto would change, with its position, without changing any files.",
+			flags:   allRules,
+			implies: map[string]string{"check": "true"},
+			run: func(args []string) {
+				listing = true
+				renameMain(args, nil)
+			},
+		},
+		{
+			name: "undo",
+			args: "summary.json " + pkgsSynopsis,
+			doc:  "Rename back the identifiers listed in a -report=json summary, in the packages named, or ./... by default.",
+			run: func(args []string) {
+				if len(args) < 1 {
+					usage()
+				}
+				renames, err := readUndo(args[0])
+				if err != nil {
+					fmt.Fprintln(os.Stderr, err)
+					os.Exit(exitUsage)
+				}
+				pkgs := args[1:]
+				if len(pkgs) == 0 {
+					pkgs = []string{"./..."}
+				}
+				renameMain(pkgs, renames)
+			},
+		},
+		{
+			name: "apply",
+			args: "script " + pkgsSynopsis,
+			doc:  "Run the rename recorded in a script by -record, with its arguments unless others are given.",
+			run: func(args []string) {
+				if len(args) < 1 {
+					usage()
+				}
+				flag.Set("play", args[0])
+				renameMain(args[1:], nil)
+			},
+		},
+		{
+			name:  "suggest",
+			args:  pkgsSynopsis,
+			doc:   "Print renames of poorly named identifiers that the lint rules miss, such as misspelled or Hungarian-prefixed names, as a -map file to review and rename with.",
+			flags: []string{"hungarian-prefixes"},
+			run:   suggestMain,
+		},
+		{
+			name:  "preview",
+			args:  pkgsSynopsis,
+			doc:   "Serve each changed file's diff on -http, for picking the files to write, and write those picked.",
+			flags: append([]string{"http"}, allRules...),
+			run: func(args []string) {
+				previewing = true
+				renameMain(args, nil)
+			},
+		},
+		{
+			name:  "review",
+			args:  pkgsSynopsis,
+			doc:   "Show the changes full screen in the terminal, for picking the hunks to write, and write those chosen.",
+			flags: allRules,
+			run: func(args []string) {
+				reviewing = true
+				renameMain(args, nil)
+			},
+		},
+		{
+			name:  "receivers",
+			args:  "-type <type> -name <name> " + pkgsSynopsis,
+			doc:   "Give the receivers of a type's methods a single name.",
+			local: receiversCommand,
+		},
+		{
+			name:  "results",
+			args:  "-func <func> -from <name> -to <name> " + pkgsSynopsis,
+			doc:   "Rename a named result of one function.",
+			local: resultsCommand,
+		},
+		{
+			name:  "fields",
+			args:  "-type <type> -from <field> -to <field> " + pkgsSynopsis,
+			doc:   "Rename a field of one struct type, leaving same-named fields of other types alone.",
+			local: fieldsCommand,
+		},
+		{
+			name:  "methods",
+			args:  "-type <type> -from <method> -to <method> " + pkgsSynopsis,
+			doc:   "Rename a method of one type, leaving same-named methods of other types alone.",
+			local: methodsCommand,
+		},
+		{
+			name:  "labels",
+			args:  "[-func <func>] -from <label> -to <label> " + pkgsSynopsis,
+			doc:   "Rename a statement label, in one function or all of them.",
+			local: labelsCommand,
+		},
+		{
+			name:  "replay",
+			args:  "[-root <dir>] changelog.json " + pkgsSynopsis,
+			doc:   "Apply the renames listed in a -report=json summary to another checkout.",
+			local: replayCommand,
+		},
+		{
+			name: "move",
+			args: "pkg.Name otherpkg " + pkgsSynopsis,
+			doc:  "Move a top-level declaration to another package of the module, and update the references to it.",
+			run:  moveMain,
+		},
+	}
+}
+
+// current is the command being run, whose usage usage prints.
+var current *command
+
+// lookupCommand returns the command with the given name, or nil.
+func lookupCommand(name string) *command {
+	for _, c := range commands {
+		if c.name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// sharedFlag reports whether the top-level flag name is one that every
+// command takes: one that no command takes alone, sets, or stands in for.
+func sharedFlag(name string) bool {
+	for _, c := range commands {
+		for _, f := range c.flags {
+			if f == name {
+				return false
+			}
+		}
+		for _, a := range c.aliases {
+			if a.flag == name {
+				return false
+			}
+		}
+		if _, ok := c.implies[name]; ok {
+			return false
+		}
+	}
+	return true
+}
+
+// visitFlags calls fn with the name and usage of each of c's flags, the
+// top-level flag it sets, and whether it is one of c's own rather than a
+// shared one.
+func (c *command) visitFlags(fn func(name, usage string, f *flag.Flag, own bool)) {
+	for _, name := range c.flags {
+		f := flag.Lookup(name)
+		fn(name, f.Usage, f, true)
+	}
+	for _, a := range c.aliases {
+		fn(a.name, a.usage, flag.Lookup(a.flag), true)
+	}
+	flag.VisitAll(func(f *flag.Flag) {
+		if sharedFlag(f.Name) {
+			fn(f.Name, f.Usage, f, false)
+		}
+	})
+}
+
+// main parses args as c's flags and arguments, and runs c.
+func (c *command) main(args []string) {
+	current = c
+	fs := flag.NewFlagSet(c.name, flag.ExitOnError)
+	fs.Usage = c.printUsage
+	c.visitFlags(func(name, usage string, f *flag.Flag, _ bool) {
+		fs.Var(topLevelFlag{f, name == f.Name}, name, usage)
+	})
+	run := c.run
+	if c.local != nil {
+		run = c.local(fs)
+	}
+	fs.Parse(args)
+	set := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	for name, value := range c.implies {
+		if !set[name] {
+			flag.Set(name, value)
+		}
+	}
+	run(fs.Args())
+}
+
+// printUsage prints c's usage line, what it does, and its flags.
+func (c *command) printUsage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s %s [flags] %s\n\n%s\n", os.Args[0], c.name, c.args, c.doc)
+	// The defaults are printed from the top-level flags' own values.
+	own, shared := flag.NewFlagSet(c.name, flag.ContinueOnError), flag.NewFlagSet(c.name, flag.ContinueOnError)
+	c.visitFlags(func(name, usage string, f *flag.Flag, isOwn bool) {
+		if isOwn {
+			own.Var(f.Value, name, usage)
+		} else {
+			shared.Var(f.Value, name, usage)
+		}
+	})
+	if c.local != nil {
+		c.local(own)
+	}
+	own.SetOutput(os.Stderr)
+	shared.SetOutput(os.Stderr)
+	if len(c.flags) > 0 || len(c.aliases) > 0 || c.local != nil {
+		fmt.Fprintln(os.Stderr, "\nFlags:")
+		own.PrintDefaults()
+	}
+	fmt.Fprintln(os.Stderr, "\nFlags for finding, writing, and reporting files, shared by every command:")
+	shared.PrintDefaults()
+}
+
+// printCommands prints the commands and what each does, for usage.
+func printCommands() {
+	fmt.Fprintf(os.Stderr, "\nCommands, each with its own -help:\n")
+	width := 0
+	for _, c := range commands {
+		width = max(width, len(c.name))
+	}
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-*s %s\n", width, c.name, c.doc)
+	}
+}
+
+// A topLevelFlag is a command's flag that sets the top-level flag f, so
+// that flag.Visit sees it. Under f's own name, it is a boolean flag if f
+// is.
+type topLevelFlag struct {
+	f        *flag.Flag
+	sameName bool
+}
+
+func (t topLevelFlag) String() string {
+	if t.f == nil {
+		return ""
+	}
+	return t.f.Value.String()
+}
+
+func (t topLevelFlag) Set(s string) error {
+	return flag.Set(t.f.Name, s)
+}
+
+func (t topLevelFlag) IsBoolFlag() bool {
+	b, ok := t.f.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag() && t.sameName
+}
+
+// readUndo reads the renames in a -report=json summary, turned around.
+func readUndo(path string) ([]renameRule, error) {
+	renames, err := readChangeLog(path)
+	if err != nil {
+		return nil, err
+	}
+	var olds []string
+	for old := range renames {
+		olds = append(olds, old)
+	}
+	sort.Strings(olds)
+	back := make(map[string]string)
+	for _, old := range olds {
+		n := renames[old]
+		if o, ok := back[n]; ok {
+			return nil, fmt.Errorf("%s: both %s and %s were renamed to %s, so which to rename it back to is unclear", path, o, old, n)
+		}
+		back[n] = old
+	}
+	var rs []renameRule
+	for n, old := range back {
+		rs = append(rs, renameRule{From: n, To: old})
+	}
+	sort.Slice(rs, func(i, j int) bool { return rs[i].From < rs[j].From })
+	return rs, nil
+}
+
+// listing is set by the list command, which prints each change rather
+// than the summary.
+var listing bool
+
+// sites holds the identifiers renamed, for the list command.
+var sites = struct {
+	sync.Mutex
+	s []rename.Change
+}{}
+
+// listSite is the Options.OnChange of the list command.
+func listSite(c rename.Change) {
+	sites.Lock()
+	sites.s = append(sites.s, c)
+	sites.Unlock()
+}
+
+// printSites prints the identifiers renamed, by position, for the list
+// command.
+func printSites(cs []rename.Change) {
+	for _, c := range cs {
+		fmt.Printf("%s: %s -> %s\n", c.Pos, c.Old, c.New)
+	}
+}
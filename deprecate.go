@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"sort"
+
+	"github.com/jeremyschlatter/gorename-global/rename"
+)
+
+// deprecate writes, for -deprecate-only, the new names of the exported
+// declarations that Rename renamed in files alongside the old ones, which
+// are marked deprecated, rather than the renamed files, and records the
+// results.
+func deprecate(ctx context.Context, files []*rename.File) {
+	exitIfInterrupted(ctx)
+	srcs, err := rename.Deprecate(files)
+	if err != nil {
+		exitOnErr([]error{err})
+	}
+	var paths []string
+	for path := range srcs {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	declared := make(map[string]bool)
+	for _, f := range files {
+		declared[f.Path] = true
+		if _, ok := srcs[f.Path]; !ok && !f.Broken() {
+			record(f.Path, statusUnchanged, nil)
+		}
+		for _, r := range f.APIRenames() {
+			if _, ok := srcs[f.Path]; !ok {
+				continue
+			}
+			p := changeLog.m[[2]string{r.Old, r.New}]
+			p.From, p.To = r.Old, r.New
+			p.Occurrences++
+			p.Files++
+			changeLog.m[[2]string{r.Old, r.New}] = p
+		}
+	}
+	for _, path := range paths {
+		status := statusCreated
+		if declared[path] {
+			status = statusDeprecated
+		}
+		switch {
+		case *check:
+			record(path, statusWouldRename, nil)
+		case ctx.Err() != nil:
+			record(path, statusInterrupted, stopReason(ctx))
+		default:
+			if err := writeOutput(path, srcs[path]); err != nil {
+				record(path, statusWriteErr, err)
+				continue
+			}
+			record(path, status, nil)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import "strings"
+
+// A hunk is a run of lines, start through end-1 counting from 0, that is
+// old in the original source and new in the renamed one.
+type hunk struct {
+	start, end int
+	old, new   []string // with their line endings
+}
+
+// lineHunks returns the hunks that turn old into new. Renames rarely change
+// the number of lines, so when old and new have the same number, each run
+// of changed lines is a hunk. Otherwise the lines between the first and the
+// last difference are one hunk of at least one line.
+func lineHunks(old, new []byte) []hunk {
+	ol := strings.SplitAfter(string(old), "\n")
+	nl := strings.SplitAfter(string(new), "\n")
+	var hs []hunk
+	if len(ol) == len(nl) {
+		for i := 0; i < len(ol); i++ {
+			if ol[i] == nl[i] {
+				continue
+			}
+			j := i
+			for j < len(ol) && ol[j] != nl[j] {
+				j++
+			}
+			hs = append(hs, hunk{i, j, ol[i:j], nl[i:j]})
+			i = j
+		}
+		return hs
+	}
+	pre := 0
+	for pre < len(ol) && pre < len(nl) && ol[pre] == nl[pre] {
+		pre++
+	}
+	suf := 0
+	for suf < len(ol)-pre && suf < len(nl)-pre && ol[len(ol)-1-suf] == nl[len(nl)-1-suf] {
+		suf++
+	}
+	if pre == len(ol)-suf {
+		if pre > 0 {
+			pre--
+		} else {
+			suf--
+		}
+	}
+	return []hunk{{pre, len(ol) - suf, ol[pre : len(ol)-suf], nl[pre : len(nl)-suf]}}
+}
+
+// applyHunks returns old with those of the hunks from old to new that keep
+// says to apply, by index, applied.
+func applyHunks(old, new []byte, keep []bool) []byte {
+	ol := strings.SplitAfter(string(old), "\n")
+	var b strings.Builder
+	next := 0
+	for i, h := range lineHunks(old, new) {
+		if i >= len(keep) || !keep[i] {
+			continue
+		}
+		b.WriteString(strings.Join(ol[next:h.start], ""))
+		b.WriteString(strings.Join(h.new, ""))
+		next = h.end
+	}
+	b.WriteString(strings.Join(ol[next:], ""))
+	return []byte(b.String())
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jeremyschlatter/gorename-global/rename"
+)
+
+// A dirMove is a package directory moved by -rename-dirs.
+type dirMove struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// movedDirs holds the directories moved by -rename-dirs.
+var movedDirs []dirMove
+
+// renamePackageDirs moves each directory named after its package, as
+// example.com/foo is for package foo, to the package's new name, for the
+// packages of files whose package clause was renamed, and rewrites the
+// import paths of the moved packages, and of those under them, in the
+// module in the current directory. Directories not named after their
+// packages are left where they are.
+func renamePackageDirs(files []*rename.File) {
+	names := make(map[string]string) // the new package names, by directory
+	for _, f := range files {
+		old, n := f.PackageNames()
+		dir := filepath.Dir(f.Path)
+		if old == n || strings.HasSuffix(old, "_test") || filepath.Base(dir) != old {
+			continue
+		}
+		if r := results.m[f.Path]; r.Status != statusRenamed {
+			continue // not written
+		}
+		names[dir] = n
+	}
+	if len(names) == 0 {
+		return
+	}
+	root, err := moduleRoot()
+	if err != nil {
+		exitOnErr([]error{err})
+	}
+	modPath := modulePath(root)
+
+	var dirs []string
+	for dir := range names {
+		dirs = append(dirs, dir)
+	}
+	// Deeper directories first, so that a parent's move doesn't take its
+	// children out from under them.
+	sort.Slice(dirs, func(i, j int) bool { return len(dirs[i]) > len(dirs[j]) })
+	var paths [][2]string // old and new import paths, in the order moved
+	for _, dir := range dirs {
+		to := filepath.Join(filepath.Dir(dir), names[dir])
+		real, err := filepath.EvalSymlinks(dir)
+		if err != nil {
+			record(dir, statusWriteErr, err)
+			continue
+		}
+		oldPath := modImportPath(root, modPath, real)
+		if _, err := os.Stat(to); err == nil {
+			record(dir, statusWriteErr, fmt.Errorf("can't move to %s, which already exists", to))
+			continue
+		}
+		if err := os.Rename(dir, to); err != nil {
+			record(dir, statusWriteErr, err)
+			continue
+		}
+		movedDirs = append(movedDirs, dirMove{From: dir, To: to})
+		if oldPath != "" {
+			paths = append(paths, [2]string{oldPath, path.Join(path.Dir(oldPath), names[dir])})
+		}
+	}
+	if len(paths) == 0 {
+		return
+	}
+	err = walkModule(root, func(name string) error {
+		src, err := os.ReadFile(name)
+		if err != nil {
+			record(name, statusReadErr, err)
+			return nil
+		}
+		out, changed := rewriteImports(src, paths)
+		if !changed {
+			return nil
+		}
+		st, err := os.Stat(name)
+		if err != nil {
+			record(name, statusWriteErr, err)
+			return nil
+		}
+		if err := fsys.WriteFile(name, out, st.Mode().Perm()); err != nil {
+			record(name, statusWriteErr, err)
+			return nil
+		}
+		record(name, statusRenamed, nil)
+		return nil
+	})
+	if err != nil {
+		exitOnErr([]error{err})
+	}
+}
+
+// rewriteImports returns src with each import of a package moved as paths
+// says changed to its new path, and whether any was.
+func rewriteImports(src []byte, paths [][2]string) ([]byte, bool) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", src, parser.ImportsOnly)
+	if err != nil {
+		return src, false
+	}
+	var buf bytes.Buffer
+	last := 0
+	for _, imp := range f.Imports {
+		p, err := strconv.Unquote(imp.Path.Value)
+		if err != nil {
+			continue
+		}
+		n, ok := movedPath(p, paths)
+		if !ok {
+			continue
+		}
+		off := fset.Position(imp.Path.Pos()).Offset
+		buf.Write(src[last:off])
+		buf.WriteString(strconv.Quote(n))
+		last = off + len(imp.Path.Value)
+	}
+	if last == 0 {
+		return src, false
+	}
+	buf.Write(src[last:])
+	return buf.Bytes(), true
+}
+
+// movedPath returns the import path p moves to, as the package it names or
+// those it is under move, in turn, as paths says, and whether it moves.
+func movedPath(p string, paths [][2]string) (string, bool) {
+	moved := false
+	for _, m := range paths {
+		if old, n := m[0], m[1]; p == old || strings.HasPrefix(p, old+"/") {
+			p = n + p[len(old):]
+			moved = true
+		}
+	}
+	return p, moved
+}
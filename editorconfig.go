@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/jeremyschlatter/gorename-global/rename"
+)
+
+// editorConfigStyle returns the style that the .editorconfig files above
+// the Go file at p give it, starting from def. It understands indent_style,
+// indent_size and tab_width, and the section patterns likely to apply to
+// Go files: *, *.go, {a,b} alternatives, and paths from the config's
+// directory.
+func editorConfigStyle(p string, def rename.Style) rename.Style {
+	abs, err := filepath.Abs(p)
+	if err != nil {
+		return def
+	}
+	// Nearer files take precedence, so apply them last.
+	var configs []string
+	for dir := filepath.Dir(abs); ; dir = filepath.Dir(dir) {
+		cfg := filepath.Join(dir, ".editorconfig")
+		if root, err := editorConfigIsRoot(cfg); err == nil {
+			configs = append(configs, cfg)
+			if root {
+				break
+			}
+		}
+		if filepath.Dir(dir) == dir {
+			break
+		}
+	}
+	s := def
+	for i := len(configs) - 1; i >= 0; i-- {
+		rel, err := filepath.Rel(filepath.Dir(configs[i]), abs)
+		if err != nil {
+			continue
+		}
+		applyEditorConfig(configs[i], filepath.ToSlash(rel), &s)
+	}
+	return s
+}
+
+// editorConfigIsRoot reports whether the .editorconfig at cfg says
+// root = true, or returns an error if it can't be read.
+func editorConfigIsRoot(cfg string) (bool, error) {
+	root := false
+	err := readEditorConfig(cfg, func(section, key, value string) {
+		if section == "" && key == "root" {
+			root = value == "true"
+		}
+	})
+	return root, err
+}
+
+// applyEditorConfig sets s from the sections of cfg that match rel, the
+// slash-separated path of a file relative to cfg's directory.
+func applyEditorConfig(cfg, rel string, s *rename.Style) {
+	indentSize := ""
+	readEditorConfig(cfg, func(section, key, value string) {
+		if section == "" || !editorConfigMatch(section, rel) {
+			return
+		}
+		switch key {
+		case "indent_style":
+			s.IndentSpaces = value == "space"
+		case "indent_size":
+			indentSize = value
+		case "tab_width":
+			if n, err := strconv.Atoi(value); err == nil && n > 0 {
+				s.TabWidth = n
+			}
+		}
+	})
+	// indent_size = tab means tab_width, which is already set.
+	if n, err := strconv.Atoi(indentSize); err == nil && n > 0 {
+		s.TabWidth = n
+	}
+}
+
+// readEditorConfig calls fn with each key and value in the .editorconfig
+// at cfg, along with the pattern of the section it is in, or "" before
+// the first section. Keys and values are lower-cased.
+func readEditorConfig(cfg string, fn func(section, key, value string)) error {
+	file, err := os.Open(cfg)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	section := ""
+	sc := bufio.NewScanner(file)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		switch {
+		case line == "", line[0] == '#', line[0] == ';':
+		case line[0] == '[' && line[len(line)-1] == ']':
+			section = line[1 : len(line)-1]
+		default:
+			if i := strings.IndexAny(line, "=:"); i >= 0 {
+				key := strings.ToLower(strings.TrimSpace(line[:i]))
+				value := strings.ToLower(strings.TrimSpace(line[i+1:]))
+				fn(section, key, value)
+			}
+		}
+	}
+	return sc.Err()
+}
+
+// editorConfigMatch reports whether the section pattern matches rel.
+// A pattern without a slash matches the file name in any directory.
+func editorConfigMatch(pattern, rel string) bool {
+	for _, alt := range expandBraces(pattern) {
+		alt = strings.ReplaceAll(alt, "**", "*")
+		name := rel
+		if strings.Contains(alt, "/") {
+			alt = strings.TrimPrefix(alt, "/")
+		} else {
+			name = path.Base(rel)
+		}
+		if ok, _ := path.Match(alt, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// expandBraces expands the first {a,b} in pattern, recursively.
+func expandBraces(pattern string) []string {
+	i := strings.Index(pattern, "{")
+	j := strings.Index(pattern, "}")
+	if i < 0 || j < i {
+		return []string{pattern}
+	}
+	var out []string
+	for _, alt := range strings.Split(pattern[i+1:j], ",") {
+		out = append(out, expandBraces(pattern[:i]+alt+pattern[j+1:])...)
+	}
+	return out
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/jeremyschlatter/gorename-global/rename"
+)
+
+// enumRenames returns the renames that -enum-prefix makes: each constant
+// in a const block of files whose name starts with the prefix, such as
+// ColorRed for Color, to the same name starting with -to, HueRed. It is an
+// error if no const block declares any.
+func enumRenames(files []*rename.File) ([]renameRule, error) {
+	seen := make(map[renameRule]bool)
+	var rs []renameRule
+	for _, f := range files {
+		pkg := importPath(filepath.Dir(f.Path))
+		for _, name := range f.ConstFamily(*enumPrefix) {
+			r := renameRule{From: name, To: *to + strings.TrimPrefix(name, *enumPrefix), Kind: "const", Package: pkg}
+			if !seen[r] {
+				seen[r] = true
+				rs = append(rs, r)
+			}
+		}
+	}
+	if len(rs) == 0 {
+		return nil, fmt.Errorf("no const block declares constants starting with %s", *enumPrefix)
+	}
+	return rs, nil
+}
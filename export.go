@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/jeremyschlatter/gorename-global/rename"
+)
+
+// exportRenames returns the renames that -export makes, of the name to the
+// name exported, as initialisms are: httpClient becomes HTTPClient. It is
+// an error if a package that declares the name also declares the new one
+// at the top level, since the two would collide.
+func exportRenames(files []*rename.File) ([]renameRule, error) {
+	to := rename.Export(*exportName)
+	rs, err := topLevelRenames(files, *exportName, to)
+	if err != nil {
+		return nil, err
+	}
+	declares := make(map[string]bool) // by directory
+	for _, f := range files {
+		if _, ok := f.Declared()[*exportName]; ok {
+			declares[filepath.Dir(f.Path)] = true
+		}
+	}
+	for _, f := range files {
+		if !declares[filepath.Dir(f.Path)] {
+			continue
+		}
+		if kind, ok := f.Declared()[to]; ok {
+			return nil, fmt.Errorf("%s can't be exported as %s, which %s already declares as a %s", *exportName, to, f.Path, kind)
+		}
+	}
+	return rs, nil
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/jeremyschlatter/gorename-global/rename"
+)
+
+// fsys is where source files are read from and written to, and where the
+// command's own files, such as the -manifest, are written.
+var fsys rename.WriteFS = osFS{}
+
+// osFS is the disk. Unlike os.DirFS, which is rooted at a directory, it
+// takes operating system paths, relative to the current directory or
+// absolute, as found on the command line and by go/build.
+type osFS struct{}
+
+func (osFS) Open(name string) (fs.File, error)          { return os.Open(name) }
+func (osFS) Stat(name string) (fs.FileInfo, error)      { return os.Stat(name) }
+func (osFS) ReadFile(name string) ([]byte, error)       { return os.ReadFile(name) }
+func (osFS) ReadDir(name string) ([]fs.DirEntry, error) { return os.ReadDir(name) }
+
+// WriteFile replaces the file at name with data. It writes to a temporary
+// file first and renames it into place, so that the file is never left
+// half written, and keeps its permissions. A new file is written directly,
+// with perm. A symlink is written through: the file it points to is
+// replaced, and the link left as it is.
+func (osFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	fi, err := os.Stat(name)
+	if err != nil {
+		return os.WriteFile(name, data, perm)
+	}
+	if name, err = filepath.EvalSymlinks(name); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(name), "."+filepath.Base(name)+".*")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), fi.Mode().Perm())
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), name)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOSFSWriteFileSymlink(t *testing.T) {
+	dir := t.TempDir()
+	real := filepath.Join(dir, "shared", "real.go.txt")
+	link := filepath.Join(dir, "pkg", "old.go")
+	for _, d := range []string{filepath.Dir(real), filepath.Dir(link)} {
+		if err := os.Mkdir(d, 0777); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(real, []byte("package pkg\n\nfunc Old() {}\n"), 0640); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join("..", "shared", "real.go.txt"), link); err != nil {
+		t.Skip(err)
+	}
+	want := "package pkg\n\nfunc New() {}\n"
+	if err := (osFS{}).WriteFile(link, []byte(want), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if fi, err := os.Lstat(link); err != nil || fi.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("%s is no longer a symlink: %v, %v", link, fi.Mode(), err)
+	}
+	got, err := os.ReadFile(real)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != want {
+		t.Errorf("%s:\n%s\nwant:\n%s", real, got, want)
+	}
+	if fi, err := os.Stat(real); err != nil || fi.Mode().Perm() != 0640 {
+		t.Errorf("%s has mode %v, want 0640", real, fi.Mode().Perm())
+	}
+}
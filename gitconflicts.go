@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/jeremyschlatter/gorename-global/rename"
+)
+
+// A branchConflict is a file that the rename changes and that another
+// branch has changed too, so that merging the two will likely conflict.
+type branchConflict struct {
+	Branch string `json:"branch"`
+	Path   string `json:"path"`
+}
+
+// branchConflicts are found by -git-conflicts.
+var branchConflicts []branchConflict
+
+// findBranchConflicts looks for the changed files among files that have
+// also been changed on the given branches, or on every other local branch
+// if there are none, since the branch left the current one.
+func findBranchConflicts(files []*rename.File, branches []string) error {
+	top, err := git("rev-parse", "--show-toplevel")
+	if err != nil {
+		return err
+	}
+	top = strings.TrimSpace(top)
+	changed := make(map[string]string) // path from top -> path as given
+	for _, f := range files {
+		if !f.Changed() {
+			continue
+		}
+		if rel, ok := repoPath(top, f.Path); ok {
+			changed[rel] = f.Path
+		}
+	}
+	if len(branches) == 0 {
+		if branches, err = otherBranches(); err != nil {
+			return err
+		}
+	}
+	for _, b := range branches {
+		out, err := git("diff", "--name-only", "HEAD..."+b, "--")
+		if err != nil {
+			return err
+		}
+		for _, p := range strings.Fields(out) {
+			if path, ok := changed[p]; ok {
+				branchConflicts = append(branchConflicts, branchConflict{Branch: b, Path: path})
+			}
+		}
+	}
+	sort.Slice(branchConflicts, func(i, j int) bool {
+		a, b := branchConflicts[i], branchConflicts[j]
+		return a.Branch < b.Branch || a.Branch == b.Branch && a.Path < b.Path
+	})
+	return nil
+}
+
+// repoPath returns the slash-separated path of the file at p from top, the
+// top of its git work tree, and whether it is inside it.
+func repoPath(top, p string) (string, bool) {
+	abs, err := filepath.Abs(p)
+	if err != nil {
+		return "", false
+	}
+	rel, err := filepath.Rel(top, abs)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return filepath.ToSlash(rel), true
+}
+
+// otherBranches returns the local branches other than the current one.
+func otherBranches() ([]string, error) {
+	out, err := git("for-each-ref", "--format=%(refname:short)", "refs/heads/")
+	if err != nil {
+		return nil, err
+	}
+	// HEAD may be detached, in which case every branch is another.
+	current, _ := git("symbolic-ref", "--short", "-q", "HEAD")
+	current = strings.TrimSpace(current)
+	var branches []string
+	for _, b := range strings.Fields(out) {
+		if b != current {
+			branches = append(branches, b)
+		}
+	}
+	return branches, nil
+}
+
+// git runs git with args and returns its output.
+func git(args ...string) (string, error) {
+	out, err := exec.Command("git", args...).Output()
+	if err != nil {
+		if ee, ok := err.(*exec.ExitError); ok && len(ee.Stderr) > 0 {
+			return "", fmt.Errorf("git %s: %s", strings.Join(args, " "), strings.TrimSpace(string(ee.Stderr)))
+		}
+		return "", fmt.Errorf("git %s: %v", strings.Join(args, " "), err)
+	}
+	return string(out), nil
+}
@@ -0,0 +1,188 @@
+// Package golangci is a golangci-lint module plugin that reports the
+// identifiers that gorename-global's -auto rules would rename, and fixes
+// them with golangci-lint --fix. The golang.org/x/tools and
+// github.com/golangci/plugin-module-register packages it needs are
+// vendored, as the rest of the tree's dependencies are. List it in
+// .custom-gcl.yml,
+//
+//	version: v1.60.0
+//	plugins:
+//	  - module: github.com/jeremyschlatter/gorename-global
+//	    import: github.com/jeremyschlatter/gorename-global/golangci
+//	    version: latest
+//
+// build the custom binary with golangci-lint custom,
+// and enable the linter in .golangci.yml, where its settings take the
+// -auto rules and the flags that go with them:
+//
+//	linters-settings:
+//	  custom:
+//	    gorename-global:
+//	      type: module
+//	      settings:
+//	        rules: [underscores, initialisms]
+//	        exported-only: false
+//	linters:
+//	  enable: [gorename-global]
+package golangci
+
+import (
+	"fmt"
+	"go/token"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/golangci/plugin-module-register/register"
+	"golang.org/x/tools/go/analysis"
+
+	"github.com/jeremyschlatter/gorename-global/rename"
+)
+
+func init() {
+	register.Plugin("gorename-global", New)
+}
+
+// Settings are the plugin's settings in .golangci.yml.
+type Settings struct {
+	// Rules are the -auto rules to apply, the lint rules if empty.
+	Rules []string `json:"rules"`
+
+	ExportedOnly      bool     `json:"exported-only"`
+	Initialisms       []string `json:"initialisms"`
+	HungarianPrefixes []string `json:"hungarian-prefixes"`
+}
+
+// New returns the plugin with the settings conf, for golangci-lint.
+func New(conf any) (register.LinterPlugin, error) {
+	s, err := register.DecodeSettings[Settings](conf)
+	if err != nil {
+		return nil, err
+	}
+	return plugin{s}, nil
+}
+
+type plugin struct {
+	s Settings
+}
+
+func (p plugin) BuildAnalyzers() ([]*analysis.Analyzer, error) {
+	return []*analysis.Analyzer{NewAnalyzer(p.s)}, nil
+}
+
+// The rules need only the syntax.
+func (p plugin) GetLoadMode() string { return register.LoadModeSyntax }
+
+// NewAnalyzer returns an analyzer that reports each identifier that the
+// -auto rules of s would rename, once for each old and new name in a
+// package, with a fix that renames every use of it there. Renames that
+// would collide are reported without one.
+func NewAnalyzer(s Settings) *analysis.Analyzer {
+	return &analysis.Analyzer{
+		Name: "gorenameglobal",
+		Doc:  "reports identifiers that gorename-global -auto would rename",
+		Run:  s.run,
+	}
+}
+
+func (s Settings) run(pass *analysis.Pass) (any, error) {
+	readFile := pass.ReadFile
+	if readFile == nil {
+		readFile = os.ReadFile
+	}
+	var files []*rename.File
+	for _, af := range pass.Files {
+		name := pass.Fset.File(af.Pos()).Name()
+		if !strings.HasSuffix(name, ".go") {
+			continue // cgo output, say
+		}
+		src, err := readFile(name)
+		if err != nil {
+			return nil, err
+		}
+		f, err := rename.ParseFile(name, src)
+		if err != nil {
+			continue
+		}
+		files = append(files, f)
+	}
+	var (
+		mu      sync.Mutex
+		changes []rename.Change
+	)
+	opts := rename.Options{
+		Auto:              true,
+		AutoRules:         s.Rules,
+		ExportedOnly:      s.ExportedOnly,
+		Initialisms:       s.Initialisms,
+		HungarianPrefixes: s.HungarianPrefixes,
+		OnChange: func(c rename.Change) {
+			mu.Lock()
+			changes = append(changes, c)
+			mu.Unlock()
+		},
+	}
+	if err := rename.Rename(files, opts); err != nil {
+		return nil, err
+	}
+
+	// One diagnostic for each rename, at its first use, with every use.
+	type key struct{ old, new string }
+	var keys []key
+	edits := make(map[key][]analysis.TextEdit)
+	for _, c := range changes {
+		pos, ok := position(pass, c.Pos)
+		if !ok {
+			continue
+		}
+		k := key{c.Old, c.New}
+		if edits[k] == nil {
+			keys = append(keys, k)
+		}
+		edits[k] = append(edits[k], analysis.TextEdit{Pos: pos, End: pos + token.Pos(len(c.Old)), NewText: []byte(c.New)})
+	}
+	for _, k := range keys {
+		es := edits[k]
+		sort.Slice(es, func(i, j int) bool { return es[i].Pos < es[j].Pos })
+		msg := fmt.Sprintf("%s should be %s", k.old, k.new)
+		pass.Report(analysis.Diagnostic{
+			Pos:            es[0].Pos,
+			End:            es[0].End,
+			Message:        msg,
+			SuggestedFixes: []analysis.SuggestedFix{{Message: "rename " + k.old + " to " + k.new, TextEdits: es}},
+		})
+	}
+	for _, f := range files {
+		for _, c := range f.Conflicts() {
+			if pos, ok := position(pass, c.Pos); ok {
+				pass.Reportf(pos, "%s should be %s, but that would collide with %s", c.Old, c.New, c.With)
+			}
+		}
+	}
+	return nil, nil
+}
+
+// position returns the position in pass of pos, a file:line:column from
+// Rename.
+func position(pass *analysis.Pass, pos string) (token.Pos, bool) {
+	i := strings.LastIndex(pos, ":")
+	j := strings.LastIndex(pos[:max(i, 0)], ":")
+	if j < 0 {
+		return token.NoPos, false
+	}
+	line, err1 := strconv.Atoi(pos[j+1 : i])
+	col, err2 := strconv.Atoi(pos[i+1:])
+	if err1 != nil || err2 != nil {
+		return token.NoPos, false
+	}
+	name := pos[:j]
+	for _, af := range pass.Files {
+		tf := pass.Fset.File(af.Pos())
+		if tf.Name() == name && line <= tf.LineCount() {
+			return tf.LineStart(line) + token.Pos(col-1), true
+		}
+	}
+	return token.NoPos, false
+}
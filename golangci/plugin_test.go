@@ -0,0 +1,52 @@
+package golangci
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"testing"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+func TestAnalyzer(t *testing.T) {
+	const src = `package a
+
+var user_id int
+
+func f() int { return user_id }
+`
+	fset := token.NewFileSet()
+	af, err := parser.ParseFile(fset, "a.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var diags []analysis.Diagnostic
+	pass := &analysis.Pass{
+		Fset:     fset,
+		Files:    []*ast.File{af},
+		ReadFile: func(string) ([]byte, error) { return []byte(src), nil },
+		Report:   func(d analysis.Diagnostic) { diags = append(diags, d) },
+	}
+	if _, err := NewAnalyzer(Settings{}).Run(pass); err != nil {
+		t.Fatal(err)
+	}
+	if len(diags) != 1 {
+		t.Fatalf("got %d diagnostics, want 1: %v", len(diags), diags)
+	}
+	d := diags[0]
+	if d.Message != "user_id should be userID" || fset.Position(d.Pos).Line != 3 {
+		t.Errorf("got %q at %v", d.Message, fset.Position(d.Pos))
+	}
+	var got []string
+	for _, fix := range d.SuggestedFixes {
+		for _, e := range fix.TextEdits {
+			got = append(got, fset.Position(e.Pos).String()+" "+src[fset.Position(e.Pos).Offset:fset.Position(e.End).Offset]+" -> "+string(e.NewText))
+		}
+	}
+	want := []string{"a.go:3:5 user_id -> userID", "a.go:5:23 user_id -> userID"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("edits %q, want %q", got, want)
+	}
+}
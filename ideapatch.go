@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ideaContext is how many unchanged lines surround each hunk of an
+// -report=idea-patch patch, as in git's.
+const ideaContext = 3
+
+// ideaPatches are the unified diffs of the changed files, by path relative
+// to the top of the work tree, collected for -report=idea-patch.
+var ideaPatches = struct {
+	sync.Mutex
+	m map[string]string
+}{
+	m: make(map[string]string),
+}
+
+// diffFile records the unified diff that turns old, the source of the file
+// at path, into new.
+func diffFile(path string, old, new []byte) {
+	path = topRelative(path)
+	d := unifiedDiff(old, new)
+	ideaPatches.Lock()
+	ideaPatches.m[path] = d
+	ideaPatches.Unlock()
+}
+
+// unifiedDiff returns the hunks of the unified diff from old to new, with
+// ideaContext lines of context, joining hunks whose context would meet.
+func unifiedDiff(old, new []byte) string {
+	ol := strings.SplitAfter(string(old), "\n")
+	n := len(ol)
+	if ol[n-1] == "" {
+		n--
+	}
+	hs := lineHunks(old, new)
+	var b strings.Builder
+	delta := 0 // lines added less lines removed, before the hunk
+	for i := 0; i < len(hs); {
+		j := i
+		for j+1 < len(hs) && hs[j+1].start-hs[j].end <= 2*ideaContext {
+			j++
+		}
+		start, end := max(hs[i].start-ideaContext, 0), min(hs[j].end+ideaContext, n)
+		var body strings.Builder
+		oldLines, newLines := 0, 0
+		line := func(prefix byte, l string) {
+			body.WriteByte(prefix)
+			body.WriteString(l)
+			if !strings.HasSuffix(l, "\n") {
+				body.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+		from := delta
+		at := start
+		for _, h := range hs[i : j+1] {
+			for ; at < h.start; at++ {
+				line(' ', ol[at])
+				oldLines++
+				newLines++
+			}
+			for _, l := range h.old {
+				if l != "" {
+					line('-', l)
+					oldLines++
+				}
+			}
+			for _, l := range h.new {
+				if l != "" {
+					line('+', l)
+					newLines++
+				}
+			}
+			at = h.end
+			delta += len(h.new) - len(h.old)
+		}
+		for ; at < end; at++ {
+			line(' ', ol[at])
+			oldLines++
+			newLines++
+		}
+		fmt.Fprintf(&b, "@@ -%s +%s @@\n", hunkRange(start, oldLines), hunkRange(start+from, newLines))
+		b.WriteString(body.String())
+		i = j + 1
+	}
+	return b.String()
+}
+
+// hunkRange returns the range of a unified diff hunk header for count
+// lines after the first start, which, if there are none, it names.
+func hunkRange(start, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
+
+// printIDEAPatch prints the diffs to w as a patch that GoLand and the other
+// IntelliJ IDEs import with Git | Patch | Apply Patch, showing the changes
+// in their preview before applying them. Its Subject and message, which the
+// IDE offers as the commit message, list the renames in changed.
+func printIDEAPatch(w io.Writer, changed []pair) {
+	subject := fmt.Sprintf("Rename %d identifiers", len(changed))
+	if len(changed) == 1 {
+		subject = fmt.Sprintf("Rename %s to %s", changed[0].From, changed[0].To)
+	}
+	fmt.Fprintf(w, "Subject: [PATCH] %s\n\n", subject)
+	for _, p := range changed {
+		fmt.Fprintf(w, "%s -> %s (%s in %s)\n", p.From, p.To, plural(p.Occurrences, "identifier"), plural(p.Files, "file"))
+	}
+	fmt.Fprintf(w, "---\n")
+	var paths []string
+	for p := range ideaPatches.m {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	for _, p := range paths {
+		fmt.Fprintf(w, "Index: %s\n", p)
+		fmt.Fprintf(w, "IDEA additional info:\nSubsystem: com.intellij.openapi.diff.impl.patch.CharsetEP\n<+>UTF-8\n")
+		fmt.Fprintf(w, "%s\n", strings.Repeat("=", 67))
+		fmt.Fprintf(w, "diff --git a/%s b/%s\n--- a/%s\n+++ b/%s\n", p, p, p, p)
+		io.WriteString(w, ideaPatches.m[p])
+	}
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// skippedDirs are the directories that listGoFiles doesn't walk into, unless
+// named or with -include-ignored: those of version control, and those that
+// hold copies of other code, which are renamed in where they come from.
+var skippedDirs = map[string]bool{
+	".git":         true,
+	".hg":          true,
+	".svn":         true,
+	".bzr":         true,
+	".jj":          true,
+	"node_modules": true,
+	"vendor":       true,
+	"third_party":  true,
+}
+
+// An ignorePattern is a line of a .gitignore file, matching paths relative
+// to the file's directory.
+type ignorePattern struct {
+	re      *regexp.Regexp
+	negate  bool
+	dirOnly bool
+}
+
+// An ignoreFile is a .gitignore file, with the directory it is in.
+type ignoreFile struct {
+	dir      string
+	patterns []ignorePattern
+}
+
+// ignorer matches paths against the .gitignore files that apply to them
+// while listGoFiles walks a tree: those in the directories above it, up to
+// the top of its git work tree, and those in it so far.
+type ignorer struct {
+	files []ignoreFile
+}
+
+// newIgnorer returns an ignorer for a walk of dir, holding the .gitignore
+// files of the directories above it up to the top of the work tree it is
+// in, if any.
+func newIgnorer(dir string) *ignorer {
+	ig := new(ignorer)
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return ig
+	}
+	if _, err := os.Stat(filepath.Join(abs, ".git")); err == nil {
+		return ig // dir is the top
+	}
+	var above []string
+	for d := filepath.Dir(abs); ; d = filepath.Dir(d) {
+		above = append(above, d)
+		if _, err := os.Stat(filepath.Join(d, ".git")); err == nil {
+			break
+		}
+		if filepath.Dir(d) == d {
+			return ig // not in a work tree
+		}
+	}
+	for i := len(above) - 1; i >= 0; i-- {
+		ig.read(above[i])
+	}
+	return ig
+}
+
+// read adds the .gitignore file in the absolute directory dir, if there is
+// one.
+func (ig *ignorer) read(dir string) {
+	b, err := os.ReadFile(filepath.Join(dir, ".gitignore"))
+	if err != nil {
+		return
+	}
+	f := ignoreFile{dir: dir}
+	sc := bufio.NewScanner(bytes.NewReader(b))
+	for sc.Scan() {
+		if p, ok := parseIgnorePattern(sc.Text()); ok {
+			f.patterns = append(f.patterns, p)
+		}
+	}
+	ig.files = append(ig.files, f)
+}
+
+// enter adds the .gitignore file in dir, which the walk has come to, and
+// drops those of directories the walk has left.
+func (ig *ignorer) enter(dir string) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return
+	}
+	files := ig.files[:0]
+	for _, f := range ig.files {
+		if within(f.dir, abs) {
+			files = append(files, f)
+		}
+	}
+	ig.files = files
+	ig.read(abs)
+}
+
+// ignored reports whether the .gitignore files exclude path. As with git,
+// the last pattern to match it decides, and those of deeper files come
+// later. What is in an excluded directory is not matched, since the walk
+// doesn't go into it.
+func (ig *ignorer) ignored(path string, isDir bool) bool {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+	ignored := false
+	for _, f := range ig.files {
+		rel, err := filepath.Rel(f.dir, abs)
+		if err != nil || !within(f.dir, abs) {
+			continue
+		}
+		rel = filepath.ToSlash(rel)
+		for _, p := range f.patterns {
+			if (isDir || !p.dirOnly) && p.re.MatchString(rel) {
+				ignored = !p.negate
+			}
+		}
+	}
+	return ignored
+}
+
+// within reports whether the absolute path p is dir or under it.
+func within(dir, p string) bool {
+	return p == dir || strings.HasPrefix(p, strings.TrimSuffix(dir, string(filepath.Separator))+string(filepath.Separator))
+}
+
+// parseIgnorePattern parses a line of a .gitignore file, reporting false
+// for a blank line or comment.
+func parseIgnorePattern(line string) (ignorePattern, bool) {
+	var p ignorePattern
+	for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, `\ `) {
+		line = line[:len(line)-1]
+	}
+	if line == "" || line[0] == '#' {
+		return p, false
+	}
+	if line[0] == '!' {
+		p.negate = true
+		line = line[1:]
+	} else if line[0] == '\\' && len(line) > 1 && (line[1] == '#' || line[1] == '!') {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		p.dirOnly = true
+		line = strings.TrimSuffix(line, "/")
+	}
+	if line == "" {
+		return p, false
+	}
+	// A pattern with a slash other than at its end is relative to the
+	// directory of the .gitignore file; one without matches at any depth.
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+	var re strings.Builder
+	re.WriteString("^")
+	if !anchored {
+		re.WriteString("(?:.*/)?")
+	}
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case strings.HasPrefix(line[i:], "**/"):
+			re.WriteString("(?:.*/)?")
+			i += 2
+		case line[i:] == "**":
+			re.WriteString(".*")
+			i++
+		case c == '*':
+			re.WriteString("[^/]*")
+		case c == '?':
+			re.WriteString("[^/]")
+		case c == '[':
+			j := strings.IndexByte(line[i+1:], ']')
+			if j < 0 {
+				re.WriteString(`\[`)
+				continue
+			}
+			class := line[i+1 : i+1+j]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			re.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += j + 1
+		case c == '\\' && i+1 < len(line):
+			i++
+			re.WriteString(regexp.QuoteMeta(line[i : i+1]))
+		default:
+			re.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	re.WriteString("$")
+	var err error
+	if p.re, err = regexp.Compile(re.String()); err != nil {
+		return p, false
+	}
+	return p, true
+}
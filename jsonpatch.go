@@ -0,0 +1,94 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// A filePatch is the RFC 6902 JSON Patch that renames one file, taken as a
+// JSON array of its lines, each with its line ending. Every line a patch
+// changes is first tested to be what it was, and SHA256 is the hash of the
+// whole original file, so either can tell a patch from a stale one.
+type filePatch struct {
+	Path   string        `json:"path"`
+	SHA256 string        `json:"sha256"`
+	Patch  []jsonPatchOp `json:"patch"`
+}
+
+type jsonPatchOp struct {
+	Op    string `json:"op"` // test, replace, remove, or add
+	Path  string `json:"path"`
+	Value string `json:"value,omitempty"`
+}
+
+// filePatches are collected for -report=json-patch.
+var filePatches = struct {
+	sync.Mutex
+	list []filePatch
+}{}
+
+// patchFile records the JSON Patch that turns old, the source of the file
+// at path, into new. The hunks are patched from the last, so that the line
+// numbers of the earlier ones still hold.
+func patchFile(path string, old, new []byte) {
+	sum := sha256.Sum256(old)
+	p := filePatch{Path: topRelative(path), SHA256: hex.EncodeToString(sum[:]), Patch: []jsonPatchOp{}}
+	hs := lineHunks(old, new)
+	for i := len(hs) - 1; i >= 0; i-- {
+		h := hs[i]
+		ol, nl := nonEmpty(h.old), nonEmpty(h.new)
+		for k, l := range ol {
+			p.Patch = append(p.Patch, jsonPatchOp{Op: "test", Path: linePointer(h.start + k), Value: l})
+		}
+		if len(ol) == len(nl) {
+			for k, l := range nl {
+				p.Patch = append(p.Patch, jsonPatchOp{Op: "replace", Path: linePointer(h.start + k), Value: l})
+			}
+			continue
+		}
+		for range ol {
+			p.Patch = append(p.Patch, jsonPatchOp{Op: "remove", Path: linePointer(h.start)})
+		}
+		for k, l := range nl {
+			p.Patch = append(p.Patch, jsonPatchOp{Op: "add", Path: linePointer(h.start + k), Value: l})
+		}
+	}
+	filePatches.Lock()
+	filePatches.list = append(filePatches.list, p)
+	filePatches.Unlock()
+}
+
+// nonEmpty returns the lines in ls other than the empty one that follows a
+// final line ending, which isn't a line of the document.
+func nonEmpty(ls []string) []string {
+	var out []string
+	for _, l := range ls {
+		if l != "" {
+			out = append(out, l)
+		}
+	}
+	return out
+}
+
+// linePointer returns the JSON Pointer to line i, counting from 0.
+func linePointer(i int) string {
+	return "/" + strconv.Itoa(i)
+}
+
+// printJSONPatches prints the patches as a JSON array, by path.
+func printJSONPatches() {
+	list := filePatches.list
+	sort.Slice(list, func(i, j int) bool { return list[i].Path < list[j].Path })
+	if list == nil {
+		list = []filePatch{}
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "\t")
+	enc.SetEscapeHTML(false)
+	enc.Encode(list)
+}
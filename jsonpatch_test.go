@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+var jsonPatchTests = []struct {
+	name     string
+	old, new string
+}{
+	{
+		name: "replace",
+		old:  "package p\n\nfunc Old() {}\n\nfunc f() {}\n\nvar _ = Old\n",
+		new:  "package p\n\nfunc New() {}\n\nfunc f() {}\n\nvar _ = New\n",
+	},
+	{
+		name: "grow",
+		old:  "package p\n\nimport \"example.com/old\"\n\nvar _ = old.X\n",
+		new:  "package p\n\nimport (\n\t\"example.com/new\"\n)\n\nvar _ = new.X\n",
+	},
+	{
+		name: "shrink",
+		old:  "package p\n\nimport (\n\t\"example.com/old\"\n)\n\nvar _ = old.X\n",
+		new:  "package p\n\nimport \"example.com/new\"\n\nvar _ = new.X\n",
+	},
+	{
+		name: "crlf",
+		old:  "package p\r\n\r\nfunc Old() {}\r\n",
+		new:  "package p\r\n\r\nfunc New() {}\r\n",
+	},
+	{
+		name: "no-final-newline",
+		old:  "package p\n\nfunc Old() {}",
+		new:  "package p\n\nfunc New() {}",
+	},
+}
+
+func TestJSONPatch(t *testing.T) {
+	for _, tt := range jsonPatchTests {
+		t.Run(tt.name, func(t *testing.T) {
+			filePatches.list = nil
+			patchFile("p.go", []byte(tt.old), []byte(tt.new))
+			p := filePatches.list[0]
+			filePatches.list = nil
+
+			got, err := applyJSONPatch(nonEmpty(strings.SplitAfter(tt.old, "\n")), p.Patch)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Join(got, "") != tt.new {
+				t.Errorf("patched:\n%q\nwant:\n%q", strings.Join(got, ""), tt.new)
+			}
+			var buf bytes.Buffer
+			enc := json.NewEncoder(&buf)
+			enc.SetIndent("", "\t")
+			enc.SetEscapeHTML(false)
+			enc.Encode(p.Patch)
+			checkGolden(t, "jsonpatch/"+tt.name, buf.Bytes())
+		})
+	}
+}
+
+// applyJSONPatch applies the RFC 6902 operations ops to doc, an array of
+// strings, as a JSON Patch library would.
+func applyJSONPatch(doc []string, ops []jsonPatchOp) ([]string, error) {
+	doc = append([]string(nil), doc...)
+	for _, op := range ops {
+		i, err := strconv.Atoi(strings.TrimPrefix(op.Path, "/"))
+		if err != nil || !strings.HasPrefix(op.Path, "/") || i < 0 || i > len(doc) || i == len(doc) && op.Op != "add" {
+			return nil, fmt.Errorf("bad path in %s %s", op.Op, op.Path)
+		}
+		switch op.Op {
+		case "test":
+			if doc[i] != op.Value {
+				return nil, fmt.Errorf("test %s failed: it is %q", op.Path, doc[i])
+			}
+		case "replace":
+			doc[i] = op.Value
+		case "remove":
+			doc = append(doc[:i], doc[i+1:]...)
+		case "add":
+			doc = append(doc[:i], append([]string{op.Value}, doc[i:]...)...)
+		default:
+			return nil, fmt.Errorf("unknown op %s", op.Op)
+		}
+	}
+	return doc, nil
+}
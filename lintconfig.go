@@ -0,0 +1,328 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/jeremyschlatter/gorename-global/rename"
+)
+
+// A lintConfig is what a revive or staticcheck configuration says about
+// the naming rules that -auto follows.
+type lintConfig struct {
+	// initialisms are those the linter wants upper-cased, or nil for
+	// those 'go lint' knows.
+	initialisms []string
+
+	// disabled is set if the linter doesn't check names at all.
+	disabled bool
+}
+
+// readLintConfig reads the -lint-config file at p: a staticcheck.conf, by
+// its name, or otherwise a revive.toml.
+func readLintConfig(p string) (lintConfig, error) {
+	b, err := os.ReadFile(p)
+	if err != nil {
+		return lintConfig{}, err
+	}
+	t, err := parseTOML(string(b))
+	if err != nil {
+		return lintConfig{}, fmt.Errorf("%s: %v", p, err)
+	}
+	if filepath.Base(p) == "staticcheck.conf" {
+		return staticcheckConfig(t), nil
+	}
+	return reviveConfig(t), nil
+}
+
+// reviveConfig returns what the revive configuration t says about its
+// var-naming rule, which is run if it has a [rule.var-naming] table, or
+// enableAllRules is set, unless the table says it is disabled. The rule's
+// first argument lists initialisms it allows, which aren't upper-cased,
+// and its second those it adds.
+func reviveConfig(t map[string]any) lintConfig {
+	const rule = "rule.var-naming"
+	disabled, _ := t[rule+".disabled"].(bool)
+	all, _ := t["enableAllRules"].(bool)
+	_, listed := t[rule]
+	c := lintConfig{disabled: disabled || !listed && !all}
+	args, _ := t[rule+".arguments"].([]any)
+	if len(args) == 0 {
+		return c
+	}
+	is := make(map[string]bool)
+	for _, i := range rename.CommonInitialisms() {
+		is[i] = true
+	}
+	for _, i := range tomlStrings(args[0]) {
+		delete(is, strings.ToUpper(i))
+	}
+	if len(args) > 1 {
+		for _, i := range tomlStrings(args[1]) {
+			is[strings.ToUpper(i)] = true
+		}
+	}
+	c.initialisms = []string{}
+	for i := range is {
+		c.initialisms = append(c.initialisms, i)
+	}
+	return c
+}
+
+// staticcheckDefaultChecks are the checks staticcheck runs if its
+// configuration doesn't say.
+var staticcheckDefaultChecks = []string{"all", "-ST1000", "-ST1003", "-ST1016", "-ST1020", "-ST1021", "-ST1022", "-ST1023"}
+
+// staticcheckConfig returns what the staticcheck configuration t says
+// about ST1003, its naming check: whether its checks enable it, and the
+// initialisms it takes. "inherit" in either list stands for the defaults.
+func staticcheckConfig(t map[string]any) lintConfig {
+	checks := staticcheckDefaultChecks
+	if cs, ok := t["checks"]; ok {
+		checks = nil
+		for _, c := range tomlStrings(cs) {
+			if c == "inherit" {
+				checks = append(checks, staticcheckDefaultChecks...)
+			} else {
+				checks = append(checks, c)
+			}
+		}
+	}
+	enabled := false
+	for _, c := range checks {
+		pat := strings.TrimPrefix(c, "-")
+		if ok, _ := path.Match(pat, "ST1003"); ok || pat == "all" {
+			enabled = !strings.HasPrefix(c, "-")
+		}
+	}
+	c := lintConfig{disabled: !enabled}
+	if is, ok := t["initialisms"]; ok {
+		c.initialisms = []string{}
+		for _, i := range tomlStrings(is) {
+			if i == "inherit" {
+				c.initialisms = append(c.initialisms, rename.CommonInitialisms()...)
+			} else {
+				c.initialisms = append(c.initialisms, i)
+			}
+		}
+	}
+	return c
+}
+
+// tomlStrings returns the strings in v, a TOML array.
+func tomlStrings(v any) []string {
+	var ss []string
+	a, _ := v.([]any)
+	for _, x := range a {
+		if s, ok := x.(string); ok {
+			ss = append(ss, s)
+		}
+	}
+	return ss
+}
+
+// parseTOML parses the TOML in s, as far as linter configurations use it,
+// into a map by dotted key, such as "rule.var-naming.arguments", in which
+// each table is also present, as true. Values are strings, booleans,
+// arrays, as []any, and, as their source text, numbers and dates; inline
+// tables are nil.
+func parseTOML(s string) (map[string]any, error) {
+	t := make(map[string]any)
+	table := ""
+	lines := strings.Split(s, "\n")
+	for n := 0; n < len(lines); n++ {
+		line := strings.TrimSpace(lines[n])
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		if line[0] == '[' {
+			name := strings.Trim(stripTOMLComment(line), "[] \t")
+			table = unquoteTOMLKey(name)
+			t[table] = true
+			continue
+		}
+		k, v, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("line %d: want key = value", n+1)
+		}
+		// An array may go on for several lines.
+		start := n
+		for !tomlComplete(v) && n+1 < len(lines) {
+			n++
+			v += "\n" + lines[n]
+		}
+		val, rest, err := parseTOMLValue(strings.TrimSpace(v))
+		if err == nil && strings.TrimSpace(stripTOMLComment(rest)) != "" {
+			err = fmt.Errorf("unexpected %q", strings.TrimSpace(rest))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", start+1, err)
+		}
+		key := unquoteTOMLKey(strings.TrimSpace(k))
+		if table != "" {
+			key = table + "." + key
+		}
+		t[key] = val
+	}
+	return t, nil
+}
+
+// unquoteTOMLKey returns the dotted key k with its quoted parts unquoted.
+func unquoteTOMLKey(k string) string {
+	parts := strings.Split(k, ".")
+	for i, p := range parts {
+		p = strings.TrimSpace(p)
+		if u, err := strconv.Unquote(p); err == nil {
+			p = u
+		} else {
+			p = strings.Trim(p, "'")
+		}
+		parts[i] = p
+	}
+	return strings.Join(parts, ".")
+}
+
+// tomlComplete reports whether the value v closes every bracket and brace
+// it opens, outside strings and comments.
+func tomlComplete(v string) bool {
+	depth := 0
+	for i := 0; i < len(v); i++ {
+		switch c := v[i]; c {
+		case '"', '\'':
+			j := tomlStringEnd(v, i)
+			if j < 0 {
+				return false
+			}
+			i = j - 1
+		case '#':
+			for i < len(v) && v[i] != '\n' {
+				i++
+			}
+		case '[', '{':
+			depth++
+		case ']', '}':
+			depth--
+		}
+	}
+	return depth <= 0
+}
+
+// tomlStringEnd returns the offset after the string starting at v[i], or
+// -1 if it isn't closed.
+func tomlStringEnd(v string, i int) int {
+	q := v[i]
+	for j := i + 1; j < len(v); j++ {
+		switch {
+		case v[j] == '\\' && q == '"':
+			j++
+		case v[j] == q:
+			return j + 1
+		}
+	}
+	return -1
+}
+
+// stripTOMLComment returns s up to any comment, outside strings.
+func stripTOMLComment(s string) string {
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '"', '\'':
+			j := tomlStringEnd(s, i)
+			if j < 0 {
+				return s
+			}
+			i = j - 1
+		case '#':
+			return s[:i]
+		}
+	}
+	return s
+}
+
+// parseTOMLValue parses the value at the start of s, and returns it and
+// what follows it.
+func parseTOMLValue(s string) (any, string, error) {
+	s = skipTOMLSpace(s)
+	if s == "" {
+		return nil, "", fmt.Errorf("missing value")
+	}
+	switch s[0] {
+	case '"', '\'':
+		j := tomlStringEnd(s, 0)
+		if j < 0 {
+			return nil, "", fmt.Errorf("unterminated string")
+		}
+		if s[0] == '\'' {
+			return s[1 : j-1], s[j:], nil
+		}
+		u, err := strconv.Unquote(s[:j])
+		if err != nil {
+			return nil, "", fmt.Errorf("bad string %s", s[:j])
+		}
+		return u, s[j:], nil
+	case '[', '{':
+		closer := byte(']')
+		if s[0] == '{' {
+			closer = '}'
+		}
+		var a []any
+		s = s[1:]
+		for {
+			s = skipTOMLSpace(s)
+			if s == "" {
+				return nil, "", fmt.Errorf("unterminated %c", closer)
+			}
+			if s[0] == closer {
+				if closer == '}' {
+					return nil, s[1:], nil
+				}
+				return a, s[1:], nil
+			}
+			if closer == '}' {
+				// Skip the key of an inline table's entry.
+				if i := strings.IndexByte(s, '='); i >= 0 {
+					s = s[i+1:]
+				}
+			}
+			v, rest, err := parseTOMLValue(s)
+			if err != nil {
+				return nil, "", err
+			}
+			a = append(a, v)
+			s = skipTOMLSpace(rest)
+			if s != "" && s[0] == ',' {
+				s = s[1:]
+			}
+		}
+	}
+	i := strings.IndexAny(s, ",]}# \t\n")
+	if i < 0 {
+		i = len(s)
+	}
+	switch word := s[:i]; word {
+	case "true", "false":
+		return word == "true", s[i:], nil
+	default:
+		return word, s[i:], nil
+	}
+}
+
+// skipTOMLSpace returns s without its leading space, newlines, and
+// comments.
+func skipTOMLSpace(s string) string {
+	for {
+		s = strings.TrimLeft(s, " \t\r\n")
+		if !strings.HasPrefix(s, "#") {
+			return s
+		}
+		if i := strings.IndexByte(s, '\n'); i >= 0 {
+			s = s[i+1:]
+		} else {
+			return ""
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/jeremyschlatter/gorename-global/rename"
+)
+
+// lockName is the name of the lock file that a run puts at the root of each
+// module it writes to, so that two runs don't write to the same files at
+// once.
+const lockName = ".gorename-global.lock"
+
+// held are the lock files this run holds.
+var held struct {
+	sync.Mutex
+	paths []string
+}
+
+// lockModules takes the lock of every module with a file in files to be
+// written, or of the file's directory outside any module, in order, so
+// that two runs taking the same locks can't each wait for the other. A
+// lock held by another run is waited for, for up to -lock-wait, and then
+// is an error; one left by a run on this host that is no longer running
+// is taken over.
+func lockModules(files []*rename.File) error {
+	seen := make(map[string]bool)
+	var roots []string
+	for _, f := range files {
+		if !f.Changed() || f.Broken() {
+			continue
+		}
+		dir, err := filepath.Abs(filepath.Dir(f.Path))
+		if err != nil {
+			return err
+		}
+		if root := modRoot(dir); !seen[root] {
+			seen[root] = true
+			roots = append(roots, root)
+		}
+	}
+	sort.Strings(roots)
+	held.Lock()
+	have := make(map[string]bool) // from an earlier -batch
+	for _, p := range held.paths {
+		have[p] = true
+	}
+	held.Unlock()
+	for _, root := range roots {
+		if p := filepath.Join(root, lockName); !have[p] {
+			if err := lock(p); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// modRoot returns the nearest directory at or above dir with a go.mod
+// file, or dir if there is none.
+func modRoot(dir string) string {
+	for d := dir; ; d = filepath.Dir(d) {
+		if _, err := os.Stat(filepath.Join(d, "go.mod")); err == nil {
+			return d
+		}
+		if filepath.Dir(d) == d {
+			return dir
+		}
+	}
+}
+
+// lock creates the lock file at path, saying which process holds it.
+func lock(path string) error {
+	host, _ := os.Hostname()
+	deadline := time.Now().Add(*lockWait)
+	for {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0666)
+		if err == nil {
+			fmt.Fprintf(f, "%d %s %s\n", os.Getpid(), host, time.Now().Format(time.RFC3339))
+			if err := f.Close(); err != nil {
+				os.Remove(path)
+				return err
+			}
+			held.Lock()
+			held.paths = append(held.paths, path)
+			held.Unlock()
+			return nil
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return err
+		}
+		b, _ := os.ReadFile(path)
+		owner := strings.Fields(string(b))
+		if len(owner) >= 2 && owner[1] == host {
+			if pid, err := strconv.Atoi(owner[0]); err == nil && !processAlive(pid) {
+				logger.Warn("taking over the lock of a run that is gone", "path", path, "pid", pid)
+				os.Remove(path)
+				continue
+			}
+		}
+		if time.Now().After(deadline) {
+			holder := "another run"
+			if len(owner) >= 3 {
+				holder = fmt.Sprintf("another run, pid %s on %s since %s", owner[0], owner[1], owner[2])
+			}
+			return fmt.Errorf("%s is held by %s; wait for it to finish, or pass -lock-wait, or remove the file if that run is gone", path, holder)
+		}
+		logger.Debug("waiting for lock", "path", path)
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// processAlive reports whether the process pid is running on this host.
+func processAlive(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	if runtime.GOOS == "windows" {
+		return true // FindProcess opens the process, so it is running
+	}
+	err = p.Signal(syscall.Signal(0))
+	return err == nil || errors.Is(err, syscall.EPERM) // running as someone else
+}
+
+// unlockModules removes the lock files this run holds. Since os.Exit skips
+// deferred calls, the ways out of a run that may hold them call it.
+func unlockModules() {
+	held.Lock()
+	defer held.Unlock()
+	for _, p := range held.paths {
+		os.Remove(p)
+	}
+	held.paths = nil
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// holdLock writes the lock file at path as the run pid on this host would.
+func holdLock(t *testing.T, path string, pid int) {
+	t.Helper()
+	host, _ := os.Hostname()
+	line := fmt.Sprintf("%d %s %s\n", pid, host, time.Now().Format(time.RFC3339))
+	if err := os.WriteFile(path, []byte(line), 0666); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// setLockWait sets -lock-wait to d for the rest of t.
+func setLockWait(t *testing.T, d time.Duration) {
+	old := *lockWait
+	*lockWait = d
+	t.Cleanup(func() { *lockWait = old })
+}
+
+func TestLockHeld(t *testing.T) {
+	setLockWait(t, 0)
+	path := filepath.Join(t.TempDir(), lockName)
+	holdLock(t, path, os.Getpid()) // a run still going
+	err := lock(path)
+	if err == nil {
+		unlockModules()
+		t.Fatal("lock succeeded on a lock held by another run")
+	}
+	if !strings.Contains(err.Error(), "is held by another run, pid") {
+		t.Errorf("lock: %v", err)
+	}
+}
+
+func TestLockWait(t *testing.T) {
+	setLockWait(t, 10*time.Second)
+	path := filepath.Join(t.TempDir(), lockName)
+	holdLock(t, path, os.Getpid())
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		os.Remove(path) // the other run finishes
+	}()
+	if err := lock(path); err != nil {
+		t.Fatal(err)
+	}
+	defer unlockModules()
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pid := strings.Fields(string(b))[0]; pid != fmt.Sprint(os.Getpid()) {
+		t.Errorf("lock file names pid %s, want %d", pid, os.Getpid())
+	}
+}
+
+func TestLockStale(t *testing.T) {
+	setLockWait(t, 0)
+	cmd := exec.Command(os.Args[0], "-test.run=^$")
+	if err := cmd.Run(); err != nil {
+		t.Skip(err)
+	}
+	path := filepath.Join(t.TempDir(), lockName)
+	holdLock(t, path, cmd.Process.Pid) // a run that is gone
+	if err := lock(path); err != nil {
+		t.Fatal(err)
+	}
+	unlockModules()
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("%s is still there after unlockModules: %v", path, err)
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"log/slog"
+	"os"
+)
+
+// logger receives all diagnostics. Set up by setupLog; until then, and for
+// usage errors, it writes text.
+var logger = slog.New(newLogHandler("text", slog.LevelInfo))
+
+// setupLog points logger at stderr in the -log-format format, at the
+// -log-level level. Text diagnostics are colored as setupColor says.
+func setupLog(format string, level slog.Level) {
+	if format != "text" && format != "json" {
+		usage()
+	}
+	logger = slog.New(newLogHandler(format, level))
+}
+
+func newLogHandler(format string, level slog.Level) slog.Handler {
+	opts := &slog.HandlerOptions{Level: level}
+	if format == "json" {
+		return slog.NewJSONHandler(os.Stderr, opts)
+	}
+	// Times make text lines harder to read, and CI already adds its own.
+	opts.ReplaceAttr = func(groups []string, a slog.Attr) slog.Attr {
+		if len(groups) == 0 && a.Key == slog.TimeKey {
+			return slog.Attr{}
+		}
+		return a
+	}
+	return colorHandler{slog.NewTextHandler(os.Stderr, opts)}
+}
@@ -7,230 +7,1946 @@
 // It is still safer than using sed, though. It will only replace Go identifiers that
 // exactly match the --from argument.
 //
+// Building gorename-global needs Go 1.21 or later.
+//
+// The flags can also be split up by command, each of which takes only the flags
+// that apply to it, besides those that control how files are found, written and
+// reported, and has its own -help: rename, for --from and --to, --to-template, -e,
+// --map or --matcher-cmd; auto, for --auto's rules, given as -rules; check, which
+// reports what either would change, as --check does; list, which prints each
+// identifier that would change and where; undo, which renames back the identifiers
+// listed in a --report=json summary; and apply, which runs a script written by
+// --record. For example:
+//
+//	gorename-global list -from Old -to New ./...
+//	gorename-global auto -rules=initialisms ./...
+//	gorename-global undo summary.json
+//
+// When --from is declared at the top level of a package, locals in that package
+// that shadow it, such as a log := ... inside a function when renaming a
+// package-level log, are different things with the same name and are left alone,
+// along with their uses.
+//
+// The --from argument may be qualified with a package name, as in --from pkg.Old
+// --to pkg.New (or just --to New). Then only references through that package are
+// renamed, along with the declaration itself if package pkg is among the ones
+// named. If a struct embeds pkg.Old or *pkg.Old, the embedded field's selectors
+// and composite literal keys are renamed as well, as type-checking the packages
+// finds them, so that same-named fields of other types are left alone.
+//
+// --enum-prefix renames a family of constants in one go. Each constant declared in
+// a parenthesized const block whose name starts with the prefix, followed by a new
+// camelCase word, gets the prefix replaced by --to, along with its uses, so that
+// ColorRed and ColorGreen become HueRed and HueGreen, while Colorful is left
+// alone:
+//
+//	gorename-global --enum-prefix Color --to Hue ./...
+//
+// --prefix-exported puts a prefix in front of every exported name declared at the
+// top level of the packages named, other than in their tests, and updates their
+// uses, so that a small package can be folded into a bigger one without its names
+// colliding with the bigger one's:
+//
+//	gorename-global --prefix-exported Cache ./internal/cache
+//
+// --unexport unexports a name where the packages named declare it at the top
+// level, along with its uses in those packages, turning URLParser into urlParser.
+// Since uses from other packages would no longer compile, it first looks through
+// every package of the module in the current directory, named on the command line
+// or not, and stops with the list of them if any other package uses the name:
+//
+//	gorename-global --unexport Helper ./internal/util
+//
+// --export does the opposite, exporting a name where the packages named declare
+// it, along with its uses, with a leading initialism upper-cased as a whole, so
+// that httpClient becomes HTTPClient. It stops if such a package already declares
+// the new name.
+//
+// The --matcher-cmd flag starts a command that decides, for each candidate
+// identifier, whether to rename it and to what. The tool writes one JSON object
+// per line to the command's standard input, with the fields name, new (the name
+// the other flags would give it), kind, pos, decl (the enclosing top-level
+// declaration), confidence (as for --min-confidence) and, for pkg.Name, qualifier
+// (the import path of pkg). The command replies with one line per candidate,
+// either {"action":"skip"} or {"action":"rename","name":"NewName"}. Without --from
+// or --auto, every identifier is a candidate.
+//
+// Since renames go by names, some sites are less certain than others. Each
+// candidate identifier is scored from 0 to 1: a name selected from a value, whose
+// type isn't known, or from something that isn't clearly an imported package, and
+// a key in a composite literal, which may belong to any struct, score lower, as do
+// names of four letters or fewer, which unrelated things are more likely to share.
+// With --min-confidence, the identifiers that score lower than it are left alone
+// and listed in the summary for review by hand:
+//
+//	gorename-global --from ID --to Key --min-confidence 0.6 ./...
+//
+// In a file that dot-imports packages, an unqualified identifier may be declared
+// by one of them, which a rename by name alone can't tell. Each one that a rename
+// matches is listed in the summary to check, and is renamed by name all the same,
+// or, with a qualified --from, only if the last element of a dot-imported path is
+// the package named. With --resolve-dot-imports, the packages with such files are
+// type-checked, importing from source, and an identifier from a dot-imported
+// package is renamed only as a selector on the package would be: with an
+// unqualified --from, if the package is among those renamed in, and with a
+// qualified one, if it is the package named.
+//
+//	gorename-global --resolve-dot-imports --from models.User --to models.Account ./...
+//
+// A rename by name alone is least sure of a field or method selected from a value,
+// as in x.Name, since it can't know the value's type, and the field may be another
+// type's that shares the name. With --warn-selectors, each identifier renamed
+// where it is selected from something other than an imported package is listed in
+// the summary with what it is selected from, to check by hand.
+//
+//	gorename-global --warn-selectors --from Timeout --to Deadline ./...
+//
+// Renaming a package's name, as in --from util --to strutil, renames its package
+// clause and the references through it, but leaves the directory, and so the
+// import path, as it was. With --rename-dirs, each renamed package whose directory
+// is named after it is also moved to a directory of the new name, and the imports
+// of it, and of the packages under it, are updated throughout the module in the
+// current directory, keeping the package name the same as the last element of its
+// import path.
+//
+// Renaming to a name that is already declared redeclares it, unless --merge is
+// given, which merges the two: where a package declares both, the declaration of
+// whichever is an alias or forwarder of the other, such as a deprecated type
+// OldName = NewName or a func OldName() that only returns NewName(), is deleted
+// and the uses of -from are renamed. Declarations that are the same but for the
+// name merge too; any others are refused:
+//
+//	gorename-global --merge --from OldName --to NewName ./...
+//
+// With --calls, only the calls of -from that match a pattern are renamed, and its
+// other uses and its declaration are left alone, so that the calls of a
+// constructor overloaded by convention can be split between two new names. In the
+// pattern, _ is any argument, a last _... any number more, and any other
+// expression only that one:
+//
+//	gorename-global --from pkg.New --to NewPair --calls 'New(_, _)' ./...
+//	gorename-global --from pkg.New --to NewDefault --calls 'New(nil, _...)' ./...
+//
+// With --positions, exactly the identifiers at the positions a file lists are
+// renamed, and no others, for corrections worked out by other tools. Each line is
+// a position and a new name, or a line as the list command prints it, which also
+// says what the identifier must be called now; it is an error for a position to
+// have no identifier to rename:
+//
+//	other/other.go:4:6 NewName
+//	user/user.go:12:14: OldName -> NewName
+//
+// The suggest command looks for poorly named identifiers that the lint rules miss:
+// single-letter names at the top level of a package, names with commonly
+// misspelled words, such as recieveMsg, and names with Hungarian prefixes, such as
+// strName, pUser, and m_count. It prints better names as a --map file, with the
+// position and reason for each in a comment, leaving commented out those it has no
+// name for or whose name is taken, for review before renaming with it:
+//
+//	gorename-global suggest ./... > names.txt
+//	gorename-global --map names.txt ./...
+//
+// The move command moves a top-level declaration, with a type's methods, into a
+// new file named after it in another package of the module, qualifying,
+// unqualifying, and requalifying the references to it and fixing imports:
+//
+//	gorename-global move ./other.Old ./third ./...
+//
+// It refuses if the declaration refers to unexported names it would leave behind,
+// or if the move would make an import cycle.
+//
+// Packages are loaded the way the go command would build them: GOOS, GOARCH and
+// CGO_ENABLED come from the environment or go env, and build tags from -tags in
+// GOFLAGS, so files excluded from the build by their tags or file names are left
+// alone.
+//
+// With --all-platforms, each package is loaded once for every platform in
+// --platforms, a space-separated list of goos/goarch pairs, each optionally
+// followed by extra build tags as in "linux/amd64,integration", and the tool
+// renames in every file that any of them would build. This updates all the
+// platform-specific implementations of a function in one run.
+//
+// --only-main limits renaming to command packages, those named main, and
+// --skip-main to library packages, so that a rename of entry-point helpers under
+// cmd/ can be reviewed separately from the libraries. Both apply to packages, not
+// to files named on the command line.
+//
+// Files marked as generated with a "// Code generated ... DO NOT EDIT." comment are
+// left alone unless you pass --include-generated. When it is done, the tool prints
+// what it renamed, with how many identifiers in how many files, and what happened
+// to each file it skipped or failed to process;
+// --report=json prints the same summary, with every file's status, as JSON.
+// --report=gh-suggestions prints the changes instead as a JSON array of GitHub
+// pull request review comments, each suggesting one changed run of lines, ready
+// to post to the pull request review comments API, and leaves the files alone,
+// as --check does. Each comment is on the commit that --commit-id gives, the
+// pull request's head, or HEAD if it isn't given.
+//
+// --report=workspace-edit prints them as an LSP WorkspaceEdit, mapping the file
+// URI of each changed file to edits of whole lines, and leaves the files for the
+// editor or tool that applies it to change, as --check does. Positions count lines
+// from 0 and characters in UTF-16 code units, as LSP does by default.
+//
+// --report=idea-patch prints them instead as a patch that GoLand and the other
+// IntelliJ IDEs can load with Git | Patch | Apply Patch, to inspect the renames in
+// the IDE's preview before applying them, and leaves the files alone, as --check
+// does. Paths are relative to the top of the git work tree, and the patch's
+// subject and message, which the IDE offers as the commit message, list the
+// renames.
+//
+// --report=json-patch prints them as a JSON array with an RFC 6902 JSON Patch for
+// each changed file, taking the file as a JSON array of its lines, each with its
+// line ending, for tools that apply JSON Patch to apply without knowing Go. Each
+// patch tests every line it changes before changing it, and comes with the file's
+// path, relative to the top of the git work tree, and the SHA-256 of its original
+// contents, so that a patch for a file changed since fails rather than applying.
+// As with --check, the files are left alone.
+//
+// A renamed declaration's doc comment is updated if it starts with the old name,
+// as in "// OldName returns ..." or "// An OldName is ...", so that it still
+// starts with the name as linters expect. Other comments are left as they are, so
+// the summary also lists the comments that still use a renamed identifier's old
+// name as a whole word, with the line they are on, for their wording to be
+// reviewed; in the JSON summary they are under comment_mentions.
+//
+// --summary=package adds a breakdown by package, with how many files and
+// identifiers changed in each, so that the owners of the changed code can be
+// told.
+//
+// --color colors the summary, the errors and warnings, and the review screen's
+// changed lines: always, never, or auto, the default, which colors only what goes
+// to a terminal, unless NO_COLOR is set. The colors are SGR parameters that
+// GORENAME_GLOBAL_COLORS can change, as a colon-separated list of removed, added,
+// heading, failure and warning settings:
+//
+//	GORENAME_GLOBAL_COLORS='removed=35:heading=1;4' gorename-global review ./...
+//
+// By default, a package that can't be loaded or a file that can't be read,
+// parsed or written is logged, and the rest of the rename goes ahead. The summary
+// then lists it under Failed:, on standard error, and says that the results are
+// partial, and the exit status is 3.
+// --errors=fail-fast instead stops at the first such failure, before writing
+// anything more.
+//
+// With --check, nothing is written; the summary says what would be renamed. The
+// exit status is 0 if nothing needed renaming, 1 if something was renamed (or
+// would be, with --check), 2 for invalid flags or arguments, and 3 if any
+// package or file could not be processed.
+//
+// With --compat, each package whose exported declarations were renamed also
+// gets a deprecated.go that keeps the old names working for its importers:
+// type aliases, constants and variables set from the new names, and wrapper
+// functions and methods, each marked "Deprecated: Use New instead."
+//
+// --deprecate-only turns that around, for a gradual migration in which the code
+// using the old names can't be rewritten yet: nothing is renamed, and instead each
+// package whose exported declarations would have been gets a newnames.go declaring
+// the new names as aliases and wrappers of the old ones, which are marked
+// "Deprecated: Use New instead." in their doc comments. Other renames, such as
+// those of unexported names, are dropped. It can't be combined with the flags that
+// act on the renamed files, such as --compat, --verify or --migration-doc.
+//
+// Renaming exported identifiers breaks a module's importers. If the module in the
+// current directory has been released at v1 or later, going by its semver git tags
+// (or, for a module in a subdirectory, tags such as sub/v1.2.3), such a rename
+// stops with an error that suggests the next major version and its module path,
+// unless --breaking is given or --compat keeps the old names working. With
+// --check, or for a module still at v0, it only warns, suggesting the next minor
+// version.
+//
+// With --migration-doc=FILE, it also writes a Markdown table of the renamed
+// exported symbols of each package, linked to their documentation, for the
+// release notes of the packages' importers.
+//
+// With --api-map=FILE, it writes the same renames as versioned JSON, for
+// tools that update the code depending on the renamed packages.
+//
+// With --api-diff=FILE, it writes the change to each package's exported API in the
+// style of the apidiff tool: the old names of the renamed top-level declarations
+// and methods under "Incompatible changes", and their new names under "Compatible
+// changes", or only the latter with --compat. --api-diff=- prints the report
+// instead, before the summary, even with --check.
+//
+// With --changelog-entry=FILE, it adds an entry to a changelog such as
+// CHANGELOG.md, dated today, listing the renames and the packages they were made
+// in. The entry goes before the latest one, taken to be the first "## " heading,
+// or at the end if there is none, and the file is created if it is missing.
+// --changelog-entry=- prints the entry instead, before the summary.
+//
+// Diagnostics, such as files that could not be read, parsed or written, are
+// logged to standard error as they happen, as text or, with
+// --log-format=json, one JSON object per line. --log-level=debug also logs
+// what happened to every file.
+//
+// With --debug-trace, it logs why each candidate identifier was or was not
+// renamed: the new name, or the check that left it alone.
+//
+// Each file is written to a temporary file and renamed into place. On
+// interrupt, gorename-global stops starting work, lets the writes in
+// progress finish, prints what it did so far, and exits with status 3.
+//
+// A file that has changed since it was read, as when an editor saves it while a
+// --preview waits or a review goes on, is not written: it is reported as modified,
+// with exit status 3, so that the run can be repeated on what it is now. The
+// file's size and modification time are checked first, and its contents, by
+// SHA-256, only if they differ, so that a file that was only touched is still
+// renamed.
+//
+// So that two runs at once, such as a CI bot's and a person's, can't interleave
+// their writes, a run that writes files first takes a lock on each module it
+// writes to, by creating a .gorename-global.lock file at the module's root, or in
+// the file's directory outside any module, and removes it when done. If another
+// run holds one, it fails saying which, unless --lock-wait gives it a time to wait
+// for the lock; a lock left behind by a run on the same host that is no longer
+// running is taken over.
+//
+// With --resume=FILE, a run checkpoints its progress in FILE: as the files of each
+// package are all written, it adds the package's directory and the renames made
+// there. Run the same command again after an interruption or a failure, and the
+// packages listed are skipped, without being parsed again, while their renames
+// still count in the summary and the --changelog-entry. A checkpoint written by a
+// run with other arguments is refused, and one is removed once a run finishes with
+// nothing left to do. --resume doesn't go with --check or --verify, or the report
+// formats other than text and json.
+//
+//	gorename-global --resume /tmp/rename.ckpt --from Client --to Conn ./...
+//
+// Files whose lines mostly end in CRLF keep CRLF line endings.
+//
+// Rewritten files are laid out as gofmt would, unless --indent=spaces or
+// --tab-width say otherwise. With --editorconfig, the indent_style,
+// indent_size and tab_width of any .editorconfig files that apply are used
+// instead, though --indent and --tab-width still win when given.
+//
+// With --format=gofumpt, rewritten files are also passed through the gofumpt
+// command, which must be on the PATH.
+//
+// Mocks generated by gomock and mockery are renamed along with the
+// interfaces they mirror, so that tests keep compiling without regenerating
+// them.
+//
+// With --messages, the old names are also replaced where they appear as
+// whole words in the string literals passed to fmt.Errorf, errors.New, and
+// the log and slog functions. Each such edit is listed in the report.
+//
+// The preview command takes the same flags and arguments as a plain run, but
+// before writing anything it serves a page, at --http (localhost:8080 by
+// default), with each changed file's diff and a checkbox. Clicking Apply writes
+// the checked files, leaves the rest alone and lists them in the report:
+//
+//	gorename-global preview -http localhost:8080 -from Client -to Conn ./...
+//
+// Applying takes a token made afresh for each run, which only the page has,
+// and is refused from a page on any other site.
+//
+// The review command does the same in the terminal. It shows the changes full
+// screen, grouped by file. Space toggles the hunk or file under the cursor, a
+// toggles everything, and enter or q writes the chosen hunks. ^C quits without
+// writing anything. Since what is chosen isn't known until then, neither command
+// may be combined with --compat, --rename-dirs, --migration-doc, --api-map,
+// --api-diff, or --changelog-entry, which go by every rename made.
+//
+// The receivers command gives every method on a type the same receiver
+// name, renaming the receiver's uses too:
+//
+//	gorename-global receivers -type '*Server' -name s ./...
+//
+// Methods in which the new name is already used are left alone and listed
+// in the report. The flags that control how files are found, written and
+// reported apply as usual.
+//
+// The results command renames a named result of one function, which may be
+// a method written as Type.Method or (*Type).Method:
+//
+//	gorename-global results -func '(*Server).Close' -from err2 -to err ./...
+//
+// It leaves the result alone, and says so in the report, if the new name is
+// already used anywhere in the function, since that could change what a
+// naked return returns.
+//
+// The fields command renames one field of one struct type, type-checking the
+// named packages so that same-named fields of other structs, and their
+// composite literals, are left alone:
+//
+//	gorename-global fields -type UserRecord -from Name -to FullName ./...
+//
+// The type may be qualified with its package name, as in users.UserRecord.
+// If the type already has a field or method with the new name, nothing is
+// renamed and the clash is listed in the report.
+//
+// The methods command does the same for one method of one type, with its
+// calls, method values and method expressions, leaving same-named methods of
+// other types alone:
+//
+//	gorename-global methods -type Cache -from Get -to Fetch ./...
+//
+// Calls through an interface could be calls of any type's method, so if the
+// type may implement an interface with the method, nothing is renamed and the
+// interface is listed in the report.
+//
+// Statement labels are left alone by renames, since they don't share a
+// namespace with anything else. The labels command renames them, in one
+// function with -func or in all of them:
+//
+//	gorename-global labels -func Process -from retry -to outer ./...
+//
+// For several renames at once, -e takes a sed-style substitution and may be
+// repeated, or hold several separated by semicolons. Unlike sed, each one renames
+// whole identifiers only, so -e 's/Old/New/' leaves OldStyle alone:
+//
+//	gorename-global -e 's/Client/Conn/' -e 's/NewClient/Dial/' ./...
+//
+// For a longer list, such as one generated by diffing two versions of an API,
+// --map reads the renames from a file, one "old new" pair per line, with blank
+// lines and lines starting with # skipped. --map - reads them from standard input,
+// so that another tool can pipe its list straight in. The renames can be combined
+// with -e, but not with another kind of rename.
+//
+//	./list-renames.sh | gorename-global --map - ./...
+//
+// A --map file whose first line has a comma or a tab is read as CSV or TSV
+// instead, as exported by a spreadsheet or a deprecation tracker. Its columns are
+// the old name, the new one, and optionally a kind (const, var, type or func) and
+// a package pattern, such as example.com/api/..., limiting the rename to
+// identifiers of that kind declared in matching packages; a header row may name
+// the columns old, new, kind and package in any order, and other columns are
+// ignored. Identifiers whose kind or package can't be told, such as fields and
+// methods, follow any rule for their name, so that a field embedding a renamed
+// type is renamed with it.
+//
+//	old,new,kind,package
+//	Client,Conn,type,example.com/api/...
+//	NewClient,Dial,func,example.com/api/...
+//
+// With --companions, names derived from --from are renamed along with it.
+// Each comma-separated template has a {} that stands for the old or new
+// name, so --from Foo --to Bar --companions 'New{},{}Error,{}Impl' also
+// renames NewFoo, FooError, FooImpl and fooImpl.
+//
+// --to-template, instead of --to, makes each new name from the name it replaces.
+// {orig} stands for the name as it is, {exported} and {unexported} for it with
+// its first word capitalized or not, and {snake} and {SNAKE} for it in lower or
+// upper snake_case, so --from Client --to-template '{orig}Legacy' renames Client
+// to ClientLegacy and, with --companions 'New{}', NewClient to NewClientLegacy.
+//
+// With --record=FILE, the flags and arguments of a run are saved to a
+// script, one per line, and --play=FILE runs the same rename again later,
+// perhaps on another branch. Flags and arguments given with --play override
+// the script's.
+//
+// The replay command applies the renames listed in a --report=json summary
+// to another checkout, such as a maintenance branch, renaming every
+// identifier with one of the old names as an unqualified --from would:
+//
+//	gorename-global replay -root ../release-branch changes.json
+//
+// With --git-conflicts, it first lists the files it is about to change that
+// other local branches, or those named by --branches, have also changed
+// since they diverged, since merging them will likely conflict. Combine it
+// with --check to see the forecast without renaming.
+//
+// Work on files is bounded across all packages: -j sets how many are read,
+// parsed or written at once, and --max-memory the rough number of megabytes
+// they may take, so that large trees don't exhaust memory or file
+// descriptors.
+//
+// Even so, every parsed file is held until the end of the run. With --batch,
+// packages are loaded in batches that fit in --max-memory instead, and each batch
+// is renamed and written before the next is loaded, so that a module-wide run fits
+// on a laptop. Since each batch is renamed on its own, --batch refuses what needs
+// every file at once: --auto, a qualified --from, renames limited by kind or
+// package, --enum-prefix, --prefix-exported, --unexport, --export,
+// --deprecate-only, the preview and review commands, --verify, --regenerate,
+// --compat, --migration-doc, --api-map, --api-diff, --git-conflicts, and
+// --rename-dirs. In a module released at v1 or later it needs --breaking, since it
+// can't check that no exported name changes before writing the first batch.
+// Combine it with --resume to carry on from the last batch written.
+//
+// When a run renames only identifiers named in advance, by --from, -e or --map, a
+// file that doesn't mention any of the names, in any case, can't change, so it is
+// parsed faster, without its comments or resolving its identifiers, and only
+// consulted for what it declares. Files that may change are parsed in full, since
+// they are printed from their syntax trees, comments and all.
+//
+// With --include, only files whose names match one of the comma-separated
+// patterns, such as '*_handler.go', are rewritten. A pattern with a slash
+// is matched against the whole path. The other files are still read, so
+// that conflicts with their names are found.
+//
+// Where packages don't resolve, as in a tree with a broken module setup, a
+// vendored copy, or a staging area of generated code, --walk renames in every .go
+// file in the directory trees named, or the current one, without consulting
+// go/build or the go command: build constraints, testdata, and directories
+// starting with . or _ are all taken in, and import paths are left out of the
+// reports. Each file is renamed on its own, as with --files-mode.
+//
+//	gorename-global --walk --from OldName --to NewName ./staging
+//
+// With --files-mode or --walk, files and directories that .gitignore files
+// exclude are left alone, as are vendor, third_party, and node_modules
+// directories, and those of version control, so that build outputs and copies
+// of other code aren't rewritten by accident. A directory named as an argument
+// is always walked. Pass --include-ignored to rename in all of them.
+//
+// A file reached by more than one path, through symlinks, is renamed only
+// once, as is a package that more than one pattern names, such as ./... and
+// ./sub, or a directory and its import path, which is also loaded only once.
+// With --stay-in-root, files that symlinks lead to outside the module
+// root, or outside the current directory if there is no go.mod, are
+// reported rather than renamed.
+//
+// With --manifest=FILE, the same rename is run in each repository listed in
+// the file, one root per line, relative to the file. Each gets its own
+// summary. --patch-dir=DIR writes each repository's changes, including the
+// files the run created, to DIR/repo-HASH.patch, where HASH is of the
+// repository's whole path, and --commit=MESSAGE commits them.
+//
+// With --pr-repo, the rename is made in another repository, cloned from the
+// given URL unless it is a local checkout. The change is committed to the
+// new branch --pr-branch and pushed, and a pull request is opened against
+// --pr-base, or the default branch, with the summary as its description.
+// GitHub and GitLab are supported, with a token in GITHUB_TOKEN or
+// GITLAB_TOKEN. The GitLab token is sent only to gitlab.com or to the server
+// that GITLAB_HOST names. A local checkout must have no uncommitted changes,
+// and a clone is removed when the run is done.
+//
+// To leave the tree as it is and write the renamed files somewhere else, use the
+// --output-dir flag. Each changed file is written to the same place under the
+// given directory as it has under the current one, with its permissions, and the
+// directories are created as needed; files that don't change aren't copied. It
+// can't be combined with --verify or --regenerate, which work on the tree in
+// place.
+//
+//	gorename-global --from Old --to New --output-dir ./renamed ./...
+//
 // You can use the --auto flag to fix any identifier that 'go lint' would flag.
+// To apply only some of the fixes, name the rules, as in --auto=underscores or
+// --auto=initialisms.
+//
+// To follow your linter's naming configuration rather than go lint's, give
+// --lint-config a revive.toml or staticcheck.conf. The initialisms are then those
+// it lists, as the arguments of revive's var-naming rule or staticcheck's
+// initialisms, and if it doesn't check names at all, with var-naming or ST1003
+// disabled, the underscores and initialisms rules are dropped:
+//
+//	gorename-global --auto --lint-config revive.toml ./...
+//
+// Beyond the lint rules, --auto=spelling corrects the words of identifiers that
+// are common misspellings, such as recieve and seperate, in the case of the words
+// they replace, so that recieveMsg becomes receiveMsg and MAX_LENGHT MAX_LENGTH.
+// As with the other rules, a correction whose new name is already declared in the
+// same scope is skipped and reported as a conflict.
+//
+// With --spelling=US or --spelling=UK, the words of identifiers are respelled the
+// American or British way, splitting them into words as the lint rules do, so that
+// with US, initialiseColours becomes initializeColors and Behaviour Behavior. It
+// may be combined with --auto, and is also among its rules, as us-spelling and
+// uk-spelling:
+//
+//	gorename-global --spelling=US ./...
+//
+// The hungarian rule, --auto=hungarian, strips the prefixes of Hungarian notation,
+// renaming strName to name, pUser to user, and m_count to count, unless the
+// remainder is already taken. A prefix without an underscore must be followed by a
+// capitalized word, so pure and pURL are left alone. The prefixes are listed by
+// --hungarian-prefixes, which the suggest command also follows:
+//
+//	gorename-global --auto=hungarian --hungarian-prefixes=str,obj,p,m_ ./...
+//
+// The -auto rules are also available as a golangci-lint module plugin, in the
+// golangci directory, which reports what they would rename and fixes it with
+// golangci-lint --fix. Its package documentation says how to build it into
+// golangci-lint and configure it.
 package main
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
-	"go/ast"
 	"go/build"
-	"go/parser"
-	"go/printer"
 	"go/token"
+	"io/fs"
+	"log/slog"
 	"os"
+	"os/exec"
+	"os/signal"
 	"path/filepath"
+	"runtime"
+	"sort"
 	"strings"
 	"sync"
-	"unicode"
 
 	"go4.org/syncutil"
 
+	"github.com/jeremyschlatter/gorename-global/rename"
 	"github.com/kisielk/gotool"
 )
 
 var (
 	from = flag.String("from", "", "the current name")
 	to   = flag.String("to", "", "the new name")
-	auto = flag.Bool("auto", false, "automatically change any identifier flagged by 'go lint'")
+	auto autoFlag
+
+	exprs exprFlag
+
+	logLevel slog.Level
+
+	exportedOnly = flag.Bool("exported-only", false, "with -auto, only change exported identifiers")
+
+	word       = flag.Bool("word", false, "match -from against the camelCase words of identifiers")
+	plurals    = flag.Bool("plurals", false, "also rename the plural of -from to the plural of -to")
+	pluralsOf  = flag.String("plural-overrides", "", "comma-separated `singular=plural` pairs for -plurals, such as person=people")
+	companions = flag.String("companions", "", "comma-separated `templates` for names derived from -from to rename with it, such as New{},{}Error,{}Impl")
+	include    = flag.String("include", "", "comma-separated `patterns`, such as *_handler.go, limiting the files to rewrite")
+	toTemplate = flag.String("to-template", "", "instead of -to, make each new name from the one it replaces with this `template`, such as {orig}Legacy or {snake}")
+	ignoreCase = flag.Bool("ignore-case", false, "match -from regardless of case, keeping each match's exportedness")
+
+	regenerate     = flag.Bool("regenerate", false, "run 'go generate' in each changed package after renaming")
+	matcherCmd     = flag.String("matcher-cmd", "", "consult this command about each candidate identifier")
+	compat         = flag.Bool("compat", false, "write deprecated forwarders from renamed exported declarations to their new names")
+	migration      = flag.String("migration-doc", "", "write a Markdown table of the renamed exported symbols to this file")
+	apiMapPath     = flag.String("api-map", "", "write the renamed exported symbols to this file as JSON, for tools that update dependent code")
+	messages       = flag.Bool("messages", false, "also rename words in fmt.Errorf, errors.New, and log message strings")
+	indent         = flag.String("indent", "tabs", "indent rewritten files with tabs or spaces")
+	tabWidth       = flag.Int("tab-width", 8, "the width of an indent")
+	formatter      = flag.String("format", "", "also pass rewritten files through this formatter: gofumpt")
+	editorCfg      = flag.Bool("editorconfig", false, "take -indent and -tab-width from .editorconfig files, unless set explicitly")
+	check          = flag.Bool("check", false, "report what would be renamed without changing any files")
+	generated      = flag.Bool("include-generated", false, "also rename in generated files")
+	recordPath     = flag.String("record", "", "write the flags and arguments of this run to this script `file`")
+	playPath       = flag.String("play", "", "take flags and arguments from this script `file`, written by -record, unless given on the command line")
+	reportFmt      = flag.String("report", "text", "summary format: text, json, gh-suggestions, workspace-edit, idea-patch, or json-patch")
+	commitID       = flag.String("commit-id", "", "with -report=gh-suggestions, the `sha` of the pull request's head commit, which each comment is on; HEAD by default")
+	debugTrace     = flag.Bool("debug-trace", false, "log why each candidate identifier was or was not renamed")
+	logFormat      = flag.String("log-format", "text", "diagnostics format: text or json")
+	stayInRoot     = flag.Bool("stay-in-root", false, "refuse to follow symlinks to files outside the module root, or the current directory outside any module")
+	filesMode      = flag.Bool("files-mode", false, "treat arguments as directories of independent .go files rather than packages")
+	includeIgnored = flag.Bool("include-ignored", false, "with -files-mode or -walk, also rename in what .gitignore excludes, and in vendor, third_party, node_modules, and version control directories")
+	walk           = flag.Bool("walk", false, "rename in every .go file in the directory trees named, without loading packages or consulting go/build: for trees whose packages don't resolve")
+	verify         = flag.String("verify", "", "if \"test\", test each changed package after renaming, and restore the original files if the tests fail")
+
+	jobs      = flag.Int("j", 4*runtime.GOMAXPROCS(0), "the most files to read, parse, or write at once")
+	maxMemory = flag.Int("max-memory", 1024, "roughly the most `megabytes` to spend on files being read, parsed, or written at once")
+
+	manifest  = flag.String("manifest", "", "rename in each repository listed in this `file`, one root per line")
+	patchDir  = flag.String("patch-dir", "", "with -manifest, write each repository's changes to a patch in this directory")
+	commitMsg = flag.String("commit", "", "with -manifest, commit each repository's changes with this message")
+
+	prRepo   = flag.String("pr-repo", "", "rename in this repository, cloned unless it is a local checkout, and open a pull request with the change")
+	prBranch = flag.String("pr-branch", "", "with -pr-repo, the branch to push the change to")
+	prBase   = flag.String("pr-base", "", "with -pr-repo, the branch to merge the change into; by default the remote's default branch")
+	prTitle  = flag.String("pr-title", "", "with -pr-repo, the title of the commit and pull request")
+
+	allPlatforms = flag.Bool("all-platforms", false, "rename in the files that any platform in -platforms would build, not just the current one")
+	platforms    = flag.String("platforms", defaultPlatforms, "with -all-platforms, the space-separated `matrix` of goos/goarch[,tag...] combinations")
+
+	onlyMain = flag.Bool("only-main", false, "only rename in command packages, those named main")
+	skipMain = flag.Bool("skip-main", false, "only rename in library packages, those not named main")
+
+	minConfidence = flag.Float64("min-confidence", 0, "leave alone, and list for review, the identifiers whose rename scores lower than this, from 0 to 1, by how sure a rename by name alone can be")
+	resolveDots   = flag.Bool("resolve-dot-imports", false, "type-check the packages of files with dot imports, so that unqualified identifiers from the dot-imported packages are renamed only as selectors on them would be")
+	warnSelectors = flag.Bool("warn-selectors", false, "list the identifiers renamed where they are selected from something other than an imported package, as fields and methods of values whose type a rename by name can't know")
+
+	apiDiff = flag.String("api-diff", "", "write the change to each package's exported API, as apidiff would report it, to this `file`, or print it if -")
+
+	breaking = flag.Bool("breaking", false, "go ahead with renaming exported identifiers in a module released at v1 or later, which breaks its API")
+
+	changelog = flag.String("changelog-entry", "", "add an entry listing the renames and the packages they were made in to this changelog `file`, such as CHANGELOG.md, or print it if -")
+
+	deprecateOnly = flag.Bool("deprecate-only", false, "rather than renaming, add the new names of exported declarations alongside the old ones, and mark the old ones deprecated")
+
+	mapPath = flag.String("map", "", "rename whole identifiers as this `file` says, one \"old new\" pair per line, or - to read the pairs from standard input")
+
+	colorMode = flag.String("color", "auto", "color the review screen, summary, and errors: always, never, or auto, for only on a terminal unless NO_COLOR is set")
+
+	enumPrefix = flag.String("enum-prefix", "", "rename the constants of const blocks whose names start with this `prefix`, such as Color in ColorRed, to start with -to instead")
+
+	prefixExported = flag.String("prefix-exported", "", "put this `prefix` in front of every exported name declared at the top level of the packages named, as before folding them into another")
+
+	unexportName = flag.String("unexport", "", "unexport this `name` where the packages named declare it, first checking that no other package in the module uses it")
+
+	exportName = flag.String("export", "", "export this `name` where the packages named declare it, as initialisms are, so that httpClient becomes HTTPClient")
+
+	renameDirs = flag.Bool("rename-dirs", false, "when a package is renamed, also move its directory, if named after it, to the new name, and update the module's imports of it")
+
+	merge = flag.Bool("merge", false, "with -from and -to, merge -from into -to where a package already declares both, deleting whichever is an alias or forwarder of the other, or the same but for the name, rather than declaring -to twice")
+
+	callsPattern = flag.String("calls", "", "with -from and -to, rename only the calls of -from that match this `pattern`, such as New(_, _), where _ is any argument and a last _... any more of them")
+
+	positionsPath = flag.String("positions", "", "rename exactly the identifiers at the positions this `file` lists, one \"path:line:col new-name\" per line, or as the list command prints them, or - to read them from standard input")
+
+	spelling = flag.String("spelling", "", "respell the words of identifiers the American or British way, US or UK, so that Colour becomes Color with US, along with any -auto rules")
+
+	hungarian = flag.String("hungarian-prefixes", strings.Join(rename.HungarianPrefixes, ","), "the comma-separated `prefixes` that -auto=hungarian and the suggest command take for Hungarian notation")
+
+	lintConfigPath = flag.String("lint-config", "", "with -auto, follow the naming rules of this revive.toml or staticcheck.conf `file`: its initialisms, and whether it checks names at all")
+
+	batch = flag.Bool("batch", false, "load, rename, and write the packages in batches that fit in -max-memory, rather than holding every file until the end")
+
+	resumePath = flag.String("resume", "", "record the packages done in this checkpoint `file`, and skip those it already lists, to carry on with a run that was interrupted")
+
+	lockWait = flag.Duration("lock-wait", 0, "if another run is writing to a module this one would write to, wait this long for it to finish rather than failing at once")
+
+	outputDir = flag.String("output-dir", "", "write changed files to the same places under this `directory` rather than in place")
+
+	errorsMode = flag.String("errors", "collect", "on a package or file error, fail-fast to stop the run, or collect to carry on and report every error at the end")
+
+	summaryBy = flag.String("summary", "", "if \"package\", also break the summary down by package, for telling the owners of changed code")
+
+	httpAddr = flag.String("http", "localhost:8080", "with the preview command, the address to serve the preview on")
+
+	gitConflicts = flag.Bool("git-conflicts", false, "before writing, list the changed files that other local branches have changed too")
+	branches     = flag.String("branches", "", "with -git-conflicts, the comma-separated branches to check, rather than all local ones")
 )
 
+func init() {
+	flag.Var(&auto, "auto", "automatically change any identifier flagged by 'go lint'; or =`rules`, a comma-separated list of "+
+		strings.Join(rename.AllRules, ", ")+", or all")
+	flag.Var(&exprs, "e", "rename whole identifiers as this sed-style `s/Old/New/` says; may be repeated")
+	flag.TextVar(&logLevel, "log-level", slog.LevelInfo, "the least severe diagnostics to show: debug, info, warn, or error")
+}
+
+// autoFlag is the -auto flag. Alone, it selects the lint rules. It also
+// accepts a comma-separated list of rules, or "all".
+type autoFlag struct {
+	set   bool
+	rules []string
+}
+
+func (a *autoFlag) String() string { return strings.Join(a.rules, ",") }
+
+func (a *autoFlag) IsBoolFlag() bool { return true }
+
+func (a *autoFlag) Set(s string) error {
+	a.rules = nil
+	switch s {
+	case "false":
+		a.set = false
+		return nil
+	case "true":
+		a.set = true
+		a.rules = rename.LintRules
+		return nil
+	}
+	a.set = true
+	for _, rule := range strings.Split(s, ",") {
+		switch rule {
+		case "all":
+			a.rules = append(a.rules, rename.AllRules...)
+		case "lint":
+			a.rules = append(a.rules, rename.LintRules...)
+		default:
+			a.rules = append(a.rules, rule)
+		}
+	}
+	return nil
+}
+
+// exprFlag is the -e flag, which may be repeated. Each value is one or more
+// sed-style substitutions, s/Old/New/, separated by semicolons. Any
+// character may stand in for the slashes.
+type exprFlag []pair
+
+func (e *exprFlag) String() string {
+	var ss []string
+	for _, p := range *e {
+		ss = append(ss, "s/"+p.From+"/"+p.To+"/")
+	}
+	return strings.Join(ss, ";")
+}
+
+func (e *exprFlag) Set(s string) error {
+	for _, x := range strings.Split(s, ";") {
+		x = strings.TrimSpace(x)
+		if len(x) < 2 || x[0] != 's' {
+			return fmt.Errorf("%q is not of the form s/Old/New/", x)
+		}
+		parts := strings.Split(x[2:], x[1:2])
+		if len(parts) != 3 || parts[2] != "" {
+			return fmt.Errorf("%q is not of the form s/Old/New/", x)
+		}
+		if !token.IsIdentifier(parts[0]) || !token.IsIdentifier(parts[1]) {
+			return fmt.Errorf("%q: both names must be identifiers", x)
+		}
+		*e = append(*e, pair{From: parts[0], To: parts[1]})
+	}
+	return nil
+}
+
+// changeLog holds each old -> new rename made, with how often it was made.
 var changeLog = struct {
 	sync.Mutex
-	m map[[2]string]bool
+	m map[[2]string]pair
 }{
-	m: make(map[[2]string]bool),
+	m: make(map[[2]string]pair),
 }
 
 func main() {
+	if len(os.Args) > 1 {
+		if c := lookupCommand(os.Args[1]); c != nil {
+			c.main(os.Args[2:])
+			return
+		}
+	}
 	flag.Parse()
-	if *auto && (*from != "" || *to != "") ||
-		!*auto && (*from == "" || *to == "") {
-		fmt.Fprintf(os.Stderr, "Usage: %s [-from <name> -to <name>] [-auto] [pkg...]\n", os.Args[0])
-		os.Exit(1)
-	}
-	paths := gotool.ImportPaths(flag.Args())
-	var wg syncutil.Group
-	for _, p := range paths {
-		p := p
-		wg.Go(func() error {
-			return renameIn(p)
-		})
+	renameMain(flag.Args(), nil)
+}
+
+// renameMain runs a plain rename of the files named by args, as the
+// flags say, along with the renames given by a command such as undo.
+func renameMain(args []string, given []renameRule) {
+	if *playPath != "" {
+		var err error
+		if args, err = playScript(*playPath, args); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(exitUsage)
+		}
 	}
-	if errs := wg.Errs(); errs != nil {
-		for _, err := range errs {
+	setupColor(*colorMode)
+	setupLog(*logFormat, logLevel)
+	if *manifest != "" {
+		runManifest(*manifest, args)
+	}
+	if *prRepo != "" {
+		runPR(args)
+		return
+	}
+	switch *spelling {
+	case "":
+	case "US", "UK":
+		if !auto.set {
+			auto.set, auto.rules = true, nil
+		}
+		auto.rules = append(auto.rules, strings.ToLower(*spelling)+"-spelling")
+	default:
+		usage()
+	}
+	var initialisms []string
+	if *lintConfigPath != "" {
+		if !auto.set {
+			usage()
+		}
+		cfg, err := readLintConfig(*lintConfigPath)
+		if err != nil {
+			exitOnErr([]error{err})
+		}
+		if cfg.disabled {
+			// Names the linter doesn't check need no fixing for it.
+			var rs []string
+			for _, r := range auto.rules {
+				if r != rename.RuleUnderscores && r != rename.RuleInitialisms {
+					rs = append(rs, r)
+				}
+			}
+			if len(rs) == 0 {
+				logger.Info("the lint configuration doesn't check names, so there is nothing to rename", "config", *lintConfigPath)
+				os.Exit(exitUnchanged)
+			}
+			auto.rules = rs
+		}
+		initialisms = cfg.initialisms
+	}
+	rules := 0
+	if auto.set {
+		rules++
+	}
+	if *enumPrefix != "" {
+		if *from != "" || !token.IsIdentifier(*to) || *toTemplate != "" || *matcherCmd != "" {
+			usage()
+		}
+		rules++
+	} else if *from != "" || *to != "" || *toTemplate != "" {
+		if *from == "" || (*to == "") == (*toTemplate == "") || *toTemplate != "" && *word {
+			usage()
+		}
+		rules++
+	}
+	if *merge && (*from == "" || *to == "" || *enumPrefix != "" || *word || *ignoreCase || *plurals || *companions != "") {
+		usage()
+	}
+	var calls *callPattern
+	if *callsPattern != "" {
+		if *from == "" || *enumPrefix != "" || *merge || *word || *ignoreCase || *plurals || *companions != "" {
+			usage()
+		}
+		var err error
+		if calls, err = parseCallPattern(*callsPattern, *from); err != nil {
 			fmt.Fprintln(os.Stderr, err)
+			os.Exit(exitUsage)
+		}
+	}
+	if len(exprs) > 0 || *mapPath != "" || len(given) > 0 {
+		rules++
+	}
+	if *prefixExported != "" {
+		if !token.IsIdentifier(*prefixExported) || !token.IsExported(*prefixExported) {
+			usage()
+		}
+		rules++
+	}
+	if *unexportName != "" {
+		if !token.IsIdentifier(*unexportName) || !token.IsExported(*unexportName) {
+			usage()
 		}
-		os.Exit(1)
+		rules++
+	}
+	if *exportName != "" {
+		if !token.IsIdentifier(*exportName) || token.IsExported(*exportName) {
+			usage()
+		}
+		rules++
+	}
+	if *positionsPath != "" {
+		rules++
+	}
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "matcher-cmd" && strings.TrimSpace(*matcherCmd) == "" {
+			usage() // no command to run
+		}
+	})
+	if rules > 1 || rules == 0 && *matcherCmd == "" || (len(exprs) > 0 || *mapPath != "" || len(given) > 0 || *prefixExported != "" || *unexportName != "" || *exportName != "" || *positionsPath != "") && *matcherCmd != "" || *exportedOnly && !auto.set {
+		usage()
+	}
+	if *minConfidence < 0 || *minConfidence > 1 || *resumePath != "" && (*check || *verify != "" || *reportFmt != "text" && *reportFmt != "json") {
+		usage()
+	}
+	if *deprecateOnly && (*compat || *verify != "" || *regenerate || *migration != "" || *apiMapPath != "" || *apiDiff != "" || *gitConflicts ||
+		previewing || reviewing || *reportFmt == "gh-suggestions" || *reportFmt == "workspace-edit" || *reportFmt == "idea-patch" || *reportFmt == "json-patch") {
+		usage()
+	}
+	// What the preview and review commands leave out is only known once
+	// the files are written, too late for the steps that go by every rename.
+	if (previewing || reviewing) && (*compat || *renameDirs || *migration != "" || *apiMapPath != "" || *apiDiff != "" || *changelog != "") {
+		usage()
+	}
+	checkOutputFlags()
+	renames := given
+	for _, p := range exprs {
+		renames = append(renames, renameRule{From: p.From, To: p.To})
+	}
+	if *mapPath != "" {
+		ps, err := readRenameMap(*mapPath)
+		if err != nil {
+			exitOnErr([]error{err})
+		}
+		renames = append(renames, ps...)
+	}
+	checkBatch(renames)
+	parseNames = renamedNames(renames)
+	var positions *positionList
+	if *positionsPath != "" {
+		var err error
+		if positions, err = readPositions(*positionsPath); err != nil {
+			exitOnErr([]error{err})
+		}
+	}
+	setupPool(*jobs, *maxMemory)
+	if *recordPath != "" {
+		if err := recordScript(*recordPath, args); err != nil {
+			exitOnErr([]error{err})
+		}
+	}
+	if *resumePath != "" {
+		if err := openCheckpoint(*resumePath); err != nil {
+			exitOnErr([]error{err})
+		}
+	}
+	// On interrupt, stop starting work, but let writes in progress finish,
+	// so that no file is left half written.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	ctx = withErrorPolicy(ctx)
+	var files []*rename.File
+	if !*batch {
+		files = load(ctx, args)
+	}
+	if *enumPrefix != "" {
+		rs, err := enumRenames(files)
+		if err != nil {
+			exitOnErr([]error{err})
+		}
+		renames = append(renames, rs...)
+	}
+	if *prefixExported != "" {
+		renames = append(renames, prefixRenames(files)...)
+	}
+	if *unexportName != "" {
+		rs, err := topLevelRenames(files, *unexportName, rename.Unexport(*unexportName))
+		if err != nil {
+			exitOnErr([]error{err})
+		}
+		checkUnexport(files)
+		renames = append(renames, rs...)
+	}
+	if *exportName != "" {
+		rs, err := exportRenames(files)
+		if err != nil {
+			exitOnErr([]error{err})
+		}
+		renames = append(renames, rs...)
+	}
+	opts := rename.Options{
+		From:         *from,
+		To:           *to,
+		ToTemplate:   *toTemplate,
+		Auto:         auto.set,
+		AutoRules:    auto.rules,
+		ExportedOnly: *exportedOnly,
+		Word:         *word,
+		Plurals:      *plurals,
+		IgnoreCase:   *ignoreCase,
+		Generated:    *generated,
+		Messages:     *messages,
+	}
+	if *enumPrefix != "" {
+		opts.To = "" // it replaces the prefix, in the renames
+	}
+	opts.MinConfidence = *minConfidence
+	opts.ResolveDotImports = *resolveDots
+	opts.WarnSelectors = *warnSelectors
+	opts.HungarianPrefixes = hungarianPrefixes()
+	opts.Initialisms = initialisms
+	if calls != nil {
+		opts.Filter = calls.filter
+	}
+	if listing {
+		opts.OnChange = listSite
+	}
+	if *include != "" {
+		opts.Include = strings.Split(*include, ",")
+	}
+	if *companions != "" {
+		opts.Companions = strings.Split(*companions, ",")
+	}
+	if *pluralsOf != "" {
+		opts.PluralOverrides = make(map[string]string)
+		for _, kv := range strings.Split(*pluralsOf, ",") {
+			i := strings.Index(kv, "=")
+			if i < 0 {
+				usage()
+			}
+			opts.PluralOverrides[strings.ToLower(kv[:i])] = kv[i+1:]
+		}
+	}
+	if *debugTrace {
+		opts.Trace = func(pos, name, reason string) {
+			logger.Info("trace", "pos", pos, "name", name, "reason", reason)
+		}
+	}
+	if positions != nil {
+		opts.Match = positions.match
 	}
-	if len(changeLog.m) > 0 {
-		fmt.Println("Changed:")
-		for k := range changeLog.m {
-			fmt.Printf("\t%s -> %s\n", k[0], k[1])
+	var m *matcher
+	if *matcherCmd != "" {
+		var err error
+		if m, err = startMatcher(*matcherCmd); err != nil {
+			exitOnErr([]error{err})
 		}
+		opts.Match = m.decide
+	}
+	renameFiles := func(files []*rename.File) error {
+		if *merge {
+			if err := rename.Merge(files, *from, *to); err != nil {
+				return err
+			}
+		}
+		opts := opts
+		if len(renames) > 0 {
+			match, err := matchRules(renames, files)
+			if err != nil {
+				return err
+			}
+			opts.Match = match
+		}
+		return rename.Rename(files, opts)
+	}
+	var errs []error
+	if *batch {
+		renameBatches(ctx, args, renameFiles)
+	} else if err := renameFiles(files); err != nil {
+		errs = append(errs, err)
+	}
+	if m != nil {
+		if err := m.close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if positions != nil && len(errs) == 0 {
+		errs = positions.unused()
+	}
+	exitOnErr(errs)
+	if *deprecateOnly {
+		// The renamed files are not written; finish only reports on what
+		// deprecate wrote.
+		deprecate(ctx, files)
+		files = nil
+	}
+	finish(ctx, files)
+}
+
+// receiversCommand adds the flags of the receivers command, which gives the
+// receivers of a type's methods a single name, to fs, and returns what runs
+// it.
+func receiversCommand(fs *flag.FlagSet) func(args []string) {
+	typ := fs.String("type", "", "the type whose methods to change, such as *Server")
+	name := fs.String("name", "", "the new receiver name")
+	return func(args []string) {
+		if *typ == "" || !token.IsIdentifier(*name) {
+			usage()
+		}
+		runSubcommand(args, func(files []*rename.File) error {
+			return rename.RenameReceivers(files, *typ, *name)
+		})
+	}
+}
+
+// fieldsCommand adds the flags of the fields command, which renames a field
+// of one struct type, to fs, and returns what runs it.
+func fieldsCommand(fs *flag.FlagSet) func(args []string) {
+	typ := fs.String("type", "", "the struct type, such as UserRecord or users.UserRecord")
+	from := fs.String("from", "", "the current field name")
+	to := fs.String("to", "", "the new field name")
+	return func(args []string) {
+		if *typ == "" || *from == "" || !token.IsIdentifier(*to) {
+			usage()
+		}
+		runSubcommand(args, func(files []*rename.File) error {
+			return rename.RenameField(files, *typ, *from, *to)
+		})
+	}
+}
+
+// methodsCommand adds the flags of the methods command, which renames a
+// method of one type, to fs, and returns what runs it.
+func methodsCommand(fs *flag.FlagSet) func(args []string) {
+	typ := fs.String("type", "", "the type whose method to rename, such as Cache or store.Cache")
+	from := fs.String("from", "", "the current method name")
+	to := fs.String("to", "", "the new method name")
+	return func(args []string) {
+		if *typ == "" || *from == "" || !token.IsIdentifier(*to) {
+			usage()
+		}
+		runSubcommand(args, func(files []*rename.File) error {
+			return rename.RenameMethod(files, strings.TrimPrefix(*typ, "*"), *from, *to)
+		})
+	}
+}
+
+// resultsCommand adds the flags of the results command, which renames a
+// named result of one function, to fs, and returns what runs it.
+func resultsCommand(fs *flag.FlagSet) func(args []string) {
+	fn := fs.String("func", "", "the function, such as Open or (*Server).Close")
+	from := fs.String("from", "", "the current result name")
+	to := fs.String("to", "", "the new result name")
+	return func(args []string) {
+		if *fn == "" || *from == "" || !token.IsIdentifier(*to) {
+			usage()
+		}
+		runSubcommand(args, func(files []*rename.File) error {
+			return rename.RenameResults(files, *fn, *from, *to)
+		})
+	}
+}
+
+// labelsCommand adds the flags of the labels command, which renames a
+// statement label, to fs, and returns what runs it.
+func labelsCommand(fs *flag.FlagSet) func(args []string) {
+	fn := fs.String("func", "", "the function, such as Open or (*Server).Close; all functions if empty")
+	from := fs.String("from", "", "the current label")
+	to := fs.String("to", "", "the new label")
+	return func(args []string) {
+		if *from == "" || !token.IsIdentifier(*to) {
+			usage()
+		}
+		runSubcommand(args, func(files []*rename.File) error {
+			return rename.RenameLabels(files, *fn, *from, *to)
+		})
 	}
 }
 
-func renameIn(pkgPath string) error {
-	printerConf := printer.Config{
-		Mode:     printer.UseSpaces | printer.TabIndent,
-		Tabwidth: 8,
+// replayCommand adds the flags of the replay command, which applies the
+// renames listed in a -report=json summary to another checkout, to fs, and
+// returns what runs it.
+func replayCommand(fs *flag.FlagSet) func(args []string) {
+	root := fs.String("root", ".", "the checkout to apply the renames to")
+	return func(args []string) {
+		if len(args) < 1 {
+			usage()
+		}
+		renames, err := readChangeLog(args[0])
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(exitUsage)
+		}
+		if err := os.Chdir(*root); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(exitUsage)
+		}
+		pkgs := args[1:]
+		if len(pkgs) == 0 {
+			pkgs = []string{"./..."}
+		}
+		runSubcommand(pkgs, func(files []*rename.File) error {
+			return rename.Rename(files, rename.Options{
+				Generated: *generated,
+				Match: func(c rename.Candidate) (string, error) {
+					if n, ok := renames[c.Name]; ok {
+						return n, nil
+					}
+					return c.Name, nil
+				},
+			})
+		})
 	}
-	pkg, err := build.Import(pkgPath, ".", 0)
+}
+
+// readChangeLog reads the renames from a -report=json summary.
+func readChangeLog(path string) (map[string]string, error) {
+	b, err := os.ReadFile(path)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	var wg syncutil.Group
+	var s summary
+	if err := json.Unmarshal(b, &s); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	renames := make(map[string]string)
+	for _, p := range s.Changed {
+		if n, ok := renames[p.From]; ok && n != p.To {
+			return nil, fmt.Errorf("%s: %s is renamed to both %s and %s", path, p.From, n, p.To)
+		}
+		renames[p.From] = p.To
+	}
+	return renames, nil
+}
 
-	files := append(pkg.GoFiles, pkg.TestGoFiles...)
-	files = append(files, pkg.XTestGoFiles...)
+// runSubcommand loads the files named by args, changes them with do, and
+// finishes as the top-level command does.
+func runSubcommand(args []string, do func([]*rename.File) error) {
+	setupColor(*colorMode)
+	setupLog(*logFormat, logLevel)
+	checkOutputFlags()
+	setupPool(*jobs, *maxMemory)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	ctx = withErrorPolicy(ctx)
+	files := load(ctx, args)
+	if err := do(files); err != nil {
+		exitOnErr([]error{err})
+	}
+	finish(ctx, files)
+}
 
-	for _, path := range files {
-		path := filepath.Join(pkg.Dir, path)
-		wg.Go(func() error {
-			fset := token.NewFileSet()
-			f, err := parser.ParseFile(fset, path, nil, parser.ParseComments)
+// checkOutputFlags exits with a usage error if the flags that control how
+// files are found, written, and reported are invalid, and sets those that
+// others imply.
+func checkOutputFlags() {
+	if *jobs < 1 || *maxMemory < 1 || *onlyMain && *skipMain || *walk && (*filesMode || *allPlatforms || *onlyMain || *skipMain) || *includeIgnored && !*filesMode && !*walk {
+		usage()
+	}
+	if *verify != "" && *verify != "test" || *reportFmt != "text" && *reportFmt != "json" && *reportFmt != "gh-suggestions" && *reportFmt != "workspace-edit" && *reportFmt != "idea-patch" && *reportFmt != "json-patch" || *summaryBy != "" && *summaryBy != "package" ||
+		*errorsMode != "collect" && *errorsMode != "fail-fast" || *outputDir != "" && (*verify != "" || *regenerate || *renameDirs) || *renameDirs && *verify != "" || (*changelog == "-" || *apiDiff == "-") && *reportFmt != "text" ||
+		*indent != "tabs" && *indent != "spaces" || *tabWidth < 1 || *formatter != "" && *formatter != "gofumpt" {
+		usage()
+	}
+	if *commitID != "" && *reportFmt != "gh-suggestions" {
+		usage()
+	}
+	if *reportFmt == "gh-suggestions" || *reportFmt == "workspace-edit" || *reportFmt == "idea-patch" || *reportFmt == "json-patch" {
+		*check = true // the reviewer, or the editor, makes the changes
+	}
+}
+
+// load finds and parses the files named by args, which are package
+// patterns or, if they end in .go, file names, or with -files-mode,
+// directories. Files that can't be read or parsed are recorded, rather
+// than stopping the rename of everything else.
+func load(ctx context.Context, args []string) []*rename.File {
+	var files []*rename.File
+	loadBatches(ctx, args, 0, func(fs []*rename.File) { files = fs })
+	return files
+}
+
+// loadBatches is load, but for a limit above 0, it hands the files to each
+// as they are parsed, in batches of whole packages, starting a new batch
+// once the files parsed would take about limit bytes of memory to work on.
+// It calls each at least once, and in order.
+func loadBatches(ctx context.Context, args []string, limit int64, each func([]*rename.File)) {
+	var ctxts []*build.Context // none with -walk
+	if !*walk {
+		setupBuild()
+		var err error
+		if ctxts, err = buildContexts(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(exitUsage)
+		}
+	}
+	// As with the go command, arguments ending in .go name files rather
+	// than packages.
+	var patterns, filenames []string
+	for _, arg := range args {
+		if strings.HasSuffix(arg, ".go") {
+			filenames = append(filenames, arg)
+		} else {
+			patterns = append(patterns, arg)
+		}
+	}
+	var paths []string
+	if *filesMode || *walk {
+		if len(patterns) == 0 && len(filenames) == 0 {
+			patterns = []string{"."}
+		}
+		for _, dir := range patterns {
+			fs, err := listGoFiles(ctxts, dir)
 			if err != nil {
-				return err
+				exitOnErr([]error{err})
 			}
-			changed := false
-			ast.Inspect(f, func(node ast.Node) bool {
-				if i, ok := node.(*ast.Ident); ok {
-					if *auto {
-						n := lintName(i.Name)
-						if n != i.Name {
-							changeLog.Lock()
-							changeLog.m[[2]string{i.Name, n}] = true
-							changeLog.Unlock()
-							changed = true
-							i.Name = n
-						}
-					} else if i.Name == *from {
-						changed = true
-						i.Name = *to
-					}
+			filenames = append(filenames, fs...)
+		}
+	} else if len(patterns) > 0 || len(filenames) == 0 {
+		// Overlapping patterns can expand to the same package.
+		seen := make(map[string]bool)
+		for _, p := range gotool.ImportPaths(patterns) {
+			if !seen[p] {
+				seen[p] = true
+				paths = append(paths, p)
+			}
+		}
+	}
+	files := parseFiles(ctx, filenames)
+	var (
+		mu   sync.Mutex
+		wg   syncutil.Group
+		size int64 // of the files in the batch
+	)
+	for _, f := range files {
+		size += int64(len(f.Original()))
+	}
+	flush := func() {
+		wg.Wait()
+		exitIfInterrupted(ctx)
+		sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+		each(files)
+		files, size = nil, 0
+	}
+	for i, p := range paths {
+		// In batches, packages are started -j at a time, to see how big
+		// the batch has got before starting more.
+		if limit > 0 && i > 0 && i%*jobs == 0 {
+			wg.Wait()
+			if size*parseOverhead >= limit {
+				flush()
+			}
+		}
+		p := p
+		wg.Go(func() error {
+			fs, err := parsePackage(ctx, ctxts, p)
+			if err != nil && ctx.Err() == nil {
+				record(p, statusLoadErr, err)
+			}
+			mu.Lock()
+			files = append(files, fs...)
+			for _, f := range fs {
+				size += int64(len(f.Original()))
+			}
+			mu.Unlock()
+			return nil
+		})
+	}
+	flush()
+}
+
+// finish writes the renamed files, runs the follow-up steps the flags ask
+// for, prints the report, and exits.
+func finish(ctx context.Context, files []*rename.File) {
+	exitIfInterrupted(ctx)
+	if *gitConflicts {
+		var bs []string
+		if *branches != "" {
+			bs = strings.Split(*branches, ",")
+		}
+		if err := findBranchConflicts(files, bs); err != nil {
+			exitOnErr([]error{err})
+		}
+	}
+	checkSemver(files)
+	// The preview and review commands pick the files, and hunks, to
+	// write. A nil list of hunks means all of them.
+	var approved map[*rename.File][]bool
+	switch {
+	case previewing:
+		approved = servePreview(ctx, files)
+	case reviewing:
+		approved = review(files)
+	}
+	exitIfInterrupted(ctx)
+	writeFiles(ctx, files, approved)
+	exitIfInterrupted(ctx)
+	if *renameDirs && !*check {
+		renamePackageDirs(files)
+	}
+	findMentions(files)
+	if *compat && !*check {
+		writeCompat(files)
+	}
+	if !*check {
+		for path, src := range moveCreated {
+			if _, err := os.Stat(path); err == nil {
+				record(path, statusWriteErr, errors.New("already exists"))
+				continue
+			}
+			keepForUndo(path)
+			if err := writeOutput(path, src); err != nil {
+				record(path, statusWriteErr, err)
+				continue
+			}
+			record(path, statusCreated, nil)
+		}
+	}
+	if *migration != "" && !*check {
+		writeArtifact(*migration, files, writeMigrationDoc)
+	}
+	if *apiMapPath != "" && !*check {
+		writeArtifact(*apiMapPath, files, writeAPIMap)
+	}
+	switch {
+	case *apiDiff == "-":
+		if err := writeAPIDiff(*apiDiff, files); err != nil {
+			exitOnErr([]error{err})
+		}
+	case *apiDiff != "" && !*check:
+		writeArtifact(*apiDiff, files, writeAPIDiff)
+	}
+	switch {
+	case *changelog == "-":
+		if err := writeChangelog(*changelog, nil); err != nil {
+			exitOnErr([]error{err})
+		}
+	case *changelog != "" && !*check:
+		writeArtifact(*changelog, files, writeChangelog)
+	}
+	if *regenerate && !*check {
+		exitOnErr(regenerateForUndo(files))
+	}
+	if *verify == "test" && !*check {
+		if errs := goInChangedDirs(files, "test"); errs != nil {
+			errs = append(errs, restore(files)...)
+			printReport(*reportFmt)
+			exitOnErr(errs)
+		}
+	}
+	failed, changed := printReport(*reportFmt)
+	unlockModules()
+	closeCheckpoint(failed)
+	switch {
+	case failed:
+		os.Exit(exitFailed)
+	case changed:
+		os.Exit(exitChanged)
+	}
+}
+
+// writeFiles writes the renamed files, or only those and the hunks of them
+// that approved lists, if it isn't nil, and records what happened to each.
+func writeFiles(ctx context.Context, files []*rename.File, approved map[*rename.File][]bool) {
+	if !*check {
+		if err := lockModules(files); err != nil {
+			exitOnErr([]error{err})
+		}
+	}
+	startCheckpoint(files)
+	var rw syncutil.Group
+	for _, f := range files {
+		conflicts = append(conflicts, f.Conflicts()...)
+		messageEdits = append(messageEdits, f.MessageEdits()...)
+		uncertain = append(uncertain, f.LowConfidence()...)
+		dotImportUses = append(dotImportUses, f.DotImportUses()...)
+		selectorUses = append(selectorUses, f.SelectorUses()...)
+		switch {
+		case f.Broken():
+			checkpointFile(f, false)
+			continue
+		case !*generated && f.Generated() && !f.Mock():
+			record(f.Path, statusGenerated, nil)
+			checkpointFile(f, true)
+			continue
+		case !f.Changed():
+			record(f.Path, statusUnchanged, nil)
+			checkpointFile(f, true)
+			continue
+		}
+		keep, ok := approved[f]
+		if (previewing || reviewing) && !ok {
+			record(f.Path, statusDeclined, nil)
+			checkpointFile(f, false)
+			continue
+		}
+		f := f
+		release := acquire(int64(len(f.Original())))
+		rw.Go(func() error {
+			defer release()
+			written := false
+			defer func() { checkpointFile(f, written) }()
+			if *reportFmt == "gh-suggestions" {
+				src, err := render(f)
+				if err != nil {
+					record(f.Path, statusWriteErr, err)
+					return nil
 				}
-				return true
-			})
-			if !changed {
+				suggest(f.Path, f.Original(), src)
+			}
+			if *reportFmt == "workspace-edit" {
+				src, err := render(f)
+				if err == nil {
+					err = editText(f.Path, f.Original(), src)
+				}
+				if err != nil {
+					record(f.Path, statusWriteErr, err)
+					return nil
+				}
+			}
+			if *reportFmt == "idea-patch" {
+				src, err := render(f)
+				if err != nil {
+					record(f.Path, statusWriteErr, err)
+					return nil
+				}
+				diffFile(f.Path, f.Original(), src)
+			}
+			if *reportFmt == "json-patch" {
+				src, err := render(f)
+				if err != nil {
+					record(f.Path, statusWriteErr, err)
+					return nil
+				}
+				patchFile(f.Path, f.Original(), src)
+			}
+			switch {
+			case *check:
+				record(f.Path, statusWouldRename, nil)
+			case ctx.Err() != nil:
+				record(f.Path, statusInterrupted, stopReason(ctx))
 				return nil
+			default:
+				wrote, err := write(f, keep)
+				if errors.Is(err, errModified) {
+					record(f.Path, statusModified, err)
+					return nil
+				}
+				if err != nil {
+					record(f.Path, statusWriteErr, err)
+					return nil
+				}
+				written = true
+				if !wrote {
+					record(f.Path, statusUnchanged, nil)
+					return nil
+				}
+				record(f.Path, statusRenamed, nil)
 			}
-			wc, err := os.Create(path)
-			if err != nil {
-				return err
+			changeLog.Lock()
+			for old, n := range f.Renames() {
+				p := changeLog.m[[2]string{old, n}]
+				p.From, p.To = old, n
+				p.Occurrences += f.Occurrences()[old]
+				p.Files++
+				changeLog.m[[2]string{old, n}] = p
+			}
+			if *summaryBy == "package" {
+				logPackage(f)
 			}
-			defer wc.Close()
-			return printerConf.Fprint(wc, fset, f)
+			changeLog.Unlock()
+			return nil
 		})
 	}
-	return wg.Err()
+	rw.Wait()
+}
+
+// Exit codes.
+const (
+	exitUnchanged = 0 // nothing needed renaming
+	exitChanged   = 1 // something was renamed, or would be with -check
+	exitUsage     = 2 // the flags or arguments were invalid
+	exitFailed    = 3 // some packages or files could not be processed
+)
+
+func usage() {
+	if current != nil {
+		current.printUsage()
+		os.Exit(exitUsage)
+	}
+	fmt.Fprintf(os.Stderr, "Usage: %s [-from <name> -to <name>|-to-template <template>] [-e s/Old/New/...] [-map <file>|-] [-auto] [-matcher-cmd <cmd>] [-files-mode|-walk] [-check] [-report text|json|gh-suggestions|workspace-edit|idea-patch|json-patch] [pkg... | file.go...]\n", os.Args[0])
+	printCommands()
+	os.Exit(exitUsage)
 }
 
-// Copied from go lint.
-// lintName returns a different name if it should be different.
-func lintName(name string) (should string) {
-	// Fast path for simple cases: "_" and all lowercase.
-	if name == "_" {
-		return name
+var errInterrupted = errors.New("interrupted before writing")
+
+// exitIfInterrupted prints the report so far and exits if ctx, which is
+// canceled by an interrupt or, with -errors=fail-fast, the first failure,
+// is done.
+func exitIfInterrupted(ctx context.Context) {
+	if ctx.Err() == nil {
+		return
 	}
-	allLower := true
-	for _, r := range name {
-		if !unicode.IsLower(r) {
-			allLower = false
-			break
-		}
+	if context.Cause(ctx) == errFailFast {
+		logger.Error(errFailFast.Error())
+	} else {
+		logger.Error("interrupted")
+	}
+	printReport(*reportFmt)
+	unlockModules()
+	os.Exit(exitFailed)
+}
+
+// failFast, set with -errors=fail-fast, stops the run when a package or
+// file fails.
+var failFast context.CancelCauseFunc
+
+var errFailFast = errors.New("stopped at the first failure, with -errors=fail-fast")
+
+// withErrorPolicy returns ctx, canceled by the first failure if
+// -errors=fail-fast.
+func withErrorPolicy(ctx context.Context) context.Context {
+	if *errorsMode != "fail-fast" {
+		return ctx
+	}
+	ctx, failFast = context.WithCancelCause(ctx)
+	return ctx
+}
+
+// stopReason returns the error for the files left unwritten because ctx
+// is done.
+func stopReason(ctx context.Context) error {
+	if context.Cause(ctx) == errFailFast {
+		return errFailFast
 	}
-	if allLower {
-		return name
+	return errInterrupted
+}
+
+func exitOnErr(errs []error) {
+	if errs != nil {
+		for _, err := range errs {
+			logger.Error(err.Error())
+		}
+		unlockModules()
+		os.Exit(exitFailed)
 	}
+}
 
-	// Split camelCase at any lower->upper transition, and split on underscores.
-	// Check each word for common initialisms.
-	runes := []rune(name)
-	w, i := 0, 0 // index of start of word, scan
-	for i+1 <= len(runes) {
-		eow := false // whether we hit the end of a word
-		if i+1 == len(runes) {
-			eow = true
-		} else if runes[i+1] == '_' {
-			// underscore; shift the remainder forward over any run of underscores
-			eow = true
-			n := 1
-			for i+n+1 < len(runes) && runes[i+n+1] == '_' {
-				n++
+// parsePackage parses the files of the package at pkgPath that any of
+// ctxts would build, unless -only-main or -skip-main rules it out.
+func parsePackage(ctx context.Context, ctxts []*build.Context, pkgPath string) ([]*rename.File, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	var (
+		paths []string
+		seen  = make(map[string]bool)
+		noGo  error // if no context finds any files
+	)
+	for _, ctxt := range ctxts {
+		release := acquire(0)
+		pkg, err := ctxt.Import(pkgPath, ".", 0)
+		release()
+		if _, ok := err.(*build.NoGoError); ok && len(ctxts) > 1 {
+			noGo = err
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if *onlyMain && pkg.Name != "main" || *skipMain && pkg.Name == "main" {
+			logger.Debug("skipped package", "path", pkgPath, "name", pkg.Name)
+			return nil, nil
+		}
+		if !claimDir(pkg.Dir, pkgPath) {
+			return nil, nil
+		}
+		for _, names := range [][]string{pkg.GoFiles, pkg.TestGoFiles, pkg.XTestGoFiles} {
+			for _, name := range names {
+				path := filepath.Join(pkg.Dir, name)
+				if !seen[path] {
+					seen[path] = true
+					paths = append(paths, path)
+				}
 			}
+		}
+	}
+	if len(seen) == 0 && noGo != nil {
+		return nil, noGo
+	}
+	return parseFiles(ctx, paths), nil
+}
 
-			// Leave at most one underscore if the underscore is between two digits
-			if i+n+1 < len(runes) && unicode.IsDigit(runes[i]) && unicode.IsDigit(runes[i+n+1]) {
-				n--
+// listGoFiles returns the .go files in dir that any of ctxts would build.
+// If dir ends in "/...", it also lists the files in dir's subdirectories,
+// skipping the ones the go command would ignore. With -walk, it lists
+// every .go file in the tree at dir, whatever its build constraints or
+// directory. Either way, unless -include-ignored is set, it leaves out
+// what .gitignore files exclude, and the directories in skippedDirs below
+// dir.
+func listGoFiles(ctxts []*build.Context, dir string) ([]string, error) {
+	recursive := *walk
+	if d := strings.TrimSuffix(dir, "/..."); d != dir {
+		recursive = true
+		dir = d
+	}
+	var paths []string
+	ig := newIgnorer(dir)
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if path == dir {
+				ig.enter(path)
+				return nil
 			}
+			name := info.Name()
+			if !recursive || !*walk && (strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") || name == "testdata") {
+				return filepath.SkipDir
+			}
+			if !*includeIgnored && (skippedDirs[name] || ig.ignored(path, true)) {
+				logger.Debug("skipping ignored directory", "path", path)
+				return filepath.SkipDir
+			}
+			ig.enter(path)
+			return nil
+		}
+		if !strings.HasSuffix(path, ".go") {
+			return nil
+		}
+		if !*includeIgnored && ig.ignored(path, false) {
+			logger.Debug("skipping ignored file", "path", path)
+			return nil
+		}
+		if *walk {
+			paths = append(paths, path)
+			return nil
+		}
+		if ok, err := matchFile(ctxts, filepath.Dir(path), info.Name()); err != nil || !ok {
+			return err
+		}
+		paths = append(paths, path)
+		return nil
+	})
+	return paths, err
+}
 
-			copy(runes[i+1:], runes[i+n+1:])
-			runes = runes[:len(runes)-n]
-		} else if unicode.IsLower(runes[i]) && !unicode.IsLower(runes[i+1]) {
-			// lower->non-lower
-			eow = true
+// parseFiles parses the files at paths, recording the result for any that
+// fail. Files with syntax errors are still returned, so that what did parse
+// can inform the rename, but they will not be rewritten.
+func parseFiles(ctx context.Context, paths []string) []*rename.File {
+	var (
+		mu    sync.Mutex
+		files []*rename.File
+		wg    syncutil.Group
+	)
+	for _, path := range paths {
+		if ctx.Err() != nil {
+			break
+		}
+		if !claim(path) {
+			continue
 		}
-		i++
-		if !eow {
+		if resumed(path) {
+			logger.Debug("skipping, as done before resuming", "path", path)
 			continue
 		}
+		path := path
+		release := acquire(fileSize(path))
+		wg.Go(func() error {
+			defer release()
+			fi, _ := fs.Stat(fsys, path)
+			src, err := fs.ReadFile(fsys, path)
+			if err != nil {
+				record(path, statusReadErr, err)
+				return nil
+			}
+			stampFile(path, fi, src)
+			f, err := rename.ParseFileFor(path, src, parseNames)
+			if err != nil {
+				record(path, statusParseErr, err)
+			}
+			if f != nil {
+				mu.Lock()
+				files = append(files, f)
+				mu.Unlock()
+			}
+			return nil
+		})
+	}
+	wg.Wait()
+	return files
+}
+
+// parseNames are the names a run can rename, for rename.ParseFileFor, or
+// nil if it can rename others.
+var parseNames []string
+
+// renamedNames returns the names that the run can rename, given the
+// renames of -e and -map, if it can only rename identifiers by those and
+// -from, in whole or, with -word, in part. Other ways of renaming, such as
+// -auto, rename identifiers that nothing names in advance, or, like
+// -plurals and -merge, with names other than those, or need the comments
+// and objects of every file.
+func renamedNames(renames []renameRule) []string {
+	if auto.set || *matcherCmd != "" || *positionsPath != "" || *plurals || *merge || *enumPrefix != "" || *prefixExported != "" ||
+		*unexportName != "" || *exportName != "" || *deprecateOnly || *renameDirs {
+		return nil
+	}
+	var names []string
+	if *from != "" {
+		names = append(names, (*from)[strings.LastIndex(*from, ".")+1:])
+	}
+	for _, r := range renames {
+		names = append(names, r.From)
+	}
+	return names
+}
+
+// writeArtifact writes a file describing the renames, such as the
+// -migration-doc, and records the result.
+func writeArtifact(path string, files []*rename.File, write func(string, []*rename.File) error) {
+	keepForUndo(path)
+	if err := write(path, files); err != nil {
+		record(path, statusWriteErr, err)
+		return
+	}
+	record(path, statusCreated, nil)
+}
+
+// writeCompat writes the -compat forwarders.
+func writeCompat(files []*rename.File) {
+	fs, err := rename.Compat(files)
+	if err != nil {
+		exitOnErr([]error{err})
+	}
+	for path, src := range fs {
+		keepForUndo(path)
+		if err := writeOutput(path, src); err != nil {
+			record(path, statusWriteErr, err)
+			continue
+		}
+		record(path, statusCreated, nil)
+	}
+}
+
+// style returns the layout for the file at path, from -indent, -tab-width
+// and, with -editorconfig, .editorconfig files.
+func style(path string) rename.Style {
+	s := rename.Style{IndentSpaces: *indent == "spaces", TabWidth: *tabWidth}
+	if !*editorCfg {
+		return s
+	}
+	s = editorConfigStyle(path, s)
+	flag.Visit(func(fl *flag.Flag) {
+		switch fl.Name {
+		case "indent":
+			s.IndentSpaces = *indent == "spaces"
+		case "tab-width":
+			s.TabWidth = *tabWidth
+		}
+	})
+	return s
+}
 
-		// [w,i) is a word.
-		word := string(runes[w:i])
-		if u := strings.ToUpper(word); commonInitialisms[u] {
-			// Keep consistent case, which is lowercase only at the start.
-			if w == 0 && unicode.IsLower(runes[w]) {
-				u = strings.ToLower(u)
-			}
-			// All the common initialisms are ASCII,
-			// so we can replace the bytes exactly.
-			copy(runes[w:], []rune(u))
-		} else if w > 0 && strings.ToLower(word) == word {
-			// already all lowercase, and not the first word, so uppercase the first character.
-			runes[w] = unicode.ToUpper(runes[w])
-		}
-		w = i
-	}
-	return string(runes)
-}
-
-// Copied from go lint.
-var commonInitialisms = map[string]bool{
-	"API":   true,
-	"ASCII": true,
-	"CPU":   true,
-	"CSS":   true,
-	"DNS":   true,
-	"EOF":   true,
-	"GUID":  true,
-	"HTML":  true,
-	"HTTP":  true,
-	"HTTPS": true,
-	"ID":    true,
-	"IP":    true,
-	"JSON":  true,
-	"LHS":   true,
-	"QPS":   true,
-	"RAM":   true,
-	"RHS":   true,
-	"RPC":   true,
-	"SLA":   true,
-	"SMTP":  true,
-	"SQL":   true,
-	"SSH":   true,
-	"TCP":   true,
-	"TLS":   true,
-	"TTL":   true,
-	"UDP":   true,
-	"UI":    true,
-	"UID":   true,
-	"UUID":  true,
-	"URI":   true,
-	"URL":   true,
-	"UTF8":  true,
-	"VM":    true,
-	"XML":   true,
-	"XSRF":  true,
-	"XSS":   true,
+// render returns the new source of f, laid out as the flags say.
+func render(f *rename.File) ([]byte, error) {
+	src, err := f.FormatStyle(style(f.Path))
+	if err != nil {
+		return nil, err
+	}
+	if *formatter == "gofumpt" {
+		return gofumpt(src)
+	}
+	return src, nil
+}
+
+// write writes f back to its path, with only the hunks keep says to apply
+// if it is not nil, unless that would leave the file as it was. It reports
+// whether it wrote.
+func write(f *rename.File, keep []bool) (bool, error) {
+	src, err := render(f)
+	if err != nil {
+		return false, err
+	}
+	if keep != nil {
+		src = applyHunks(f.Original(), src, keep)
+	}
+	if bytes.Equal(src, f.Original()) {
+		return false, nil
+	}
+	if err := checkUnchanged(f.Path); err != nil {
+		return false, err
+	}
+	return true, writeOutput(f.Path, src)
+}
+
+// gofumpt formats src with the gofumpt command, keeping CRLF line endings.
+func gofumpt(src []byte) ([]byte, error) {
+	cmd := exec.Command("gofumpt")
+	cmd.Stdin = bytes.NewReader(src)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := bytes.TrimSpace(stderr.Bytes()); len(msg) > 0 {
+			return nil, fmt.Errorf("gofumpt: %v: %s", err, msg)
+		}
+		return nil, fmt.Errorf("gofumpt: %v", err)
+	}
+	if bytes.Contains(src, []byte("\r\n")) && !bytes.Contains(out, []byte("\r\n")) {
+		out = bytes.ReplaceAll(out, []byte("\n"), []byte("\r\n"))
+	}
+	return out, nil
+}
+
+// goInChangedDirs runs the go command with args in the directory of each
+// changed file.
+func goInChangedDirs(files []*rename.File, args ...string) []error {
+	seen := make(map[string]bool)
+	var dirs []string
+	for _, f := range files {
+		if dir := filepath.Dir(f.Path); f.Changed() && !seen[dir] {
+			seen[dir] = true
+			dirs = append(dirs, dir)
+		}
+	}
+	sort.Strings(dirs)
+	var errs []error
+	for _, dir := range dirs {
+		cmd := exec.Command("go", args...)
+		cmd.Dir = dir
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			errs = append(errs, fmt.Errorf("go %s in %s: %v", strings.Join(args, " "), dir, err))
+		}
+	}
+	return errs
+}
+
+// restore writes back the original contents of each changed file, and
+// undoes what the run wrote besides: it removes the files it created, such
+// as the -compat forwarders and those -regenerate wrote, and puts back the
+// ones it changed.
+func restore(files []*rename.File) []error {
+	n, errs := undoWrites()
+	for _, f := range files {
+		if !f.Changed() {
+			continue
+		}
+		if err := fsys.WriteFile(f.Path, f.Original(), 0666); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		record(f.Path, statusRestored, nil)
+		n++
+	}
+	return append(errs, fmt.Errorf("verification failed; restored %d files", n))
 }
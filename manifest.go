@@ -0,0 +1,222 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// manifestFlags are the flags of a -manifest run that aren't passed on to
+// the run in each repository.
+var manifestFlags = map[string]bool{"manifest": true, "patch-dir": true, "commit": true, "play": true, "record": true}
+
+// pathFlags are the flags that name files or directories, which flagArgs
+// makes absolute.
+var pathFlags = map[string]bool{
+	"map":             true,
+	"positions":       true,
+	"lint-config":     true,
+	"resume":          true,
+	"output-dir":      true,
+	"migration-doc":   true,
+	"api-map":         true,
+	"api-diff":        true,
+	"changelog-entry": true,
+}
+
+// flagArgs returns the flags set in fs, except those in skip, as arguments
+// for a run of this command in another directory, as -manifest and -pr-repo
+// make. Relative paths are made absolute, so that they still name the same
+// files there, as is a -matcher-cmd command given by path; "-", for standard
+// input or output, is left alone.
+func flagArgs(fs *flag.FlagSet, skip map[string]bool) []string {
+	var args []string
+	fs.Visit(func(f *flag.Flag) {
+		if skip[f.Name] {
+			return
+		}
+		v := f.Value.String()
+		switch {
+		case pathFlags[f.Name] && v != "" && v != "-":
+			v = absPath(v)
+		case f.Name == "matcher-cmd":
+			if words := strings.Fields(v); len(words) > 0 && strings.ContainsRune(words[0], filepath.Separator) {
+				words[0] = absPath(words[0])
+				v = strings.Join(words, " ")
+			}
+		}
+		args = append(args, fmt.Sprintf("-%s=%s", f.Name, v))
+	})
+	return args
+}
+
+// absPath returns path made absolute, or path itself if it can't be.
+func absPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
+
+// A repoResult is what -manifest prints for each repository with
+// -report=json.
+type repoResult struct {
+	Repo   string          `json:"repo"`
+	Exit   int             `json:"exit"`
+	Patch  string          `json:"patch,omitempty"`
+	Report json.RawMessage `json:"report,omitempty"`
+	Error  string          `json:"error,omitempty"`
+}
+
+// runManifest renames in each repository listed in the manifest at path,
+// one root per line, by running this command there with the same flags
+// and args, or ./... if there are none. It prints a summary per
+// repository, writes each one's diff to -patch-dir, and with -commit,
+// commits the changes. It then exits as a single run would.
+func runManifest(path string, args []string) {
+	repos, err := readManifest(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(exitUsage)
+	}
+	self, err := os.Executable()
+	if err != nil {
+		exitOnErr([]error{err})
+	}
+	childArgs := flagArgs(flag.CommandLine, manifestFlags)
+	if len(args) == 0 {
+		args = []string{"./..."}
+	}
+	childArgs = append(childArgs, args...)
+
+	failed, changed := false, false
+	for _, repo := range repos {
+		r := runInRepo(self, repo, childArgs)
+		switch {
+		case r.Error != "" || r.Exit == exitFailed || r.Exit == exitUsage:
+			failed = true
+		case r.Exit == exitChanged:
+			changed = true
+		}
+		if *reportFmt == "json" {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "\t")
+			enc.Encode(r)
+		} else if r.Error != "" {
+			fmt.Printf("%s: %s\n", repo, r.Error)
+		} else if r.Patch != "" {
+			fmt.Printf("Wrote %s\n", r.Patch)
+		}
+	}
+	switch {
+	case failed:
+		os.Exit(exitFailed)
+	case changed:
+		os.Exit(exitChanged)
+	}
+	os.Exit(exitUnchanged)
+}
+
+// runInRepo runs the command with args in repo, and writes the patch and
+// commit that -patch-dir and -commit ask for.
+func runInRepo(self, repo string, args []string) repoResult {
+	r := repoResult{Repo: repo}
+	cmd := exec.Command(self, args...)
+	cmd.Dir = repo
+	cmd.Stderr = os.Stderr
+	var out bytes.Buffer
+	if *reportFmt == "json" {
+		cmd.Stdout = &out
+	} else {
+		fmt.Printf("== %s ==\n", repo)
+		cmd.Stdout = os.Stdout
+	}
+	err := cmd.Run()
+	var ee *exec.ExitError
+	switch {
+	case errors.As(err, &ee):
+		r.Exit = ee.ExitCode()
+	case err != nil:
+		r.Error = err.Error()
+		return r
+	}
+	if out.Len() > 0 {
+		r.Report = json.RawMessage(bytes.TrimSpace(out.Bytes()))
+	}
+	if r.Exit != exitChanged || *check || *patchDir == "" && *commitMsg == "" {
+		return r
+	}
+	// Staging everything takes in the files the run created, which git
+	// diff and commit -a would leave out.
+	if _, err := git("-C", repo, "add", "-A"); err != nil {
+		r.Error = err.Error()
+		return r
+	}
+	if *patchDir != "" {
+		diff, err := git("-C", repo, "diff", "--cached")
+		if err != nil {
+			r.Error = err.Error()
+			return r
+		}
+		r.Patch = filepath.Join(*patchDir, patchName(repo))
+		if err := fsys.WriteFile(r.Patch, []byte(diff), 0666); err != nil {
+			r.Error = err.Error()
+			return r
+		}
+	}
+	if *commitMsg != "" {
+		if _, err := git("-C", repo, "commit", "-q", "-m", *commitMsg); err != nil {
+			r.Error = err.Error()
+		}
+	}
+	return r
+}
+
+// patchName returns the name of the -patch-dir patch for repo: its base
+// name, with a hash of its whole path so that repositories with the same
+// base name in different places don't write the same patch.
+func patchName(repo string) string {
+	if abs, err := filepath.Abs(repo); err == nil {
+		repo = abs
+	}
+	sum := sha256.Sum256([]byte(filepath.Clean(repo)))
+	return fmt.Sprintf("%s-%x.patch", filepath.Base(filepath.Clean(repo)), sum[:4])
+}
+
+// readManifest returns the repository roots listed in the manifest at
+// path, relative to the manifest's directory. Blank lines and lines
+// starting with # are ignored.
+func readManifest(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var repos []string
+	sc := bufio.NewScanner(file)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if !filepath.IsAbs(line) {
+			line = filepath.Join(filepath.Dir(path), line)
+		}
+		repos = append(repos, line)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if len(repos) == 0 {
+		return nil, fmt.Errorf("%s: no repositories", path)
+	}
+	return repos, nil
+}
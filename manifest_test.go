@@ -0,0 +1,50 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestFlagArgs(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fs := flag.NewFlagSet("gorename-global", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.String("map", "", "")
+	fs.String("lint-config", "", "")
+	fs.String("output-dir", "", "")
+	fs.String("changelog-entry", "", "")
+	fs.String("matcher-cmd", "", "")
+	fs.String("manifest", "", "")
+	fs.Bool("check", false, "")
+	err = fs.Parse([]string{
+		"-map=map.txt",
+		"-lint-config=/etc/lint.json",
+		"-output-dir=out",
+		"-changelog-entry=-",
+		"-matcher-cmd=bin/matcher -v",
+		"-manifest=repos.txt",
+		"-check",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := flagArgs(fs, map[string]bool{"manifest": true})
+	want := []string{
+		"-changelog-entry=-",
+		"-check=true",
+		"-lint-config=/etc/lint.json",
+		"-map=" + filepath.Join(wd, "map.txt"),
+		"-matcher-cmd=" + filepath.Join(wd, "bin", "matcher") + " -v",
+		"-output-dir=" + filepath.Join(wd, "out"),
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("flagArgs:\n%q\nwant:\n%q", got, want)
+	}
+}
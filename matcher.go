@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"go/token"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"github.com/jeremyschlatter/gorename-global/rename"
+)
+
+// A matcher is a -matcher-cmd subprocess. For each candidate identifier, the
+// tool writes a line of JSON describing it to the command's standard input,
+// and the command replies with a line of JSON on its standard output saying
+// what to do with it.
+type matcher struct {
+	mu  sync.Mutex // serializes request/reply pairs
+	cmd *exec.Cmd
+	in  io.WriteCloser
+	enc *json.Encoder
+	dec *json.Decoder
+}
+
+// A decision is the matcher's reply to a rename.Candidate. Action is "rename" or
+// "skip". A rename with an empty Name uses the candidate's New name.
+type decision struct {
+	Action string `json:"action"`
+	Name   string `json:"name,omitempty"`
+}
+
+func startMatcher(command string) (*matcher, error) {
+	args := strings.Fields(command)
+	if len(args) == 0 {
+		return nil, fmt.Errorf("matcher: no command")
+	}
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stderr = os.Stderr
+	in, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("matcher: %v", err)
+	}
+	return &matcher{
+		cmd: cmd,
+		in:  in,
+		enc: json.NewEncoder(in),
+		dec: json.NewDecoder(bufio.NewReader(out)),
+	}, nil
+}
+
+// decide asks the matcher what to rename c to.
+func (m *matcher) decide(c rename.Candidate) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.enc.Encode(c); err != nil {
+		return "", fmt.Errorf("matcher: %v", err)
+	}
+	var d decision
+	if err := m.dec.Decode(&d); err != nil {
+		return "", fmt.Errorf("matcher: reading decision for %s: %v", c.Pos, err)
+	}
+	switch d.Action {
+	case "skip":
+		return c.Name, nil
+	case "rename":
+		switch {
+		case d.Name == "":
+			return c.New, nil
+		case !token.IsIdentifier(d.Name):
+			return "", fmt.Errorf("matcher: %q, the name for %s, is not an identifier", d.Name, c.Pos)
+		}
+		return d.Name, nil
+	}
+	return "", fmt.Errorf("matcher: unknown action %q for %s", d.Action, c.Pos)
+}
+
+// close ends the matcher's input and waits for it to exit.
+func (m *matcher) close() error {
+	m.in.Close()
+	if err := m.cmd.Wait(); err != nil {
+		return fmt.Errorf("matcher: %v", err)
+	}
+	return nil
+}
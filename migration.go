@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/jeremyschlatter/gorename-global/rename"
+)
+
+// apiRenames groups the renamed exported symbols of files by directory,
+// and returns the directories that have any in order.
+func apiRenames(files []*rename.File) (dirs []string, byDir map[string][]rename.APIRename) {
+	byDir = make(map[string][]rename.APIRename)
+	for _, f := range files {
+		dir := filepath.Dir(f.Path)
+		byDir[dir] = append(byDir[dir], f.APIRenames()...)
+	}
+	for dir, rs := range byDir {
+		if len(rs) > 0 {
+			dirs = append(dirs, dir)
+		}
+	}
+	sort.Strings(dirs)
+	return dirs, byDir
+}
+
+// An apiMap is the -api-map file. Its schema is versioned so that tools
+// updating dependent code can rely on it; add fields, but never change or
+// remove one without bumping Version.
+type apiMap struct {
+	Version  int         `json:"version"`
+	Packages []apiMapPkg `json:"packages"`
+}
+
+// An apiMapPkg lists the renamed exported symbols of one package.
+type apiMapPkg struct {
+	Path    string             `json:"path"` // import path, or directory outside any GOPATH
+	Renames []rename.APIRename `json:"renames"`
+}
+
+// writeAPIMap writes the renamed exported symbols of each package to path
+// as JSON, for -api-map.
+func writeAPIMap(path string, files []*rename.File) error {
+	dirs, byDir := apiRenames(files)
+	m := apiMap{Version: 1, Packages: []apiMapPkg{}}
+	for _, dir := range dirs {
+		p := importPath(dir)
+		if p == "" {
+			p = dir
+		}
+		m.Packages = append(m.Packages, apiMapPkg{Path: p, Renames: byDir[dir]})
+	}
+	b, err := json.MarshalIndent(m, "", "\t")
+	if err != nil {
+		return err
+	}
+	return fsys.WriteFile(path, append(b, '\n'), 0666)
+}
+
+// writeMigrationDoc writes a Markdown table of the renamed exported
+// symbols of each package to path, for -migration-doc.
+func writeMigrationDoc(path string, files []*rename.File) error {
+	dirs, byDir := apiRenames(files)
+
+	var buf bytes.Buffer
+	buf.WriteString("# Migration guide\n\nThese exported symbols have been renamed. Generated by gorename-global.\n")
+	if len(dirs) == 0 {
+		buf.WriteString("\nNo exported symbols were renamed.\n")
+	}
+	for _, dir := range dirs {
+		pkg := importPath(dir)
+		heading := pkg
+		if pkg == "" {
+			heading = dir
+		}
+		fmt.Fprintf(&buf, "\n## %s\n\n| Old | New | Kind |\n| --- | --- | --- |\n", heading)
+		rs := byDir[dir]
+		// Methods of renamed types are listed under the type's old name.
+		oldTypes := oldTypeNames(rs)
+		sort.SliceStable(rs, func(i, j int) bool { return symbol(rs[i].Recv, rs[i].Old) < symbol(rs[j].Recv, rs[j].Old) })
+		for _, r := range rs {
+			oldRecv := r.Recv
+			if o, ok := oldTypes[r.Recv]; ok {
+				oldRecv = o
+			}
+			fmt.Fprintf(&buf, "| `%s` | %s | %s |\n", symbol(oldRecv, r.Old), docLink(pkg, symbol(r.Recv, r.New)), r.Kind)
+		}
+	}
+	return fsys.WriteFile(path, buf.Bytes(), 0666)
+}
+
+// oldTypeNames maps the new names of the types renamed in rs to their old
+// ones.
+func oldTypeNames(rs []rename.APIRename) map[string]string {
+	m := make(map[string]string)
+	for _, r := range rs {
+		if r.Kind == "type" {
+			m[r.New] = r.Old
+		}
+	}
+	return m
+}
+
+// symbol returns the name of a package-level symbol, qualified by its
+// receiver type for a method.
+func symbol(recv, name string) string {
+	if recv == "" {
+		return name
+	}
+	return recv + "." + name
+}
+
+// docLink returns a Markdown link to the documentation of sym in the
+// package with import path pkg, or just sym if pkg is empty.
+func docLink(pkg, sym string) string {
+	if pkg != "" {
+		return fmt.Sprintf("[`%s`](https://pkg.go.dev/%s#%s)", sym, pkg, sym)
+	}
+	return "`" + sym + "`"
+}
+
+// importPath returns the import path of the package in dir, by the go.mod
+// file of the module it is in or, outside any module, by where it is in
+// GOPATH. It returns "" if dir is in neither, or if -walk is set.
+func importPath(dir string) string {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return ""
+	}
+	if *walk {
+		return "" // its packages don't resolve
+	}
+	if p, ok := importPathCache.Load(abs); ok {
+		return p.(string)
+	}
+	root := modRoot(abs)
+	p := modImportPath(root, modulePath(root), abs)
+	importPathCache.Store(abs, p)
+	return p
+}
+
+// importPathCache caches importPath's answers, by absolute directory, since
+// the reports ask for each changed file's.
+var importPathCache sync.Map
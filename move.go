@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"go/build"
+	"go/token"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/jeremyschlatter/gorename-global/rename"
+)
+
+// moveCreated holds the files the move command creates, written by finish.
+var moveCreated map[string][]byte
+
+// moveMain runs the move command, which moves a top-level declaration,
+// given as pkg.Name, to another package of the module, and updates the
+// references to it in the packages named, ./... by default.
+func moveMain(args []string) {
+	if len(args) < 2 {
+		usage()
+	}
+	i := strings.LastIndex(args[0], ".")
+	if i < 0 || !token.IsIdentifier(args[0][i+1:]) {
+		usage()
+	}
+	from, name, to := args[0][:i], args[0][i+1:], args[1]
+	pkgs := args[2:]
+	if len(pkgs) == 0 {
+		pkgs = []string{"./..."}
+	}
+	runSubcommand(pkgs, func(files []*rename.File) error {
+		root, err := moduleRoot()
+		if err != nil {
+			return err
+		}
+		modPath := modulePath(root)
+		importPaths := make(map[string]string) // by directory
+		names := make(map[string]string)       // package names, by directory
+		for _, f := range files {
+			dir := filepath.Dir(f.Path)
+			if _, ok := importPaths[dir]; ok {
+				continue
+			}
+			real, err := filepath.EvalSymlinks(dir)
+			if err != nil {
+				real = dir
+			}
+			importPaths[dir] = modImportPath(root, modPath, real)
+			if pkg, err := build.ImportDir(dir, 0); err == nil {
+				names[dir] = pkg.Name
+			}
+		}
+		fromDir, err := findPackage(from, importPaths, names)
+		if err != nil {
+			return err
+		}
+		toDir, err := findPackage(to, importPaths, names)
+		if err != nil {
+			return err
+		}
+		m := rename.Move{Name: name, From: importPaths[fromDir], To: importPaths[toDir], ToDir: toDir, ToName: names[toDir]}
+		if m.ToName == "" {
+			m.ToName = filepath.Base(toDir)
+		}
+		moveCreated, err = rename.MoveDecl(files, m, importPaths)
+		return err
+	})
+}
+
+// findPackage returns the directory of the package that arg names among
+// those of importPaths and names, by directory: as the directory itself,
+// its import path, or, if only one package has it, the last element of
+// that or its package name. A directory not among them is added to them.
+func findPackage(arg string, importPaths, names map[string]string) (string, error) {
+	if fi, err := os.Stat(arg); err == nil && fi.IsDir() {
+		dir := filepath.Clean(arg)
+		if _, ok := importPaths[dir]; !ok {
+			root, err := moduleRoot()
+			if err != nil {
+				return "", err
+			}
+			real, err := filepath.EvalSymlinks(dir)
+			if err != nil {
+				return "", err
+			}
+			importPaths[dir] = modImportPath(root, modulePath(root), real)
+			if pkg, err := build.ImportDir(dir, 0); err == nil {
+				names[dir] = pkg.Name
+			}
+		}
+		if importPaths[dir] == "" {
+			return "", fmt.Errorf("%s is outside the module", arg)
+		}
+		return dir, nil
+	}
+	var found []string
+	for dir, ip := range importPaths {
+		if ip == arg {
+			return dir, nil
+		}
+		if path.Base(ip) == arg || names[dir] == arg {
+			found = append(found, dir)
+		}
+	}
+	sort.Strings(found)
+	switch len(found) {
+	case 0:
+		return "", fmt.Errorf("no package named is %s", arg)
+	case 1:
+		return found[0], nil
+	}
+	return "", fmt.Errorf("%s could be any of the packages in %s", arg, strings.Join(found, ", "))
+}
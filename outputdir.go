@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// outputPath returns where to write the file at path: path itself or, with
+// -output-dir, the same place in a copy of the current directory's tree
+// rooted there, whose directories it creates.
+func outputPath(path string) (string, error) {
+	if *outputDir == "" {
+		return path, nil
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(wd, abs)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s is outside the current directory, so it has no place in -output-dir", path)
+	}
+	out := filepath.Join(*outputDir, rel)
+	return out, os.MkdirAll(filepath.Dir(out), 0777)
+}
+
+// writeOutput writes src, the new source of the file at path, to its
+// outputPath. A new file there gets the same permissions as path.
+func writeOutput(path string, src []byte) error {
+	out, err := outputPath(path)
+	if err != nil {
+		return err
+	}
+	if out == path {
+		return fsys.WriteFile(out, src, 0666)
+	}
+	perm := fs.FileMode(0666)
+	if fi, err := fs.Stat(fsys, path); err == nil {
+		perm = fi.Mode().Perm()
+	}
+	logger.Debug("writing", "path", path, "to", out)
+	return fsys.WriteFile(out, src, perm)
+}
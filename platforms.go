@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"go/build"
+	"strings"
+)
+
+// defaultPlatforms is the matrix that -all-platforms loads packages for
+// unless -platforms says otherwise.
+const defaultPlatforms = "linux/amd64 linux/arm64 darwin/amd64 darwin/arm64 windows/amd64 windows/arm64 freebsd/amd64 js/wasm wasip1/wasm"
+
+// buildContexts returns the contexts to load packages with: build.Default,
+// or with -all-platforms, a copy of it for each platform in -platforms.
+// Each platform is goos/goarch, optionally followed by build tags, as in
+// "linux/amd64,integration", which are added to those from GOFLAGS.
+func buildContexts() ([]*build.Context, error) {
+	if !*allPlatforms {
+		return []*build.Context{&build.Default}, nil
+	}
+	var ctxts []*build.Context
+	for _, p := range strings.Fields(*platforms) {
+		tags := strings.Split(p, ",")
+		goos, goarch, ok := strings.Cut(tags[0], "/")
+		if !ok || goos == "" || goarch == "" {
+			return nil, fmt.Errorf("bad platform %q in -platforms, want goos/goarch[,tag...]", p)
+		}
+		ctxt := build.Default
+		ctxt.GOOS, ctxt.GOARCH = goos, goarch
+		ctxt.BuildTags = append(append([]string(nil), build.Default.BuildTags...), tags[1:]...)
+		ctxts = append(ctxts, &ctxt)
+	}
+	if len(ctxts) == 0 {
+		return nil, fmt.Errorf("-platforms is empty")
+	}
+	return ctxts, nil
+}
+
+// matchFile reports whether any of ctxts would build the file name in dir.
+func matchFile(ctxts []*build.Context, dir, name string) (bool, error) {
+	for _, ctxt := range ctxts {
+		if ok, err := ctxt.MatchFile(dir, name); err != nil || ok {
+			return ok, err
+		}
+	}
+	return false, nil
+}
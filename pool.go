@@ -0,0 +1,54 @@
+package main
+
+import (
+	"io/fs"
+
+	"go4.org/syncutil"
+)
+
+// The pool bounds the work on files in flight across all packages: how
+// many are being read, parsed, or written at once, and how much memory
+// that is estimated to take.
+var (
+	workers   *syncutil.Gate
+	memory    *syncutil.Sem
+	memoryMax int64
+)
+
+// parseOverhead estimates the memory needed to parse or print a file, as a
+// multiple of its size.
+const parseOverhead = 20
+
+// setupPool allows n files in flight at once, taking at most mb megabytes.
+func setupPool(n, mb int) {
+	workers = syncutil.NewGate(n)
+	memoryMax = int64(mb) << 20
+	memory = syncutil.NewSem(memoryMax)
+}
+
+// acquire blocks until there is room in the pool for work on a file of
+// size bytes, and returns the function that gives the room back. Callers
+// acquire before starting a goroutine, so that a large tree doesn't start
+// a goroutine per file up front.
+func acquire(size int64) (release func()) {
+	cost := size * parseOverhead
+	if cost > memoryMax {
+		cost = memoryMax
+	}
+	workers.Start()
+	memory.Acquire(cost)
+	return func() {
+		memory.Release(cost)
+		workers.Done()
+	}
+}
+
+// fileSize returns the size of the file at path, or 0 if it can't be
+// found, in which case reading it will fail soon enough.
+func fileSize(path string) int64 {
+	fi, err := fs.Stat(fsys, path)
+	if err != nil {
+		return 0
+	}
+	return fi.Size()
+}
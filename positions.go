@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"go/token"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/jeremyschlatter/gorename-global/rename"
+)
+
+// A positionList is the renames that -positions reads, of the identifiers
+// at particular positions.
+type positionList struct {
+	mu sync.Mutex
+	m  map[string]*listedPosition // by absolute file:line:column
+}
+
+// A listedPosition is one line of a -positions file.
+type listedPosition struct {
+	pos  string // as written
+	old  string // the name expected there, if the line gives it
+	new  string
+	used bool
+}
+
+// readPositions reads the -positions file at path, or standard input if
+// path is "-". Each line is a position and the new name of the identifier
+// there, as in "a/b.go:12:3 NewName", or a line printed by the list
+// command, as in "a/b.go:12:3: OldName -> NewName", which also says what
+// the identifier must be called now. Blank lines and those starting with
+// # are skipped.
+func readPositions(path string) (*positionList, error) {
+	r := io.Reader(os.Stdin)
+	name := "standard input"
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r, name = f, path
+	}
+	pl := &positionList{m: make(map[string]*listedPosition)}
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		var p listedPosition
+		switch fields := strings.Fields(line); {
+		case len(fields) == 2:
+			p = listedPosition{pos: fields[0], new: fields[1]}
+		case len(fields) == 4 && fields[2] == "->":
+			p = listedPosition{pos: strings.TrimSuffix(fields[0], ":"), old: fields[1], new: fields[3]}
+		default:
+			return nil, fmt.Errorf("%s:%d: want a position and a new name, got %q", name, n, line)
+		}
+		if !token.IsIdentifier(p.new) || p.old != "" && !token.IsIdentifier(p.old) {
+			return nil, fmt.Errorf("%s:%d: the names must be identifiers", name, n)
+		}
+		key, ok := absPosition(p.pos)
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: %q is not a position of the form file:line:column", name, n, p.pos)
+		}
+		if o, ok := pl.m[key]; ok && o.new != p.new {
+			return nil, fmt.Errorf("%s:%d: %s is renamed to both %s and %s", name, n, p.pos, o.new, p.new)
+		}
+		pl.m[key] = &p
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	return pl, nil
+}
+
+// absPosition returns the file:line:column position pos with the file made
+// absolute, so that positions written relative to different directories
+// compare equal, and whether pos is such a position.
+func absPosition(pos string) (string, bool) {
+	i := strings.LastIndex(pos, ":")
+	if i < 0 {
+		return "", false
+	}
+	j := strings.LastIndex(pos[:i], ":")
+	if j <= 0 {
+		return "", false
+	}
+	line, err1 := strconv.Atoi(pos[j+1 : i])
+	col, err2 := strconv.Atoi(pos[i+1:])
+	if err1 != nil || err2 != nil || line < 1 || col < 1 {
+		return "", false
+	}
+	file, err := filepath.Abs(pos[:j])
+	if err != nil {
+		return "", false
+	}
+	return fmt.Sprintf("%s:%d:%d", file, line, col), true
+}
+
+// match is the Options.Match for pl: it renames the identifiers at the
+// positions listed, and leaves every other alone.
+func (pl *positionList) match(c rename.Candidate) (string, error) {
+	key, ok := absPosition(c.Pos)
+	if !ok {
+		return c.Name, nil
+	}
+	pl.mu.Lock()
+	defer pl.mu.Unlock()
+	p, ok := pl.m[key]
+	if !ok {
+		return c.Name, nil
+	}
+	p.used = true
+	if p.old != "" && p.old != c.Name {
+		return "", fmt.Errorf("%s: the identifier there is %s, not %s as listed", p.pos, c.Name, p.old)
+	}
+	return p.new, nil
+}
+
+// unused returns an error for each position listed at which no identifier
+// was found to rename, in order.
+func (pl *positionList) unused() []error {
+	var ps []string
+	for _, p := range pl.m {
+		if !p.used {
+			ps = append(ps, p.pos)
+		}
+	}
+	sort.Strings(ps)
+	var errs []error
+	for _, p := range ps {
+		errs = append(errs, fmt.Errorf("%s: no identifier to rename there", p))
+	}
+	return errs
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+)
+
+// prFlags are the flags of a -pr-repo run that aren't passed on to the
+// rename in the checkout.
+var prFlags = map[string]bool{"pr-repo": true, "pr-branch": true, "pr-base": true, "pr-title": true, "play": true, "record": true, "report": true}
+
+// runPR renames in the repository -pr-repo, cloning it first unless it is
+// a local checkout, then pushes the change to the branch -pr-branch and
+// opens a pull request, or GitLab merge request, against -pr-base with the
+// rename's summary as its description. The token comes from GITHUB_TOKEN
+// or GITLAB_TOKEN. A local checkout must have no changes of its own, which
+// would go into the commit; a clone is removed once the run is done.
+func runPR(args []string) {
+	if *prBranch == "" || *check {
+		usage()
+	}
+	dir := *prRepo
+	if out, err := git("-C", dir, "rev-parse", "--is-inside-work-tree"); err != nil || strings.TrimSpace(out) != "true" {
+		tmp, err := os.MkdirTemp("", "gorename-global-")
+		if err != nil {
+			exitOnErr([]error{err})
+		}
+		if _, err := git("clone", "-q", *prRepo, tmp); err != nil {
+			os.RemoveAll(tmp)
+			exitOnErr([]error{err})
+		}
+		dir = tmp
+	} else if out, err := git("-C", dir, "status", "--porcelain"); err != nil {
+		exitOnErr([]error{err})
+	} else if out != "" {
+		exitOnErr([]error{fmt.Errorf("%s has uncommitted changes; commit or stash them first", dir)})
+	}
+	summary, link, err := pushPR(dir, args)
+	if dir != *prRepo {
+		os.RemoveAll(dir)
+	}
+	if err != nil {
+		exitOnErr([]error{err})
+	}
+	if link == "" {
+		fmt.Println("Nothing to rename; no pull request opened.")
+		return
+	}
+	fmt.Print(string(summary))
+	fmt.Printf("Opened %s\n", link)
+	os.Exit(exitChanged)
+}
+
+// pushPR renames in the checkout dir on the new branch -pr-branch, commits
+// and pushes the change, and opens the pull request for it. It returns the
+// rename's summary and the pull request's web address, which is "" if there
+// was nothing to rename.
+func pushPR(dir string, args []string) (summary []byte, link string, err error) {
+	base := *prBase
+	if base == "" {
+		ref, err := git("-C", dir, "symbolic-ref", "--short", "refs/remotes/origin/HEAD")
+		if err != nil {
+			return nil, "", err
+		}
+		base = strings.TrimPrefix(strings.TrimSpace(ref), "origin/")
+	}
+	remote, err := git("-C", dir, "remote", "get-url", "origin")
+	if err != nil {
+		return nil, "", err
+	}
+	host, repo, err := parseRemote(strings.TrimSpace(remote))
+	if err != nil {
+		return nil, "", err
+	}
+	if _, err := prToken(host); err != nil {
+		return nil, "", err // before anything is pushed
+	}
+	if _, err := git("-C", dir, "checkout", "-q", "-b", *prBranch); err != nil {
+		return nil, "", err
+	}
+
+	self, err := os.Executable()
+	if err != nil {
+		return nil, "", err
+	}
+	childArgs := append([]string{"-report=text"}, flagArgs(flag.CommandLine, prFlags)...)
+	if len(args) == 0 {
+		args = []string{"./..."}
+	}
+	cmd := exec.Command(self, append(childArgs, args...)...)
+	cmd.Dir = dir
+	cmd.Stderr = os.Stderr
+	summary, err = cmd.Output()
+	var ee *exec.ExitError
+	switch {
+	case err == nil:
+		return summary, "", nil
+	case !errors.As(err, &ee) || ee.ExitCode() != exitChanged:
+		return nil, "", fmt.Errorf("renaming in %s: %v", dir, err)
+	}
+
+	title := *prTitle
+	if title == "" {
+		title = "Rename identifiers with gorename-global"
+		if *from != "" && *to != "" {
+			title = fmt.Sprintf("Rename %s to %s", *from, *to)
+		}
+	}
+	body := "Generated by gorename-global.\n\n```\n" + string(summary) + "```\n"
+	for _, step := range [][]string{
+		{"-C", dir, "add", "-A"},
+		{"-C", dir, "commit", "-q", "-m", title},
+		{"-C", dir, "push", "-q", "-u", "origin", *prBranch},
+	} {
+		if _, err := git(step...); err != nil {
+			return nil, "", err
+		}
+	}
+	link, err = openPR(host, repo, base, *prBranch, title, body)
+	return summary, link, err
+}
+
+// prToken returns the Authorization header to open a pull request on host
+// with: GITHUB_TOKEN for github.com, and GITLAB_TOKEN for gitlab.com or the
+// GitLab server that GITLAB_HOST names. No token is sent to any other host.
+func prToken(host string) (string, error) {
+	gitlab := os.Getenv("GITLAB_HOST")
+	if u, err := url.Parse(gitlab); err == nil && u.Host != "" {
+		gitlab = u.Hostname()
+	}
+	switch {
+	case host == "github.com":
+		return "Bearer " + os.Getenv("GITHUB_TOKEN"), nil
+	case host == "gitlab.com", host != "" && host == gitlab:
+		return "Bearer " + os.Getenv("GITLAB_TOKEN"), nil
+	}
+	return "", fmt.Errorf("can't open a pull request on %s: not github.com or gitlab.com, and not named by GITLAB_HOST", host)
+}
+
+// openPR opens a pull request on GitHub, or a merge request on GitLab, for
+// the repository repo on host, and returns its web address.
+func openPR(host, repo, base, branch, title, body string) (string, error) {
+	token, err := prToken(host)
+	if err != nil {
+		return "", err
+	}
+	var (
+		api string
+		req map[string]string
+	)
+	if host == "github.com" {
+		api = "https://api.github.com/repos/" + repo + "/pulls"
+		req = map[string]string{"title": title, "body": body, "head": branch, "base": base}
+	} else {
+		api = "https://" + host + "/api/v4/projects/" + url.PathEscape(repo) + "/merge_requests"
+		req = map[string]string{"title": title, "description": body, "source_branch": branch, "target_branch": base}
+	}
+	b, err := json.Marshal(req)
+	if err != nil {
+		return "", err
+	}
+	hr, err := http.NewRequest("POST", api, bytes.NewReader(b))
+	if err != nil {
+		return "", err
+	}
+	hr.Header.Set("Authorization", token)
+	hr.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(hr)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	var res struct {
+		HTMLURL string `json:"html_url"` // GitHub
+		WebURL  string `json:"web_url"`  // GitLab
+		Message string `json:"message"`
+	}
+	json.NewDecoder(resp.Body).Decode(&res)
+	if resp.StatusCode/100 != 2 {
+		return "", fmt.Errorf("opening pull request on %s: %s: %s", host, resp.Status, res.Message)
+	}
+	if res.HTMLURL != "" {
+		return res.HTMLURL, nil
+	}
+	return res.WebURL, nil
+}
+
+// parseRemote splits a git remote URL, in https or scp-like ssh form, into
+// its host and repository path, such as "github.com" and "owner/repo".
+func parseRemote(remote string) (host, repo string, err error) {
+	if i := strings.Index(remote, "://"); i >= 0 {
+		u, err := url.Parse(remote)
+		if err != nil {
+			return "", "", err
+		}
+		host, repo = u.Hostname(), u.Path
+	} else if at, colon := strings.Index(remote, "@"), strings.Index(remote, ":"); colon > at {
+		host, repo = remote[at+1:colon], remote[colon+1:]
+	} else {
+		return "", "", fmt.Errorf("can't tell where to open a pull request for remote %s", remote)
+	}
+	repo = strings.TrimSuffix(strings.Trim(path.Clean("/"+repo), "/"), ".git")
+	return host, repo, nil
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"go/ast"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/jeremyschlatter/gorename-global/rename"
+)
+
+// prefixRenames returns the renames that -prefix-exported makes: each
+// exported name declared at the top level of the packages of files, other
+// than in their tests, to the same name with the prefix in front.
+func prefixRenames(files []*rename.File) []renameRule {
+	seen := make(map[renameRule]bool)
+	var rs []renameRule
+	for _, f := range files {
+		if strings.HasSuffix(f.Path, "_test.go") {
+			continue
+		}
+		pkg := importPath(filepath.Dir(f.Path))
+		for name, kind := range f.Declared() {
+			if !ast.IsExported(name) {
+				continue
+			}
+			r := renameRule{From: name, To: *prefixExported + name, Kind: kind, Package: pkg}
+			if !seen[r] {
+				seen[r] = true
+				rs = append(rs, r)
+			}
+		}
+	}
+	sort.Slice(rs, func(i, j int) bool {
+		return rs[i].Package < rs[j].Package || rs[i].Package == rs[j].Package && rs[i].From < rs[j].From
+	})
+	return rs
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"html/template"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+
+	"github.com/jeremyschlatter/gorename-global/rename"
+)
+
+// previewing is set by the preview command, which serves the changes for
+// review before writing them.
+var previewing bool
+
+// A previewFile is one changed file on the preview page.
+type previewFile struct {
+	Path  string
+	Hunks []previewHunk
+}
+
+type previewHunk struct {
+	Line     int // of the first line, counting from 1
+	Old, New []string
+}
+
+var previewPage = template.Must(template.New("preview").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>gorename-global preview</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+pre { margin: 0 0 0.5em 1.5em; padding: 0.25em; background: #f6f8fa; }
+.old { color: #b31d28; }
+.new { color: #22863a; }
+</style>
+</head>
+<body>
+{{if .Done}}
+<p>Applied {{.Applied}} of {{len .Files}} files. You can close this page.</p>
+{{else}}
+<form method="post" action="/apply">
+<input type="hidden" name="token" value="{{.Token}}">
+<p>{{len .Files}} files would change. Uncheck the ones to leave alone, then apply.</p>
+{{range $i, $f := .Files}}
+<h3><label><input type="checkbox" name="file" value="{{$i}}" checked> {{$f.Path}}</label></h3>
+{{range $f.Hunks}}<small>line {{.Line}}</small>
+<pre>{{range .Old}}<span class="old">- {{.}}</span>{{end}}{{range .New}}<span class="new">+ {{.}}</span>{{end}}</pre>
+{{end}}
+{{end}}
+<p><button type="submit">Apply</button></p>
+</form>
+{{end}}
+</body>
+</html>
+`))
+
+// servePreview serves a page at -http showing the changes to files, and
+// returns the files the reviewer approves once they apply them, with all
+// their hunks, or nil if ctx is done first.
+func servePreview(ctx context.Context, files []*rename.File) map[*rename.File][]bool {
+	var (
+		mu      sync.Mutex // guards page
+		changed []*rename.File
+		page    struct {
+			Files   []previewFile
+			Token   string
+			Done    bool
+			Applied int
+		}
+	)
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		exitOnErr([]error{err})
+	}
+	page.Token = hex.EncodeToString(token)
+	for _, f := range files {
+		if f.Broken() || !f.Changed() || !*generated && f.Generated() && !f.Mock() {
+			continue
+		}
+		src, err := render(f)
+		if err != nil {
+			record(f.Path, statusWriteErr, err)
+			continue
+		}
+		pf := previewFile{Path: f.Path}
+		for _, h := range lineHunks(f.Original(), src) {
+			pf.Hunks = append(pf.Hunks, previewHunk{h.start + 1, h.old, h.new})
+		}
+		changed = append(changed, f)
+		page.Files = append(page.Files, pf)
+	}
+
+	l, err := net.Listen("tcp", *httpAddr)
+	if err != nil {
+		exitOnErr([]error{err})
+	}
+	approved := make(chan map[*rename.File][]bool, 1)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if !localHost(r.Host) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if err := previewPage.Execute(w, page); err != nil {
+			logger.Warn("serving preview", "error", err)
+		}
+	})
+	mux.HandleFunc("/apply", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Redirect(w, r, "/", http.StatusSeeOther)
+			return
+		}
+		r.ParseForm()
+		if !localHost(r.Host) || !sameOrigin(r) ||
+			subtle.ConstantTimeCompare([]byte(r.PostForm.Get("token")), []byte(page.Token)) != 1 {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		ok := make(map[*rename.File][]bool)
+		for _, v := range r.Form["file"] {
+			if i, err := strconv.Atoi(v); err == nil && i >= 0 && i < len(changed) {
+				ok[changed[i]] = nil
+			}
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if page.Done {
+			previewPage.Execute(w, page)
+			return
+		}
+		page.Done, page.Applied = true, len(ok)
+		previewPage.Execute(w, page)
+		select {
+		case approved <- ok:
+		default:
+		}
+	})
+	srv := &http.Server{Handler: mux}
+	go srv.Serve(l)
+	defer srv.Shutdown(context.Background())
+	logger.Info("serving preview", "url", "http://"+l.Addr().String()+"/")
+	select {
+	case ok := <-approved:
+		return ok
+	case <-ctx.Done():
+		return nil
+	}
+}
+
+// localHost reports whether host, a request's Host header, names this machine
+// as localhost or an IP address, rather than a name another site controls, as
+// in a DNS rebinding attack.
+func localHost(host string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return host == "localhost" || net.ParseIP(host) != nil
+}
+
+// sameOrigin reports whether r comes from a page served by the preview itself:
+// its Origin header, if it has one, as browsers send with every post, is this
+// server's.
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && u.Scheme == "http" && u.Host == r.Host
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLocalHost(t *testing.T) {
+	for host, want := range map[string]bool{
+		"localhost:8080": true,
+		"localhost":      true,
+		"127.0.0.1:8080": true,
+		"[::1]:8080":     true,
+		"evil.com:8080":  false,
+		"evil.com":       false,
+	} {
+		if got := localHost(host); got != want {
+			t.Errorf("localHost(%q) = %v, want %v", host, got, want)
+		}
+	}
+}
+
+func TestSameOrigin(t *testing.T) {
+	for origin, want := range map[string]bool{
+		"":                       true,
+		"http://localhost:8080":  true,
+		"https://localhost:8080": false,
+		"http://localhost:9090":  false,
+		"http://evil.com":        false,
+		"null":                   false,
+	} {
+		r := httptest.NewRequest("POST", "http://localhost:8080/apply", nil)
+		if origin != "" {
+			r.Header.Set("Origin", origin)
+		}
+		if got := sameOrigin(r); got != want {
+			t.Errorf("sameOrigin with Origin %q = %v, want %v", origin, got, want)
+		}
+	}
+}
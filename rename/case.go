@@ -0,0 +1,74 @@
+package rename
+
+import (
+	"go/ast"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// sameCase returns name, exported if and only if like is.
+func sameCase(name, like string) string {
+	if ast.IsExported(like) {
+		return export(name)
+	}
+	return unexport(name)
+}
+
+// isUpper reports whether s has more than one letter and no lower-case
+// ones, like "FOO" but not "F" or "Foo".
+func isUpper(s string) bool {
+	letters := 0
+	for _, r := range s {
+		if unicode.IsLower(r) {
+			return false
+		}
+		if unicode.IsLetter(r) {
+			letters++
+		}
+	}
+	return letters > 1
+}
+
+// export returns name with its first word capitalized, or upper-cased if
+// it is a common initialism: "id" becomes "ID" and "idField" "IDField".
+func export(name string) string {
+	i := strings.IndexFunc(name, func(r rune) bool { return !unicode.IsLower(r) })
+	if i < 0 {
+		i = len(name)
+	}
+	if u := strings.ToUpper(name[:i]); commonInitialisms[u] {
+		return u + name[i:]
+	}
+	r, n := utf8.DecodeRuneInString(name)
+	return string(unicode.ToUpper(r)) + name[n:]
+}
+
+// unexport returns name with its leading run of capitals lower-cased, except
+// for a capital that starts the next word: "ID" becomes "id" and "URLPath"
+// "urlPath".
+func unexport(name string) string {
+	runes := []rune(name)
+	i := 0
+	for i < len(runes) && unicode.IsUpper(runes[i]) {
+		i++
+	}
+	if i > 1 && i < len(runes) && unicode.IsLower(runes[i]) {
+		i--
+	}
+	if i == 0 {
+		i = 1
+	}
+	for j := 0; j < i && j < len(runes); j++ {
+		runes[j] = unicode.ToLower(runes[j])
+	}
+	return string(runes)
+}
+
+// Unexport returns name unexported as Options.ToTemplate's {unexported}
+// does: "ID" becomes "id" and "URLPath" "urlPath".
+func Unexport(name string) string { return unexport(name) }
+
+// Export returns name exported as Options.ToTemplate's {exported} does:
+// "id" becomes "ID" and "httpClient" "HTTPClient".
+func Export(name string) string { return export(name) }
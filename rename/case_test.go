@@ -0,0 +1,72 @@
+package rename_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/jeremyschlatter/gorename-global/rename"
+)
+
+func TestRenameIgnoreCase(t *testing.T) {
+	tests := []struct {
+		name          string
+		from, to      string
+		src, want     string
+		wantConflicts []string
+	}{
+		{
+			name: "exportedness kept",
+			from: "userID", to: "accountID",
+			src: `package a
+
+type T struct{ UserId int }
+
+func f(userid int) int { return userid }
+
+var userID = T{}.UserId
+`,
+			want: `package a
+
+type T struct{ AccountID int }
+
+func f(accountID int) int { return accountID }
+
+var accountID = T{}.AccountID
+`,
+		},
+		{
+			name: "distinct",
+			from: "accountID", to: "AccountID",
+			src:  "package a\n\nvar accountid, AccountId int\n",
+			want: "package a\n\nvar accountID, AccountID int\n",
+		},
+		{
+			// accountID and AccountId would both become AccountID, and
+			// then accountid would become the accountID left alone.
+			name: "taken",
+			from: "accountID", to: "AccountID",
+			src:  "package a\n\nvar accountid, AccountId, accountID int\n\nfunc f() int { return accountid + AccountId + accountID }\n",
+			want: "package a\n\nvar accountid, AccountId, accountID int\n\nfunc f() int { return accountid + AccountId + accountID }\n",
+			wantConflicts: []string{
+				"AccountId -> AccountID",
+				"accountID -> AccountID",
+				"accountid -> accountID",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys := rename.MemFS{"a/a.go": []byte(tt.src)}
+			files, err := changeFS(t, fsys, func(files []*rename.File) error {
+				return rename.Rename(files, rename.Options{From: tt.from, To: tt.to, IgnoreCase: true})
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkFS(t, fsys, map[string]string{"a/a.go": tt.want})
+			if got := conflicts(files); !reflect.DeepEqual(got, tt.wantConflicts) {
+				t.Errorf("conflicts %q, want %q", got, tt.wantConflicts)
+			}
+		})
+	}
+}
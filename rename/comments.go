@@ -0,0 +1,169 @@
+package rename
+
+import (
+	"go/ast"
+	"go/token"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// A Mention is a comment that names an identifier, by its old name, after
+// it was renamed.
+type Mention struct {
+	Pos  string `json:"pos"` // file:line:column of the name
+	Old  string `json:"old"`
+	New  string `json:"new"`
+	Text string `json:"text"` // the line of the comment that has the name
+}
+
+// Mentions returns the places where comments in f name any of the old
+// names in renames, which maps old names to new, as whole words. Rename
+// leaves most comments alone, so these are the comments that may need
+// their wording updated by hand. //go:generate directives, which Rename
+// rewrites, are not among them.
+func (f *File) Mentions(renames map[string]string) []Mention {
+	var ms []Mention
+	isIdent := func(r rune) bool {
+		return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+	}
+	for _, cg := range f.f.Comments {
+		for _, c := range cg.List {
+			if strings.HasPrefix(c.Text, "//go:generate ") {
+				continue
+			}
+			for off := 0; off < len(c.Text); {
+				i := strings.IndexFunc(c.Text[off:], isIdent)
+				if i < 0 {
+					break
+				}
+				start := off + i
+				end := strings.IndexFunc(c.Text[start:], func(r rune) bool { return !isIdent(r) })
+				if end < 0 {
+					end = len(c.Text)
+				} else {
+					end += start
+				}
+				if n, ok := renames[c.Text[start:end]]; ok {
+					ms = append(ms, Mention{
+						Pos:  f.fset.Position(c.Pos() + token.Pos(start)).String(),
+						Old:  c.Text[start:end],
+						New:  n,
+						Text: commentLine(c.Text, start),
+					})
+				}
+				off = end
+			}
+		}
+	}
+	return ms
+}
+
+// commentLine returns the line of the comment text that has the byte at
+// i, without its comment markers or surrounding space.
+func commentLine(text string, i int) string {
+	line := text[strings.LastIndex(text[:i], "\n")+1:]
+	if j := strings.Index(line, "\n"); j >= 0 {
+		line = line[:j]
+	}
+	for _, p := range []string{"//", "/*"} {
+		line = strings.TrimPrefix(strings.TrimSpace(line), p)
+	}
+	return strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(line), "*/"))
+}
+
+// rewriteDocs replaces the old name that starts the doc comment of each
+// declaration that f.declRenames renamed, as in "// Old returns ...", or,
+// for a type, follows "A", "An" or "The", as in "// An Old is ...", so that
+// the comment still starts with the name, as linters expect.
+func rewriteDocs(f *File) (changed bool) {
+	renamed := make(map[ast.Node][]declRename)
+	for _, dr := range f.declRenames {
+		renamed[dr.node] = append(renamed[dr.node], dr)
+	}
+	if len(renamed) == 0 {
+		return false
+	}
+	fix := func(doc *ast.CommentGroup, drs []declRename, isType bool) {
+		if doc == nil || len(doc.List) == 0 {
+			return
+		}
+		for _, dr := range drs {
+			if replaceLeadingName(doc.List[0], dr.old, dr.new, isType) {
+				changed = true
+				return
+			}
+		}
+	}
+	for _, d := range f.f.Decls {
+		switch d := d.(type) {
+		case *ast.FuncDecl:
+			fix(d.Doc, renamed[d], false)
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				drs := renamed[spec]
+				if len(drs) == 0 {
+					continue
+				}
+				_, isType := spec.(*ast.TypeSpec)
+				doc := d.Doc
+				switch spec := spec.(type) {
+				case *ast.TypeSpec:
+					if spec.Doc != nil {
+						doc = spec.Doc
+					}
+				case *ast.ValueSpec:
+					if spec.Doc != nil {
+						doc = spec.Doc
+					}
+				}
+				if d.Lparen.IsValid() && doc == d.Doc {
+					continue // the group's comment, not the spec's
+				}
+				fix(doc, drs, isType)
+			}
+		}
+	}
+	return changed
+}
+
+// replaceLeadingName replaces old with new in c if it is the first word of
+// c's text, or the second after an article and isType is set, and reports
+// whether it did. An "A" or "An" that suited old is changed to suit new.
+func replaceLeadingName(c *ast.Comment, old, new string, isType bool) bool {
+	marker := c.Text[:2]
+	text := c.Text[2:]
+	lead := text[:len(text)-len(strings.TrimLeft(text, " \t"))]
+	text = text[len(lead):]
+	if isType {
+		for _, article := range []string{"A ", "An ", "The "} {
+			if strings.HasPrefix(text, article) {
+				lead += article
+				text = text[len(article):]
+				break
+			}
+		}
+	}
+	if !strings.HasPrefix(text, old) {
+		return false
+	}
+	if rest := text[len(old):]; rest != "" {
+		if r, _ := utf8.DecodeRuneInString(rest); r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return false
+		}
+	}
+	if a := article(old); strings.HasSuffix(lead, " "+a+" ") || lead == a+" " {
+		lead = strings.TrimSuffix(lead, a+" ") + article(new) + " "
+	}
+	c.Text = marker + lead + new + text[len(old):]
+	return true
+}
+
+// article returns the indefinite article to put before name, going by
+// whether it starts with a vowel, as in "a Client" but "an Item".
+func article(name string) string {
+	if name != "" && strings.ContainsRune("AEIOUaeiou", rune(name[0])) {
+		return "An"
+	}
+	return "A"
+}
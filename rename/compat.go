@@ -0,0 +1,334 @@
+package rename
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/printer"
+	"go/token"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A declSite is a top-level declaration, as seen from its name.
+type declSite struct {
+	node ast.Node    // *ast.FuncDecl, *ast.TypeSpec, or *ast.ValueSpec
+	tok  token.Token // for a ValueSpec, token.CONST or token.VAR
+}
+
+// A declRename is a rename of a top-level declaration.
+type declRename struct {
+	declSite
+	old, new string
+}
+
+// topLevelDecls maps the name of each top-level declaration in f to it.
+func topLevelDecls(f *ast.File) map[*ast.Ident]declSite {
+	m := make(map[*ast.Ident]declSite)
+	for _, d := range f.Decls {
+		switch d := d.(type) {
+		case *ast.FuncDecl:
+			m[d.Name] = declSite{node: d}
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				switch spec := spec.(type) {
+				case *ast.TypeSpec:
+					m[spec.Name] = declSite{node: spec}
+				case *ast.ValueSpec:
+					for _, n := range spec.Names {
+						m[n] = declSite{node: spec, tok: d.Tok}
+					}
+				}
+			}
+		}
+	}
+	return m
+}
+
+// An APIRename is the rename of an exported top-level declaration.
+type APIRename struct {
+	Old  string `json:"old"`
+	New  string `json:"new"`
+	Kind string `json:"kind"`           // "type", "func", "method", "const", or "var"
+	Recv string `json:"recv,omitempty"` // for a method, its receiver's type, by its new name
+}
+
+// APIRenames returns the renames of f's exported top-level declarations,
+// in source order. Test files and command packages have none.
+func (f *File) APIRenames() []APIRename {
+	if !f.isAPI() {
+		return nil
+	}
+	var rs []APIRename
+	for _, dr := range f.declRenames {
+		if !ast.IsExported(dr.old) {
+			continue
+		}
+		r := APIRename{Old: dr.old, New: dr.new}
+		switch n := dr.node.(type) {
+		case *ast.TypeSpec:
+			r.Kind = "type"
+		case *ast.ValueSpec:
+			r.Kind = dr.tok.String()
+		case *ast.FuncDecl:
+			r.Kind = "func"
+			if n.Recv != nil && len(n.Recv.List) > 0 {
+				r.Kind, r.Recv = "method", recvType(n.Recv.List[0].Type)
+			}
+		}
+		rs = append(rs, r)
+	}
+	return rs
+}
+
+// isAPI reports whether f's exported declarations are visible to importers.
+func (f *File) isAPI() bool {
+	return !strings.HasSuffix(f.Path, "_test.go") && f.f.Name.Name != "main"
+}
+
+// recvType returns the name of the type in a method receiver.
+func recvType(e ast.Expr) string {
+	for {
+		switch x := e.(type) {
+		case *ast.StarExpr:
+			e = x.X
+		case *ast.ParenExpr:
+			e = x.X
+		case *ast.IndexExpr:
+			e = x.X
+		case *ast.IndexListExpr:
+			e = x.X
+		case *ast.Ident:
+			return x.Name
+		default:
+			return ""
+		}
+	}
+}
+
+// Compat returns, for each package in which Rename renamed an exported
+// top-level declaration, a file of deprecated forwarders from the old names
+// to the new ones: type aliases, constants, variables initialized from the
+// new ones, and wrapper functions and methods. This keeps code outside the
+// package compiling until it catches up. The file is named deprecated.go,
+// or deprecated2.go and so on if that name is taken. Test files and
+// command packages are ignored.
+func Compat(files []*File) (map[string][]byte, error) {
+	return forwarderFiles(files, "deprecated", func(f *File, dr declRename, imports map[string]string) (string, error) {
+		return f.forwarder(dr, imports, true)
+	})
+}
+
+// forwarderFiles returns a file of the forwarders that fwd writes for each
+// package's renamed exported declarations, named base.go, or base2.go and
+// so on if that name is taken.
+func forwarderFiles(files []*File, base string, fwd func(f *File, dr declRename, imports map[string]string) (string, error)) (map[string][]byte, error) {
+	type pkg struct {
+		name    string
+		decls   []string
+		imports map[string]string // import path -> name it is used by
+		taken   map[string]bool   // file names in use
+	}
+	pkgs := make(map[string]*pkg)
+	var dirs []string
+	for _, f := range files {
+		dir := filepath.Dir(f.Path)
+		p := pkgs[dir]
+		if p == nil {
+			p = &pkg{imports: make(map[string]string), taken: make(map[string]bool)}
+			pkgs[dir] = p
+			dirs = append(dirs, dir)
+		}
+		p.taken[filepath.Base(f.Path)] = true
+		if !f.isAPI() {
+			continue
+		}
+		p.name = f.f.Name.Name
+		for _, dr := range f.declRenames {
+			if !ast.IsExported(dr.old) {
+				continue
+			}
+			d, err := fwd(f, dr, p.imports)
+			if err != nil {
+				return nil, err
+			}
+			p.decls = append(p.decls, d)
+		}
+	}
+	sort.Strings(dirs)
+	out := make(map[string][]byte)
+	for _, dir := range dirs {
+		p := pkgs[dir]
+		if len(p.decls) == 0 {
+			continue
+		}
+		var buf bytes.Buffer
+		fmt.Fprintf(&buf, "package %s\n\n", p.name)
+		var paths []string
+		for ip := range p.imports {
+			paths = append(paths, ip)
+		}
+		sort.Strings(paths)
+		for _, ip := range paths {
+			if name := p.imports[ip]; name != path.Base(ip) {
+				fmt.Fprintf(&buf, "import %s %q\n", name, ip)
+			} else {
+				fmt.Fprintf(&buf, "import %q\n", ip)
+			}
+		}
+		for _, d := range p.decls {
+			buf.WriteString("\n" + d)
+		}
+		src, err := format.Source(buf.Bytes())
+		if err != nil {
+			return nil, fmt.Errorf("rename: formatting forwarders for %s: %v", dir, err)
+		}
+		name := base + ".go"
+		for i := 2; p.taken[name]; i++ {
+			name = fmt.Sprintf("%s%d.go", base, i)
+		}
+		out[filepath.Join(dir, name)] = src
+	}
+	return out, nil
+}
+
+// forwarder returns the source of a declaration of dr.old that forwards to
+// dr.new, adding the imports it needs to imports. If deprecated is set,
+// dr.old is the old name, marked deprecated; otherwise it is the new name,
+// and dr.new the deprecated one.
+func (f *File) forwarder(dr declRename, imports map[string]string, deprecated bool) (string, error) {
+	var buf bytes.Buffer
+	if deprecated {
+		fmt.Fprintf(&buf, "// %s is the old name of %s.\n//\n// Deprecated: Use %s instead.\n", dr.old, dr.new, dr.new)
+	} else {
+		fmt.Fprintf(&buf, "// %s is the new name of %s, which is deprecated.\n", dr.old, dr.new)
+	}
+	switch n := dr.node.(type) {
+	case *ast.TypeSpec:
+		tparams, targs := f.typeParams(n.TypeParams, imports)
+		fmt.Fprintf(&buf, "type %s%s = %s%s\n", dr.old, tparams, dr.new, targs)
+	case *ast.ValueSpec:
+		if dr.tok == token.VAR {
+			fmt.Fprintf(&buf, "//\n// Unlike %s, it is set only once, when the package is initialized.\n", dr.new)
+		}
+		fmt.Fprintf(&buf, "%s %s = %s\n", dr.tok, dr.old, dr.new)
+	case *ast.FuncDecl:
+		recv, call := "", dr.new
+		if n.Recv != nil && len(n.Recv.List) > 0 {
+			rf := n.Recv.List[0]
+			name := "r"
+			if len(rf.Names) > 0 && rf.Names[0].Name != "_" {
+				name = rf.Names[0].Name
+			}
+			recv = fmt.Sprintf("(%s %s) ", name, f.exprText(rf.Type, imports))
+			call = name + "." + dr.new
+		}
+		tparams, targs := f.typeParams(n.Type.TypeParams, imports)
+		params, args := f.params(n.Type.Params, imports)
+		results := ""
+		if n.Type.Results != nil && len(n.Type.Results.List) > 0 {
+			var rs []string
+			for _, field := range n.Type.Results.List {
+				t := f.exprText(field.Type, imports)
+				for range field.Names {
+					rs = append(rs, t)
+				}
+				if len(field.Names) == 0 {
+					rs = append(rs, t)
+				}
+			}
+			results = " (" + strings.Join(rs, ", ") + ")"
+		}
+		ret := ""
+		if results != "" {
+			ret = "return "
+		}
+		fmt.Fprintf(&buf, "func %s%s%s(%s)%s {\n\t%s%s%s(%s)\n}\n", recv, dr.old, tparams, params, results, ret, call, targs, args)
+	default:
+		return "", fmt.Errorf("rename: no forwarder for %s", dr.old)
+	}
+	return buf.String(), nil
+}
+
+// params returns the parameter list for a forwarder of a function with the
+// given parameters, naming any unnamed ones, and the matching arguments.
+func (f *File) params(fl *ast.FieldList, imports map[string]string) (params, args string) {
+	var ps, as []string
+	i := 0
+	for _, field := range fl.List {
+		t := f.exprText(field.Type, imports)
+		names := field.Names
+		if len(names) == 0 {
+			names = []*ast.Ident{{Name: "_"}}
+		}
+		for _, n := range names {
+			name := n.Name
+			if name == "_" {
+				name = "p" + strconv.Itoa(i)
+			}
+			i++
+			ps = append(ps, name+" "+t)
+			if _, ok := field.Type.(*ast.Ellipsis); ok {
+				name += "..."
+			}
+			as = append(as, name)
+		}
+	}
+	return strings.Join(ps, ", "), strings.Join(as, ", ")
+}
+
+// typeParams returns a type parameter list and the matching type arguments,
+// or empty strings if fl is empty.
+func (f *File) typeParams(fl *ast.FieldList, imports map[string]string) (params, args string) {
+	if fl == nil || len(fl.List) == 0 {
+		return "", ""
+	}
+	var ps, as []string
+	for _, field := range fl.List {
+		var names []string
+		for _, n := range field.Names {
+			names = append(names, n.Name)
+		}
+		ps = append(ps, strings.Join(names, ", ")+" "+f.exprText(field.Type, imports))
+		as = append(as, names...)
+	}
+	return "[" + strings.Join(ps, ", ") + "]", "[" + strings.Join(as, ", ") + "]"
+}
+
+// exprText prints e, adding the imports it refers to to imports.
+func (f *File) exprText(e ast.Expr, imports map[string]string) string {
+	ast.Inspect(e, func(node ast.Node) bool {
+		sel, ok := node.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+		if x, ok := sel.X.(*ast.Ident); ok && x.Obj == nil {
+			if ip := f.importPath(x.Name); ip != "" {
+				imports[ip] = x.Name
+			}
+		}
+		return true
+	})
+	var buf bytes.Buffer
+	printer.Fprint(&buf, f.fset, e)
+	return buf.String()
+}
+
+// importPath returns the path of the package f imports as name, guessing
+// that an unnamed import is used by the last element of its path.
+func (f *File) importPath(name string) string {
+	for _, spec := range f.f.Imports {
+		ip, err := strconv.Unquote(spec.Path.Value)
+		if err != nil {
+			continue
+		}
+		if spec.Name != nil && spec.Name.Name == name || spec.Name == nil && path.Base(ip) == name {
+			return ip
+		}
+	}
+	return ""
+}
@@ -0,0 +1,123 @@
+package rename_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/jeremyschlatter/gorename-global/rename"
+)
+
+func TestCompat(t *testing.T) {
+	const src = `package net
+
+import "io"
+
+type Client struct{}
+
+func Dial(network string, r io.Reader) (*Client, error) { return nil, nil }
+
+func (c *Client) Close() error { return nil }
+
+var Default = 1
+
+var timeout = 2
+`
+	const forwarders = `package net
+
+import "io"
+
+// Client is the old name of Conn.
+//
+// Deprecated: Use Conn instead.
+type Client = Conn
+
+// Dial is the old name of Connect.
+//
+// Deprecated: Use Connect instead.
+func Dial(network string, r io.Reader) (*Conn, error) {
+	return Connect(network, r)
+}
+
+// Close is the old name of Shut.
+//
+// Deprecated: Use Shut instead.
+func (c *Conn) Close() error {
+	return c.Shut()
+}
+
+// Default is the old name of Std.
+//
+// Deprecated: Use Std instead.
+//
+// Unlike Std, it is set only once, when the package is initialized.
+var Default = Std
+`
+	renames := map[string]string{"Client": "Conn", "Dial": "Connect", "Close": "Shut", "Default": "Std", "timeout": "deadline"}
+	tests := []struct {
+		name  string
+		files rename.MemFS
+		want  map[string]string
+	}{
+		{
+			name:  "forwarders",
+			files: rename.MemFS{"net/net.go": []byte(src)},
+			want:  map[string]string{"net/deprecated.go": forwarders},
+		},
+		{
+			name: "name taken",
+			files: rename.MemFS{
+				"net/net.go":        []byte(src),
+				"net/deprecated.go": []byte("package net\n"),
+			},
+			want: map[string]string{"net/deprecated2.go": forwarders},
+		},
+		{
+			name: "unexported",
+			files: rename.MemFS{
+				"net/net.go": []byte("package net\n\nvar timeout = 2\n"),
+			},
+			want: map[string]string{},
+		},
+		{
+			name: "command",
+			files: rename.MemFS{
+				"cmd/main.go": []byte("package main\n\nvar Default = 1\n\nfunc main() {}\n"),
+			},
+			want: map[string]string{},
+		},
+		{
+			name: "test file",
+			files: rename.MemFS{
+				"net/net.go":      []byte("package net\n"),
+				"net/net_test.go": []byte("package net\n\nvar Default = 1\n"),
+			},
+			want: map[string]string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files, err := changeFS(t, tt.files, func(files []*rename.File) error {
+				return rename.Rename(files, rename.Options{Match: func(c rename.Candidate) (string, error) {
+					if n, ok := renames[c.Name]; ok {
+						return n, nil
+					}
+					return c.Name, nil
+				}})
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			out, err := rename.Compat(files)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := make(map[string]string)
+			for name, src := range out {
+				got[name] = string(src)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
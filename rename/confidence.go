@@ -0,0 +1,56 @@
+package rename
+
+import (
+	"go/ast"
+	"math"
+)
+
+// A LowConfidence is an identifier that Rename left alone because it was
+// less sure of the rename than Options.MinConfidence.
+type LowConfidence struct {
+	Pos        string  `json:"pos"` // file:line:column
+	Old        string  `json:"old"`
+	New        string  `json:"new"`
+	Confidence float64 `json:"confidence"`
+}
+
+// LowConfidence returns the identifiers in f that Options.MinConfidence
+// left alone.
+func (f *File) LowConfidence() []LowConfidence { return f.lowConfidence }
+
+// confidence scores how sure Rename can be, from 0 to 1, that i is the
+// identifier being renamed. Rename goes by names, so it is less sure of a
+// name selected from a value, whose type it doesn't know, or from
+// something it can't tell is a package; of a key in a composite literal,
+// which may be a field of any struct; and of a short name, which is more
+// likely to be shared by unrelated things. The score is rounded to two
+// decimal places.
+func (f *File) confidence(i *ast.Ident) float64 {
+	c := 1.0
+	switch p := f.parents[i].(type) {
+	case *ast.SelectorExpr:
+		if p.Sel != i {
+			break
+		}
+		x, ok := p.X.(*ast.Ident)
+		switch {
+		case ok && x.Obj == nil && f.importPath(x.Name) != "":
+			// Qualified by an imported package.
+		case ok && x.Obj != nil:
+			c *= 0.7 // a field or method of a value declared in f
+		default:
+			c *= 0.5
+		}
+	case *ast.KeyValueExpr:
+		if _, ok := f.parents[p].(*ast.CompositeLit); ok && p.Key == i {
+			c *= 0.7
+		}
+	}
+	switch {
+	case len(i.Name) <= 2:
+		c *= 0.5
+	case len(i.Name) <= 4:
+		c *= 0.8
+	}
+	return math.Round(c*100) / 100
+}
@@ -0,0 +1,190 @@
+package rename
+
+import (
+	"go/ast"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// A Conflict is a rename that Auto, IgnoreCase, or RenameReceivers skipped
+// because the new name is already taken in the same scope, either by an
+// existing declaration or by another identifier that would also have been
+// renamed to it.
+type Conflict struct {
+	Old  string `json:"old"`
+	New  string `json:"new"`
+	Pos  string `json:"pos"`  // where Old is declared
+	With string `json:"with"` // where the other claimant to New is declared
+}
+
+// Conflicts returns the conflicts that Rename found among the declarations
+// in the file.
+func (f *File) Conflicts() []Conflict { return f.conflicts }
+
+// A scope is a set of names that may not be declared twice: a package's
+// top-level names, a type's fields and methods, or a function's locals.
+type scope struct {
+	f     *File // the file declaring the names, if there is just one
+	names map[string]*ast.Ident
+	files map[*ast.Ident]*File
+}
+
+func newScope() *scope {
+	return &scope{names: make(map[string]*ast.Ident), files: make(map[*ast.Ident]*File)}
+}
+
+func (s *scope) add(f *File, id *ast.Ident) {
+	if id == nil || id.Name == "_" {
+		return
+	}
+	if _, ok := s.names[id.Name]; !ok {
+		s.names[id.Name] = id
+		s.files[id] = f
+	}
+}
+
+// findConflicts records, on the declaring files, every rename to what
+// newName gives that would collide with another name in its scope, and
+// returns the set of colliding old -> new pairs.
+func (r *renamer) findConflicts(files []*File, newName func(*ast.Ident) string) map[[2]string]bool {
+	var scopes []*scope
+	pkgs := make(map[string]*scope)
+	types := make(map[string]*scope)
+	typeScope := func(pkg, name string) *scope {
+		k := pkg + "." + name
+		if types[k] == nil {
+			types[k] = newScope()
+			scopes = append(scopes, types[k])
+		}
+		return types[k]
+	}
+	for _, f := range files {
+		pkg := filepath.Dir(f.Path) + ":" + f.f.Name.Name
+		if pkgs[pkg] == nil {
+			pkgs[pkg] = newScope()
+			scopes = append(scopes, pkgs[pkg])
+		}
+		for _, d := range f.f.Decls {
+			switch d := d.(type) {
+			case *ast.FuncDecl:
+				if d.Recv != nil && len(d.Recv.List) > 0 {
+					recv := strings.TrimPrefix(exprString(d.Recv.List[0].Type), "*")
+					typeScope(pkg, recv).add(f, d.Name)
+				} else {
+					pkgs[pkg].add(f, d.Name)
+				}
+				scopes = append(scopes, funcScope(f, d))
+			case *ast.GenDecl:
+				for _, spec := range d.Specs {
+					switch spec := spec.(type) {
+					case *ast.TypeSpec:
+						pkgs[pkg].add(f, spec.Name)
+						var fields *ast.FieldList
+						switch t := spec.Type.(type) {
+						case *ast.StructType:
+							fields = t.Fields
+						case *ast.InterfaceType:
+							fields = t.Methods
+						}
+						if fields != nil {
+							ts := typeScope(pkg, spec.Name.Name)
+							for _, field := range fields.List {
+								for _, n := range field.Names {
+									ts.add(f, n)
+								}
+							}
+						}
+					case *ast.ValueSpec:
+						for _, n := range spec.Names {
+							pkgs[pkg].add(f, n)
+						}
+					}
+				}
+			}
+		}
+	}
+
+	conflicts := make(map[[2]string]bool)
+	for _, s := range scopes {
+		// A name left alone keeps its old one, which may in turn be what
+		// another is renamed to, so this goes on until nothing new
+		// collides.
+		for found := true; found; {
+			found = false
+			// Group the names by what they will be called after renaming.
+			claims := make(map[string][]string)
+			for name := range s.names {
+				n := newName(s.names[name])
+				if conflicts[[2]string{name, n}] {
+					n = name
+				}
+				claims[n] = append(claims[n], name)
+			}
+			for n, olds := range claims {
+				if len(olds) < 2 {
+					continue
+				}
+				sort.Strings(olds)
+				for _, old := range olds {
+					if old == n {
+						continue
+					}
+					found = true
+					conflicts[[2]string{old, n}] = true
+					other := olds[0]
+					if other == old {
+						other = olds[1]
+					}
+					if existing, ok := s.names[n]; ok {
+						other = existing.Name
+					}
+					id, with := s.names[old], s.names[other]
+					f, wf := s.files[id], s.files[with]
+					f.conflicts = append(f.conflicts, Conflict{
+						Old:  old,
+						New:  n,
+						Pos:  f.fset.Position(id.Pos()).String(),
+						With: wf.fset.Position(with.Pos()).String(),
+					})
+				}
+			}
+		}
+	}
+	return conflicts
+}
+
+// funcScope returns the scope of the parameters and locals of fd. Nested
+// blocks and function literals are folded into it, which may report a
+// conflict that shadowing would have avoided, but never misses one.
+func funcScope(f *File, fd *ast.FuncDecl) *scope {
+	s := newScope()
+	ast.Inspect(fd, func(node ast.Node) bool {
+		id, ok := node.(*ast.Ident)
+		if !ok || id == fd.Name || id.Obj == nil {
+			return true
+		}
+		if p := id.Obj.Pos(); p == id.Pos() && p > fd.Pos() && p < fd.End() {
+			s.add(f, id)
+		}
+		return true
+	})
+	return s
+}
+
+// autoConflictName is what Auto would rename i to, for findConflicts.
+func (r *renamer) autoConflictName(i *ast.Ident) string {
+	if n := r.autoName(i.Name); r.autoExcluded(i, n) == "" {
+		return n
+	}
+	return i.Name
+}
+
+// caseConflictName is what From and To would rename i to, for findConflicts
+// with IgnoreCase, under which several names may match.
+func (r *renamer) caseConflictName(i *ast.Ident) string {
+	if n, ok := r.newName(i.Name); ok {
+		return n
+	}
+	return i.Name
+}
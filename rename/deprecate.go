@@ -0,0 +1,124 @@
+package rename
+
+import (
+	"bytes"
+	"go/ast"
+	"go/token"
+	"sort"
+	"strings"
+)
+
+// Deprecate returns, in place of the files Rename renamed, the changes
+// that add the new names of exported top-level declarations alongside the
+// old ones, for a migration in which the code using the old names may not
+// be rewritten yet. Each file that declares such a declaration is returned
+// as it was before Rename, with "Deprecated: Use New instead." added to
+// the declaration's doc comment. Each package also gets a file of the new
+// names, as type aliases, constants and variables set from the old names,
+// and wrapper functions and methods, named newnames.go, or newnames2.go and
+// so on if that name is taken. Rename's other renames, including all those
+// in test files and command packages, are dropped.
+func Deprecate(files []*File) (map[string][]byte, error) {
+	// The renamed files' declarations are printed with the new names, so
+	// the forwarders are written from the original ones instead.
+	originals := make([]*File, len(files))
+	for i, f := range files {
+		g := &File{Path: f.Path, src: f.src, f: f.f, fset: f.fset}
+		if len(f.declRenames) > 0 && f.isAPI() {
+			var err error
+			if g, err = ParseFile(f.Path, f.src); err != nil {
+				return nil, err
+			}
+			byPos := make(map[token.Pos]declSite)
+			for _, d := range topLevelDecls(g.f) {
+				byPos[d.node.Pos()] = d
+			}
+			// The forwarders declare the new names, forwarding to the old.
+			for _, dr := range f.declRenames {
+				if d, ok := byPos[dr.node.Pos()]; ok && ast.IsExported(dr.old) {
+					g.declRenames = append(g.declRenames, declRename{declSite: d, old: dr.new, new: dr.old})
+				}
+			}
+		}
+		originals[i] = g
+	}
+	out, err := forwarderFiles(originals, "newnames", func(f *File, dr declRename, imports map[string]string) (string, error) {
+		return f.forwarder(dr, imports, false)
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, g := range originals {
+		if len(g.declRenames) > 0 && g.isAPI() {
+			out[g.Path] = g.markDeprecated()
+		}
+	}
+	return out, nil
+}
+
+// markDeprecated returns f's source with a deprecation notice added to the
+// doc comment of each declaration in f.declRenames, whose new name is the
+// deprecated one and old name its replacement.
+func (f *File) markDeprecated() []byte {
+	// Notices by the offset of the line to insert them before.
+	notices := make(map[int][]string)
+	docs := make(map[int]bool)
+	for _, dr := range f.declRenames {
+		off, hasDoc := f.docLine(dr.node)
+		notices[off] = append(notices[off], dr.old)
+		docs[off] = hasDoc
+	}
+	var offs []int
+	for off := range notices {
+		offs = append(offs, off)
+	}
+	sort.Ints(offs)
+	var buf bytes.Buffer
+	last := 0
+	for _, off := range offs {
+		buf.Write(f.src[last:off])
+		line := f.src[off:]
+		indent := line[:len(line)-len(bytes.TrimLeft(line, " \t"))]
+		nl := "\n"
+		if f.crlf {
+			nl = "\r\n"
+		}
+		if docs[off] {
+			buf.Write(indent)
+			buf.WriteString("//" + nl)
+		}
+		buf.Write(indent)
+		buf.WriteString("// Deprecated: Use " + strings.Join(notices[off], " and ") + " instead." + nl)
+		last = off
+	}
+	buf.Write(f.src[last:])
+	return buf.Bytes()
+}
+
+// docLine returns the offset in f's source of the start of the line that
+// declares node, a top-level declaration or spec, and whether a doc
+// comment ends on the line before it.
+func (f *File) docLine(node ast.Node) (off int, hasDoc bool) {
+	start, doc := node.Pos(), (*ast.CommentGroup)(nil)
+	switch n := node.(type) {
+	case *ast.FuncDecl:
+		doc = n.Doc
+	case *ast.TypeSpec:
+		doc = n.Doc
+	case *ast.ValueSpec:
+		doc = n.Doc
+	}
+	// A spec outside parentheses is declared, and documented, by its
+	// GenDecl.
+	for _, d := range f.f.Decls {
+		if gd, ok := d.(*ast.GenDecl); ok && !gd.Lparen.IsValid() && gd.Pos() <= start && start < gd.End() {
+			start = gd.Pos()
+			if doc == nil {
+				doc = gd.Doc
+			}
+		}
+	}
+	tf := f.fset.File(start)
+	off = tf.Offset(tf.LineStart(tf.Line(start)))
+	return off, doc != nil && tf.Line(doc.End()) == tf.Line(start)-1
+}
@@ -0,0 +1,215 @@
+package rename
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"path"
+	"path/filepath"
+	"strconv"
+)
+
+// A DotImportUse is an unqualified identifier that a rename matched, in a
+// file that dot-imports packages, which Rename couldn't tell doesn't
+// belong to one of them. Renamed says whether it was renamed by name all
+// the same.
+type DotImportUse struct {
+	Pos     string   `json:"pos"` // file:line:column
+	Name    string   `json:"name"`
+	Renamed bool     `json:"renamed"`
+	Imports []string `json:"imports"` // the dot-imported paths
+}
+
+// DotImportUses returns the identifiers in f that may belong to a package
+// it dot-imports.
+func (f *File) DotImportUses() []DotImportUse { return f.dotImportUses }
+
+// dotInfo is what a Rename knows of a file that dot-imports packages.
+type dotInfo struct {
+	imports []string
+
+	// notBare are the identifiers that can't refer to a dot-imported
+	// name: selectors, fields, methods, and composite literal keys.
+	notBare map[*ast.Ident]bool
+
+	// objs hold, by offset, what each unqualified identifier that type
+	// checking resolved refers to, if Options.ResolveDotImports
+	// type-checked the file.
+	checked bool
+	objs    map[int]dotObj
+}
+
+// A dotObj is what an unqualified identifier refers to: whether it is an
+// object of a dot-imported package, and if so, which.
+type dotObj struct {
+	dot   bool
+	pkg   string // its package's name
+	path  string // and import path
+	inRun bool   // declared in one of the files given to Rename
+}
+
+// dotImports returns the paths of the packages f dot-imports.
+func dotImports(f *ast.File) []string {
+	var paths []string
+	for _, spec := range f.Imports {
+		if spec.Name != nil && spec.Name.Name == "." {
+			if p, err := strconv.Unquote(spec.Path.Value); err == nil {
+				paths = append(paths, p)
+			}
+		}
+	}
+	return paths
+}
+
+// notBare returns the identifiers of f that name selectors, fields,
+// methods, and composite literal keys.
+func notBare(f *ast.File) map[*ast.Ident]bool {
+	m := make(map[*ast.Ident]bool)
+	ast.Inspect(f, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.SelectorExpr:
+			m[n.Sel] = true
+		case *ast.Field:
+			for _, id := range n.Names {
+				m[id] = true
+			}
+		case *ast.FuncDecl:
+			if n.Recv != nil {
+				m[n.Name] = true
+			}
+		case *ast.CompositeLit:
+			for _, elt := range n.Elts {
+				if kv, ok := elt.(*ast.KeyValueExpr); ok {
+					if id, ok := kv.Key.(*ast.Ident); ok {
+						m[id] = true
+					}
+				}
+			}
+		}
+		return true
+	})
+	return m
+}
+
+// findDotImports notes which of files dot-import packages, for the rename
+// of From, and, with Options.ResolveDotImports, type-checks their packages
+// to find what their unqualified identifiers refer to.
+func (r *renamer) findDotImports(files []*File) {
+	dirs := make(map[string]bool)
+	for _, f := range files {
+		f.dot = nil
+		if f.broken || f.lite {
+			continue
+		}
+		if imports := dotImports(f.f); imports != nil {
+			f.dot = &dotInfo{imports: imports, notBare: notBare(f.f)}
+			dirs[filepath.Dir(f.Path)] = true
+		}
+	}
+	if len(dirs) == 0 {
+		return
+	}
+	if r.declared = r.pkgLevel; r.declared == nil {
+		r.declared = packageLevel(files)
+	}
+	if !r.ResolveDotImports {
+		return
+	}
+	var pkgFiles []*File
+	inRun := make(map[string]bool)
+	for _, f := range files {
+		if dirs[filepath.Dir(f.Path)] {
+			pkgFiles = append(pkgFiles, f)
+		}
+		if abs, err := filepath.Abs(f.Path); err == nil {
+			inRun[abs] = true
+		}
+	}
+	fset, pkgs, err := checkPackages(pkgFiles)
+	if err != nil {
+		return // each is renamed by name, as without ResolveDotImports
+	}
+	for _, p := range pkgs {
+		for i, f := range p.files {
+			if f.dot != nil {
+				f.dot.resolve(fset, p, p.asts[i], inRun)
+			}
+		}
+	}
+}
+
+// resolve records the objects of dot-imported packages that the
+// unqualified identifiers of af, the file type-checked in p, refer to.
+func (d *dotInfo) resolve(fset *token.FileSet, p *typedPkg, af *ast.File, inRun map[string]bool) {
+	dotted := make(map[*types.Package]bool)
+	for _, spec := range af.Imports {
+		if spec.Name != nil && spec.Name.Name == "." {
+			// The dot stands for the package it imports.
+			if pn, ok := p.info.Defs[spec.Name].(*types.PkgName); ok {
+				dotted[pn.Imported()] = true
+			}
+		}
+	}
+	sels := make(map[*ast.Ident]bool)
+	ast.Inspect(af, func(n ast.Node) bool {
+		if sel, ok := n.(*ast.SelectorExpr); ok {
+			sels[sel.Sel] = true
+		}
+		return true
+	})
+	d.checked = true
+	d.objs = make(map[int]dotObj)
+	for id, obj := range p.info.Uses {
+		if sels[id] {
+			continue
+		}
+		o := dotObj{dot: obj.Pkg() != nil && dotted[obj.Pkg()]}
+		if o.dot {
+			o.pkg, o.path = obj.Pkg().Name(), obj.Pkg().Path()
+			o.inRun = inRun[fset.Position(obj.Pos()).Filename]
+		}
+		d.objs[fset.Position(id.Pos()).Offset] = o
+	}
+}
+
+// renameDotImported renames i, an unqualified identifier in f, if it
+// belongs, or may belong, to a package that f dot-imports, and the rename
+// of From is of that package's name: with a qualified From, if the package
+// is the qualifier, and with an unqualified one, if it is among the files
+// or can't be told. It reports whether i was such an identifier, and
+// whether it changed. What can't be told is recorded in f.dotImportUses.
+func (r *renamer) renameDotImported(f *File, i *ast.Ident) (dotted, changed bool) {
+	d := f.dot
+	if d == nil || i.Obj != nil || d.notBare[i] || f.labels[i] || !r.matches(i.Name) {
+		return false, false
+	}
+	if r.declared[filepath.Dir(f.Path)][i.Name] || types.Universe.Lookup(i.Name) != nil {
+		return false, false
+	}
+	if d.checked {
+		if o, ok := d.objs[f.fset.Position(i.Pos()).Offset]; ok {
+			switch {
+			case !o.dot:
+				return false, false
+			case r.qualifier != "" && o.pkg == r.qualifier, r.qualifier == "" && o.inRun:
+				return true, r.renameTo(f, i)
+			}
+			r.trace(f, i, "left alone: from dot-imported package "+o.path)
+			return true, false
+		}
+	}
+	// Without type information, a qualified From is taken to name a
+	// dot-imported package by the last element of its path.
+	rename := r.qualifier == ""
+	for _, p := range d.imports {
+		rename = rename || path.Base(p) == r.qualifier
+	}
+	use := DotImportUse{Pos: f.fset.Position(i.Pos()).String(), Name: i.Name, Imports: d.imports}
+	if rename {
+		use.Renamed = r.renameTo(f, i)
+	} else {
+		r.trace(f, i, "left alone: may be from a dot-imported package")
+	}
+	f.dotImportUses = append(f.dotImportUses, use)
+	return true, use.Renamed
+}
@@ -0,0 +1,77 @@
+package rename
+
+import (
+	"fmt"
+	"go/token"
+	"go/types"
+	"strings"
+)
+
+// RenameField renames the field from of the struct type typ, such as
+// "UserRecord" or, to pick one of several packages that declare it,
+// "users.UserRecord", to to, along with its uses in selectors and keyed
+// composite literals. Unlike Rename, it type-checks each package, so the
+// same-named fields of other structs are left alone. Packages the files
+// import are type-checked from source and are not renamed in. If typ
+// already has a field or method named to, nothing changes and the clash is
+// reported with File.Conflicts, or as an error if typ is declared outside
+// files. It is an error if there is no struct type typ with the field.
+// Broken and generated files are skipped, except for mocks.
+func RenameField(files []*File, typ, from, to string) error {
+	if from == to {
+		return fmt.Errorf("rename: %s is already named %s", from, to)
+	}
+	qualifier, name := "", typ
+	if i := strings.LastIndex(typ, "."); i >= 0 {
+		qualifier, name = typ[:i], typ[i+1:]
+	}
+
+	fset, pkgs, err := checkPackages(files)
+	if err != nil {
+		return err
+	}
+
+	// Find the field, by the position of its declaration.
+	targets := make(map[token.Position]bool)
+	clashed := make(map[token.Position]bool)
+	structs, fields := 0, 0
+	for _, tn := range lookupTypes(pkgs, qualifier, name) {
+		st, ok := tn.Type().Underlying().(*types.Struct)
+		if !ok {
+			continue
+		}
+		structs++
+		for i := 0; i < st.NumFields(); i++ {
+			v := st.Field(i)
+			if v.Name() != from {
+				continue
+			}
+			fields++
+			if v.Embedded() {
+				return fmt.Errorf("rename: %s.%s is an embedded field; rename its type instead", typ, from)
+			}
+			pos := fset.Position(v.Pos())
+			if obj, _, _ := types.LookupFieldOrMethod(tn.Type(), true, tn.Pkg(), to); obj != nil {
+				if !clashed[pos] {
+					clashed[pos] = true
+					if err := typedConflict(files, fset, v, obj, typ, to); err != nil {
+						return err
+					}
+				}
+				continue
+			}
+			targets[pos] = true
+		}
+	}
+	switch {
+	case structs == 0:
+		return fmt.Errorf("rename: no struct type %s", typ)
+	case fields == 0:
+		return fmt.Errorf("rename: struct type %s has no field %s", typ, from)
+	}
+	renameTyped(fset, pkgs, from, to, func(obj types.Object) bool {
+		v, ok := obj.(*types.Var)
+		return ok && v.IsField() && targets[fset.Position(v.Origin().Pos())]
+	})
+	return nil
+}
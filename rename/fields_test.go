@@ -0,0 +1,106 @@
+package rename_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/jeremyschlatter/gorename-global/rename"
+)
+
+func TestRenameField(t *testing.T) {
+	const src = `package a
+
+type User struct{ Name string }
+
+type Team struct{ Name string }
+
+func f(u User, t Team) string {
+	u = User{Name: "x"}
+	return u.Name + t.Name + Team{Name: "y"}.Name
+}
+`
+	tests := []struct {
+		name          string
+		src           string
+		typ, from, to string
+		want          string // the new source, if it changes
+		wantConflicts []string
+		wantErr       string
+	}{
+		{
+			name: "renamed",
+			src:  src,
+			typ:  "User", from: "Name", to: "FullName",
+			want: `package a
+
+type User struct{ FullName string }
+
+type Team struct{ Name string }
+
+func f(u User, t Team) string {
+	u = User{FullName: "x"}
+	return u.FullName + t.Name + Team{Name: "y"}.Name
+}
+`,
+		},
+		{
+			name: "qualified",
+			src:  src,
+			typ:  "a.Team", from: "Name", to: "Title",
+			want: `package a
+
+type User struct{ Name string }
+
+type Team struct{ Title string }
+
+func f(u User, t Team) string {
+	u = User{Name: "x"}
+	return u.Name + t.Title + Team{Title: "y"}.Title
+}
+`,
+		},
+		{
+			name: "taken",
+			src:  "package a\n\ntype User struct{ Name, FullName string }\n\nvar _ = User{}.Name\n",
+			typ:  "User", from: "Name", to: "FullName",
+			wantConflicts: []string{"Name -> FullName"},
+		},
+		{
+			name: "no struct",
+			src:  src,
+			typ:  "Nope", from: "Name", to: "FullName",
+			wantErr: "rename: no struct type Nope",
+		},
+		{
+			name: "no field",
+			src:  src,
+			typ:  "User", from: "Age", to: "Years",
+			wantErr: "rename: struct type User has no field Age",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys := rename.MemFS{"a/a.go": []byte(tt.src)}
+			files, err := changeFS(t, fsys, func(files []*rename.File) error {
+				return rename.RenameField(files, tt.typ, tt.from, tt.to)
+			})
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("got error %v, want %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := tt.want
+			if want == "" {
+				want = tt.src
+			}
+			checkFS(t, fsys, map[string]string{"a/a.go": want})
+			if got := conflicts(files); !reflect.DeepEqual(got, tt.wantConflicts) {
+				t.Errorf("conflicts %q, want %q", got, tt.wantConflicts)
+			}
+		})
+	}
+}
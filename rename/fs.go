@@ -0,0 +1,176 @@
+package rename
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// A WriteFS is a file system that renamed files can be written back to.
+type WriteFS interface {
+	fs.FS
+
+	// WriteFile replaces the contents of the named file with data, keeping
+	// its permissions, or creates it with perm.
+	WriteFile(name string, data []byte, perm fs.FileMode) error
+}
+
+// ParseFS reads the named file from fsys and parses it, as ParseFile does.
+func ParseFS(fsys fs.FS, name string) (*File, error) {
+	src, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	return ParseFile(name, src)
+}
+
+// RenameFS renames identifiers in the named Go files of fsys, writes back
+// those that changed, and returns their names. Files with syntax errors are
+// consulted, as by Rename, rather than failing the run.
+func RenameFS(fsys WriteFS, names []string, opts Options) ([]string, error) {
+	var files []*File
+	for _, name := range names {
+		f, err := ParseFS(fsys, name)
+		if f == nil {
+			return nil, err
+		}
+		files = append(files, f)
+	}
+	if err := Rename(files, opts); err != nil {
+		return nil, err
+	}
+	var changed []string
+	for _, f := range files {
+		if !f.changed {
+			continue
+		}
+		src, err := f.Format()
+		if err != nil {
+			return changed, err
+		}
+		if err := fsys.WriteFile(f.Path, src, 0666); err != nil {
+			return changed, err
+		}
+		f.Written()
+		changed = append(changed, f.Path)
+	}
+	return changed, nil
+}
+
+// A MemFS is an in-memory WriteFS, for tests and for running where there
+// is no disk. It maps slash-separated file names, as accepted by
+// fs.ValidPath, to their contents. Directories are implied by the names of
+// the files in them.
+type MemFS map[string][]byte
+
+// Open opens the named file or directory.
+func (m MemFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	if data, ok := m[name]; ok {
+		return &memFile{memInfo{path.Base(name), int64(len(data)), false}, bytes.NewReader(data), nil}, nil
+	}
+	prefix := name + "/"
+	if name == "." {
+		prefix = ""
+	}
+	seen := make(map[string]bool)
+	var entries []fs.DirEntry
+	for n, data := range m {
+		rest, ok := strings.CutPrefix(n, prefix)
+		if !ok {
+			continue
+		}
+		entry := memInfo{rest, int64(len(data)), false}
+		if sub, _, ok := strings.Cut(rest, "/"); ok {
+			entry = memInfo{sub, 0, true}
+		}
+		if !seen[entry.name] {
+			seen[entry.name] = true
+			entries = append(entries, fs.FileInfoToDirEntry(entry))
+		}
+	}
+	if len(entries) == 0 && name != "." {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return &memFile{memInfo{path.Base(name), 0, true}, bytes.NewReader(nil), entries}, nil
+}
+
+// ReadFile returns a copy of the contents of the named file.
+func (m MemFS) ReadFile(name string) ([]byte, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: fs.ErrInvalid}
+	}
+	data, ok := m[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: fs.ErrNotExist}
+	}
+	return append([]byte(nil), data...), nil
+}
+
+// WriteFile sets the contents of the named file to a copy of data. MemFS
+// has no permissions, so perm is ignored.
+func (m MemFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	if !fs.ValidPath(name) || name == "." {
+		return &fs.PathError{Op: "write", Path: name, Err: fs.ErrInvalid}
+	}
+	m[name] = append([]byte(nil), data...)
+	return nil
+}
+
+type memInfo struct {
+	name string
+	size int64
+	dir  bool
+}
+
+func (i memInfo) Name() string       { return i.name }
+func (i memInfo) Size() int64        { return i.size }
+func (i memInfo) ModTime() time.Time { return time.Time{} }
+func (i memInfo) IsDir() bool        { return i.dir }
+func (i memInfo) Sys() interface{}   { return nil }
+
+func (i memInfo) Mode() fs.FileMode {
+	if i.dir {
+		return fs.ModeDir | 0555
+	}
+	return 0444
+}
+
+// A memFile is an open file or directory of a MemFS.
+type memFile struct {
+	info    memInfo
+	r       *bytes.Reader
+	entries []fs.DirEntry // of a directory, not yet read
+}
+
+func (f *memFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *memFile) Read(b []byte) (int, error) { return f.r.Read(b) }
+func (f *memFile) Close() error               { return nil }
+
+// ReadDir implements fs.ReadDirFile.
+func (f *memFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	if !f.info.dir {
+		return nil, &fs.PathError{Op: "readdir", Path: f.info.name, Err: fs.ErrInvalid}
+	}
+	if n <= 0 {
+		es := f.entries
+		f.entries = nil
+		return es, nil
+	}
+	if len(f.entries) == 0 {
+		return nil, io.EOF
+	}
+	if n > len(f.entries) {
+		n = len(f.entries)
+	}
+	es := f.entries[:n]
+	f.entries = f.entries[n:]
+	return es, nil
+}
@@ -0,0 +1,178 @@
+package rename
+
+import (
+	"go/ast"
+	"path/filepath"
+	"strings"
+)
+
+// generatedSuffixes are the file name endings used by protobuf, gRPC, and
+// Thrift code generators.
+var generatedSuffixes = []string{
+	".pb.go",
+	".pb.gw.go",
+	".pb.validate.go",
+	".twirp.go",
+	"-remote.go", // thrift
+}
+
+// generatedMarkers are phrases in the header comments of generated files
+// that lack a standard "DO NOT EDIT" line.
+var generatedMarkers = []string{
+	"Autogenerated by Thrift",
+	"generated by the protocol buffer compiler",
+	"Code generated by protoc-gen",
+}
+
+// looksGenerated reports whether f appears to be protobuf, gRPC, or Thrift
+// output, judging by its name and the comments before its package clause.
+func looksGenerated(f *File) bool {
+	base := filepath.Base(f.Path)
+	for _, s := range generatedSuffixes {
+		if strings.HasSuffix(base, s) {
+			return true
+		}
+	}
+	h := f.header()
+	for _, cg := range h.Comments {
+		if cg.Pos() > h.Package {
+			break
+		}
+		text := cg.Text()
+		for _, m := range generatedMarkers {
+			if strings.Contains(text, m) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// mockMarkers begin the header comments of mocks generated by gomock and
+// mockery.
+var mockMarkers = []string{
+	"Code generated by MockGen.",
+	"Code generated by mockery",
+}
+
+// Mock reports whether f is a gomock or mockery mock. Mocks mirror the
+// interfaces they implement, so they are renamed along with them rather
+// than left alone like other generated files, and tests keep compiling
+// without regenerating them.
+func (f *File) Mock() bool {
+	h := f.header()
+	for _, cg := range h.Comments {
+		if cg.Pos() > h.Package {
+			break
+		}
+		for _, c := range cg.List {
+			for _, m := range mockMarkers {
+				if strings.HasPrefix(strings.TrimSpace(strings.TrimPrefix(c.Text, "//")), m) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// isMessageType reports whether spec declares a struct with the bookkeeping
+// fields that protobuf generates for messages.
+func isMessageType(spec *ast.TypeSpec) bool {
+	st, ok := spec.Type.(*ast.StructType)
+	if !ok {
+		return false
+	}
+	for _, field := range st.Fields.List {
+		for _, n := range field.Names {
+			if strings.HasPrefix(n.Name, "XXX_") {
+				return true
+			}
+		}
+		if sel, ok := field.Type.(*ast.SelectorExpr); ok {
+			if x, ok := sel.X.(*ast.Ident); ok && x.Name == "protoimpl" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// generatedNames returns the names that Auto must leave alone because
+// generated code declares them: everything declared in files that are, or
+// look, generated, and the messages, fields, and methods of protobuf
+// message types wherever they are.
+func (r *renamer) generatedNames(files []*File) map[string]bool {
+	names := make(map[string]bool)
+	messages := make(map[string]bool)
+	for _, f := range files {
+		if looksGenerated(f) || !r.Generated && f.Generated() && !f.Mock() {
+			for _, n := range declaredNames(f.f) {
+				names[n.Name] = true
+			}
+			continue
+		}
+		for _, d := range f.f.Decls {
+			gd, ok := d.(*ast.GenDecl)
+			if !ok {
+				continue
+			}
+			for _, spec := range gd.Specs {
+				if ts, ok := spec.(*ast.TypeSpec); ok && isMessageType(ts) {
+					messages[ts.Name.Name] = true
+					names[ts.Name.Name] = true
+					for _, field := range ts.Type.(*ast.StructType).Fields.List {
+						for _, n := range field.Names {
+							names[n.Name] = true
+						}
+					}
+				}
+			}
+		}
+	}
+	for _, f := range files {
+		for _, d := range f.f.Decls {
+			if fd, ok := d.(*ast.FuncDecl); ok && fd.Recv != nil && len(fd.Recv.List) > 0 {
+				if messages[strings.TrimPrefix(exprString(fd.Recv.List[0].Type), "*")] {
+					names[fd.Name.Name] = true
+				}
+			}
+		}
+	}
+	return names
+}
+
+// declaredNames returns the identifiers of f's top-level declarations,
+// including methods, and the fields and interface methods of the types it
+// declares.
+func declaredNames(f *ast.File) []*ast.Ident {
+	var ids []*ast.Ident
+	for _, d := range f.Decls {
+		switch d := d.(type) {
+		case *ast.FuncDecl:
+			ids = append(ids, d.Name)
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				switch spec := spec.(type) {
+				case *ast.TypeSpec:
+					ids = append(ids, spec.Name)
+					var fields *ast.FieldList
+					switch t := spec.Type.(type) {
+					case *ast.StructType:
+						fields = t.Fields
+					case *ast.InterfaceType:
+						fields = t.Methods
+					}
+					if fields != nil {
+						for _, field := range fields.List {
+							ids = append(ids, field.Names...)
+						}
+					}
+				case *ast.ValueSpec:
+					ids = append(ids, spec.Names...)
+				}
+			}
+		}
+	}
+	return ids
+}
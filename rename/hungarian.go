@@ -0,0 +1,53 @@
+package rename
+
+import (
+	"go/token"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// HungarianPrefixes are the type and scope prefixes of Hungarian notation
+// that identifiers such as strName, pUser, and m_count start with.
+var HungarianPrefixes = []string{"arr", "b", "dw", "f", "i", "lp", "n", "p", "s", "str", "sz", "g_", "m_", "s_"}
+
+// stripHungarian returns name without the first of prefixes that it
+// starts with in Hungarian notation, keeping its exportedness, so that
+// "strName" becomes "name" and "m_count" "count". A prefix without an
+// underscore must be followed by a capitalized word, as in "pUser" but
+// not "pure" or "pURL", which might as well be an initialism. Names that
+// would be left a keyword, or nothing, are left alone.
+func stripHungarian(name string, prefixes []string) string {
+	for _, p := range prefixes {
+		rest, ok := strings.CutPrefix(name, p)
+		if q := exportedPrefix(p); !ok && q != "" {
+			rest, ok = strings.CutPrefix(name, q)
+		}
+		if !ok || rest == "" {
+			continue
+		}
+		if !strings.HasSuffix(p, "_") {
+			r, n := utf8.DecodeRuneInString(rest)
+			next, _ := utf8.DecodeRuneInString(rest[n:])
+			if !unicode.IsUpper(r) || !unicode.IsLower(next) {
+				continue
+			}
+		}
+		n := sameCase(rest, name)
+		if !token.IsIdentifier(n) || n == "_" {
+			continue
+		}
+		return n
+	}
+	return name
+}
+
+// exportedPrefix returns the prefix p as an exported name starts with it,
+// as "Str" for "str", or "" if it has no such form, as "m_" has none.
+func exportedPrefix(p string) string {
+	if strings.HasSuffix(p, "_") {
+		return ""
+	}
+	r, n := utf8.DecodeRuneInString(p)
+	return string(unicode.ToUpper(r)) + p[n:]
+}
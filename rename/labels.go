@@ -0,0 +1,82 @@
+package rename
+
+import (
+	"fmt"
+	"go/ast"
+)
+
+// labelIdents returns the identifiers in n that are statement labels,
+// which have a namespace of their own.
+func labelIdents(n ast.Node) map[*ast.Ident]bool {
+	labels := make(map[*ast.Ident]bool)
+	ast.Inspect(n, func(node ast.Node) bool {
+		switch n := node.(type) {
+		case *ast.LabeledStmt:
+			labels[n.Label] = true
+		case *ast.BranchStmt:
+			if n.Label != nil {
+				labels[n.Label] = true
+			}
+		}
+		return true
+	})
+	return labels
+}
+
+// RenameLabels renames the statement label from, and the goto, break, and
+// continue statements that refer to it, to to, in the function fn, or in
+// every function if fn is empty. fn is written as for RenameResults. If a
+// function already has a label named to, it is left alone and the clash
+// reported with File.Conflicts.
+func RenameLabels(files []*File, fn, from, to string) error {
+	if from == to {
+		return fmt.Errorf("rename: %s is already named %s", from, to)
+	}
+	return eachFile(files, func(f *File) error {
+		if f.broken || f.Generated() && !f.Mock() {
+			return nil
+		}
+		for _, d := range f.f.Decls {
+			if fd, ok := d.(*ast.FuncDecl); ok && fd.Body != nil && (fn == "" || isFunc(fd, fn)) {
+				f.renameLabel(fd, from, to)
+			}
+		}
+		return nil
+	})
+}
+
+// renameLabel renames the label from to to in fd.
+func (f *File) renameLabel(fd *ast.FuncDecl, from, to string) {
+	var uses []*ast.Ident
+	var first, clash *ast.Ident
+	for id := range labelIdents(fd) {
+		switch id.Name {
+		case from:
+			uses = append(uses, id)
+		case to:
+			clash = id
+		}
+	}
+	if len(uses) == 0 {
+		return
+	}
+	for _, id := range uses {
+		if first == nil || id.Pos() < first.Pos() {
+			first = id
+		}
+	}
+	if clash != nil {
+		f.conflicts = append(f.conflicts, Conflict{
+			Old:  from,
+			New:  to,
+			Pos:  f.fset.Position(first.Pos()).String(),
+			With: f.fset.Position(clash.Pos()).String(),
+		})
+		return
+	}
+	for _, id := range uses {
+		id.Name = to
+		f.renamed(from, to)
+	}
+	f.changed = true
+}
@@ -0,0 +1,195 @@
+package rename
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Copied from go lint, but for taking the initialisms to upper-case.
+// lintName returns a different name if it should be different.
+func lintName(name string, initialisms map[string]bool) (should string) {
+	// Fast path for simple cases: "_" and all lowercase.
+	if name == "_" {
+		return name
+	}
+	allLower := true
+	for _, r := range name {
+		if !unicode.IsLower(r) {
+			allLower = false
+			break
+		}
+	}
+	if allLower {
+		return name
+	}
+
+	// Split camelCase at any lower->upper transition, and split on underscores.
+	// Check each word for common initialisms.
+	runes := []rune(name)
+	w, i := 0, 0 // index of start of word, scan
+	for i+1 <= len(runes) {
+		eow := false // whether we hit the end of a word
+		if i+1 == len(runes) {
+			eow = true
+		} else if runes[i+1] == '_' {
+			// underscore; shift the remainder forward over any run of underscores
+			eow = true
+			n := 1
+			for i+n+1 < len(runes) && runes[i+n+1] == '_' {
+				n++
+			}
+
+			// Leave at most one underscore if the underscore is between two digits
+			if i+n+1 < len(runes) && unicode.IsDigit(runes[i]) && unicode.IsDigit(runes[i+n+1]) {
+				n--
+			}
+
+			copy(runes[i+1:], runes[i+n+1:])
+			runes = runes[:len(runes)-n]
+		} else if unicode.IsLower(runes[i]) && !unicode.IsLower(runes[i+1]) {
+			// lower->non-lower
+			eow = true
+		}
+		i++
+		if !eow {
+			continue
+		}
+
+		// [w,i) is a word.
+		word := string(runes[w:i])
+		if u := strings.ToUpper(word); initialisms[u] {
+			// Keep consistent case, which is lowercase only at the start.
+			if w == 0 && unicode.IsLower(runes[w]) {
+				u = strings.ToLower(u)
+			}
+			// All the common initialisms are ASCII,
+			// so we can replace the bytes exactly.
+			copy(runes[w:], []rune(u))
+		} else if w > 0 && strings.ToLower(word) == word {
+			// already all lowercase, and not the first word, so uppercase the first character.
+			runes[w] = unicode.ToUpper(runes[w])
+		}
+		w = i
+	}
+	return string(runes)
+}
+
+// words splits name into words the way lintName does, at underscores and
+// lower-to-upper transitions, and also before the last capital of a run
+// that starts a new word, so "HTTPServer" is "HTTP" and "Server" but "IDs"
+// is one word. Runs of
+// underscores are words of their own, so the words always join to name.
+func words(name string) []string {
+	runes := []rune(name)
+	var ws []string
+	w := 0
+	for i := 1; i <= len(runes); i++ {
+		eow := i == len(runes)
+		if !eow {
+			prev, r := runes[i-1], runes[i]
+			switch {
+			case (prev == '_') != (r == '_'):
+				eow = true
+			case unicode.IsLower(prev) && !unicode.IsLower(r):
+				eow = true
+			case unicode.IsUpper(prev) && unicode.IsUpper(r) &&
+				i+1 < len(runes) && unicode.IsLower(runes[i+1]) &&
+				!pluralS(runes[i+1:]):
+				eow = true
+			}
+		}
+		if eow {
+			ws = append(ws, string(runes[w:i]))
+			w = i
+		}
+	}
+	return ws
+}
+
+// pluralS reports whether rest, which follows a run of capitals, is just the
+// "s" of a plural initialism such as "IDs", with perhaps another word after.
+func pluralS(rest []rune) bool {
+	return rest[0] == 's' && (len(rest) == 1 || !unicode.IsLower(rest[1]))
+}
+
+// CommonInitialisms returns the initialisms that 'go lint' knows, which
+// Options.Initialisms may replace, in order.
+func CommonInitialisms() []string {
+	var is []string
+	for i := range commonInitialisms {
+		is = append(is, i)
+	}
+	sort.Strings(is)
+	return is
+}
+
+// Copied from go lint.
+var commonInitialisms = map[string]bool{
+	"API":   true,
+	"ASCII": true,
+	"CPU":   true,
+	"CSS":   true,
+	"DNS":   true,
+	"EOF":   true,
+	"GUID":  true,
+	"HTML":  true,
+	"HTTP":  true,
+	"HTTPS": true,
+	"ID":    true,
+	"IP":    true,
+	"JSON":  true,
+	"LHS":   true,
+	"QPS":   true,
+	"RAM":   true,
+	"RHS":   true,
+	"RPC":   true,
+	"SLA":   true,
+	"SMTP":  true,
+	"SQL":   true,
+	"SSH":   true,
+	"TCP":   true,
+	"TLS":   true,
+	"TTL":   true,
+	"UDP":   true,
+	"UI":    true,
+	"UID":   true,
+	"UUID":  true,
+	"URI":   true,
+	"URL":   true,
+	"UTF8":  true,
+	"VM":    true,
+	"XML":   true,
+	"XSRF":  true,
+	"XSS":   true,
+}
+
+// replaceWords replaces each maximal run of identifier characters in s that
+// is a key of renames with its value.
+func replaceWords(s string, renames map[string]string) string {
+	isIdent := func(r rune) bool {
+		return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+	}
+	var buf bytes.Buffer
+	for len(s) > 0 {
+		i := strings.IndexFunc(s, isIdent)
+		if i < 0 {
+			buf.WriteString(s)
+			break
+		}
+		buf.WriteString(s[:i])
+		s = s[i:]
+		j := strings.IndexFunc(s, func(r rune) bool { return !isIdent(r) })
+		if j < 0 {
+			j = len(s)
+		}
+		if n, ok := renames[s[:j]]; ok {
+			buf.WriteString(n)
+		} else {
+			buf.WriteString(s[:j])
+		}
+		s = s[j:]
+	}
+	return buf.String()
+}
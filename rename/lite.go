@@ -0,0 +1,98 @@
+package rename
+
+import (
+	"bytes"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"sync"
+)
+
+// lowerBufs hold the lower-cased copies of sources that ParseFileFor
+// searches, to be reused from file to file.
+var lowerBufs = sync.Pool{New: func() any { return new([]byte) }}
+
+// ParseFileFor is ParseFile for a Rename that can only rename identifiers
+// with the given names, parts of names, or words, by whatever case, as
+// one with From, or with Match deciding on a set of names, does. A file
+// whose source contains none of them can't change, so it is parsed faster,
+// without comments other than its header, or object resolution, and Rename
+// only consults it, for what it declares. Files that may change are still
+// parsed in full, since they are printed from the syntax tree, comments
+// and all.
+func ParseFileFor(path string, src []byte, names []string) (*File, error) {
+	if len(names) == 0 || mentionsAny(src, names) {
+		return ParseFile(path, src)
+	}
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, path, src, parser.SkipObjectResolution)
+	if f == nil {
+		return nil, err
+	}
+	// The comments above the package clause say if the file is generated.
+	head, _ := parser.ParseFile(token.NewFileSet(), path, src, parser.PackageClauseOnly|parser.ParseComments)
+	if head == nil {
+		head = f
+	}
+	file := &File{Path: path, src: src, fset: fset, f: f, head: head, lite: true, renames: make(map[string]string), counts: make(map[string]int)}
+	file.broken = err != nil
+	return file, err
+}
+
+// mentionsAny reports whether src contains any of names, ignoring case.
+func mentionsAny(src []byte, names []string) bool {
+	buf := lowerBufs.Get().(*[]byte)
+	defer lowerBufs.Put(buf)
+	lower := append((*buf)[:0], src...)
+	for i, c := range lower {
+		if 'A' <= c && c <= 'Z' {
+			lower[i] = c + 'a' - 'A'
+		}
+	}
+	*buf = lower
+	for _, n := range names {
+		if bytes.Contains(lower, bytes.ToLower([]byte(n))) {
+			return true
+		}
+	}
+	return false
+}
+
+// header returns the part of the file's syntax tree holding the comments
+// above its package clause.
+func (f *File) header() *ast.File {
+	if f.head != nil {
+		return f.head
+	}
+	return f.f
+}
+
+// declaredKinds returns what Declared does for a file parsed without
+// object resolution, from its declarations.
+func declaredKinds(f *ast.File) map[string]string {
+	m := make(map[string]string)
+	for _, d := range f.Decls {
+		switch d := d.(type) {
+		case *ast.FuncDecl:
+			if d.Recv == nil && d.Name.Name != "init" && d.Name.Name != "_" {
+				m[d.Name.Name] = "func"
+			}
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				switch spec := spec.(type) {
+				case *ast.TypeSpec:
+					if spec.Name.Name != "_" {
+						m[spec.Name.Name] = "type"
+					}
+				case *ast.ValueSpec:
+					for _, n := range spec.Names {
+						if n.Name != "_" {
+							m[n.Name] = d.Tok.String()
+						}
+					}
+				}
+			}
+		}
+	}
+	return m
+}
@@ -0,0 +1,76 @@
+package rename
+
+import "strings"
+
+// The rules that Options.AutoRules can name to respell words between
+// American and British English. Being each other's opposite, neither is
+// among AllRules.
+const (
+	RuleUSSpelling = "us-spelling" // Colour becomes Color, Initialise Initialize
+	RuleUKSpelling = "uk-spelling" // Color becomes Colour, Initialize Initialise
+)
+
+// LocaleRules are the rules that respell words by locale.
+var LocaleRules = []string{RuleUSSpelling, RuleUKSpelling}
+
+// The British words, in lower case, by their American spellings, and the
+// other way around, filled in by init from the stems below.
+var usToUK, ukToUS = make(map[string]string), make(map[string]string)
+
+// The stems of words spelled -ize in American English and -ise in British,
+// such as initial in initialize.
+var izeStems = []string{
+	"author", "capital", "categor", "central", "custom", "emphas", "final",
+	"general", "initial", "local", "maxim", "memor", "minim", "normal",
+	"optim", "organ", "parameter", "priorit", "real", "recogn", "sanit",
+	"serial", "special", "standard", "summar", "synchron", "token", "util",
+	"virtual", "visual",
+}
+
+// The stems of words spelled -or in American English and -our in British,
+// such as col in color.
+var ourStems = []string{"behavi", "col", "endeav", "fav", "flav", "hon", "hum", "lab", "neighb", "rum"}
+
+// The other pairs, American first.
+var localeWords = [][2]string{
+	{"analyze", "analyse"}, {"analyzed", "analysed"}, {"analyzer", "analyser"}, {"analyzers", "analysers"}, {"analyzing", "analysing"},
+	{"artifact", "artefact"}, {"artifacts", "artefacts"},
+	{"canceled", "cancelled"}, {"canceling", "cancelling"},
+	{"catalog", "catalogue"}, {"catalogs", "catalogues"},
+	{"center", "centre"}, {"centers", "centres"}, {"centered", "centred"},
+	{"defense", "defence"}, {"offense", "offence"},
+	{"dialog", "dialogue"}, {"dialogs", "dialogues"},
+	{"gray", "grey"},
+	{"labeled", "labelled"}, {"labeling", "labelling"},
+	{"modeled", "modelled"}, {"modeling", "modelling"},
+	{"traveled", "travelled"}, {"traveling", "travelling"},
+}
+
+func init() {
+	add := func(us, uk string) {
+		usToUK[us] = uk
+		ukToUS[uk] = us
+	}
+	for _, s := range izeStems {
+		for _, suffix := range []string{"e", "ed", "er", "ers", "es", "ing", "ation", "ations"} {
+			add(s+"iz"+suffix, s+"is"+suffix)
+		}
+	}
+	for _, s := range ourStems {
+		for _, suffix := range []string{"", "s", "ed", "ing", "able", "ful"} {
+			add(s+"or"+suffix, s+"our"+suffix)
+		}
+	}
+	for _, p := range localeWords {
+		add(p[0], p[1])
+	}
+}
+
+// respell returns name with each of its camelCase words that respellings
+// has a spelling for respelled, in the case of the word it replaces.
+func respell(name string, respellings map[string]string) string {
+	return replaceEachWord(name, func(w string) (string, bool) {
+		c, ok := respellings[strings.ToLower(w)]
+		return c, ok
+	})
+}
@@ -0,0 +1,126 @@
+package rename
+
+import (
+	"bytes"
+	"go/ast"
+	"strings"
+)
+
+// A pair is one rename that From and To expand to.
+type pair struct {
+	from, to  string
+	fromWords []string // from split into words, for Word
+}
+
+// expand returns the pairs for renaming from to to.
+func (r *renamer) expand(from, to string) []pair {
+	ps := []pair{{from: from, to: to}}
+	if r.Plurals {
+		if pf := pluralize(from, r.PluralOverrides); pf != from {
+			ps = append(ps, pair{from: pf, to: pluralize(to, r.PluralOverrides)})
+		}
+	}
+	for _, t := range r.Companions {
+		cf, ct := companion(t, from), companion(t, to)
+		ps = append(ps, pair{from: cf, to: ct})
+		// Companions come in both exported and unexported forms, as with
+		// FooImpl and fooImpl.
+		if ast.IsExported(cf) {
+			ps = append(ps, pair{from: unexport(cf), to: unexport(ct)})
+		} else {
+			ps = append(ps, pair{from: export(cf), to: export(ct)})
+		}
+	}
+	for i := range ps {
+		ps[i].fromWords = words(ps[i].from)
+		if r.ToTemplate != "" {
+			ps[i].to = fillTemplate(r.ToTemplate, ps[i].from)
+		}
+	}
+	return ps
+}
+
+// companion fills in the {} in template t with name, capitalized unless
+// it begins t, so that "New{}" and "foo" give "NewFoo".
+func companion(t, name string) string {
+	i := strings.Index(t, "{}")
+	if i > 0 {
+		name = export(name)
+	}
+	return t[:i] + name + t[i+2:]
+}
+
+// newName returns what an identifier named name should be renamed to under
+// From and To, and whether it matches From at all.
+func (r *renamer) newName(name string) (string, bool) {
+	for _, p := range r.pairs {
+		switch {
+		case name == p.from:
+			return p.to, true
+		case r.IgnoreCase && strings.EqualFold(name, p.from):
+			return sameCase(p.to, name), true
+		}
+	}
+	if r.Word {
+		return r.replaceWords(name)
+	}
+	return "", false
+}
+
+func (r *renamer) matches(name string) bool {
+	_, ok := r.newName(name)
+	return ok
+}
+
+// replaceWords replaces each run of words in name that matches the words of
+// a pair, cased to agree with the words it replaces.
+func (r *renamer) replaceWords(name string) (string, bool) {
+	ws := words(name)
+	var buf bytes.Buffer
+	found := false
+	for i := 0; i < len(ws); {
+		p, j := r.matchWords(ws[i:])
+		if p == nil {
+			buf.WriteString(ws[i])
+			i++
+			continue
+		}
+		buf.WriteString(p.caseLike(strings.Join(ws[i:i+j], "")))
+		i += j
+		found = true
+	}
+	return buf.String(), found
+}
+
+// matchWords returns the pair whose words ws begins with, and how many
+// words it matched, or nil if there is none.
+func (r *renamer) matchWords(ws []string) (*pair, int) {
+	for k := range r.pairs {
+		p := &r.pairs[k]
+		if len(p.fromWords) > len(ws) {
+			continue
+		}
+		ok := true
+		for i, w := range p.fromWords {
+			if !strings.EqualFold(w, ws[i]) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return p, len(p.fromWords)
+		}
+	}
+	return nil, 0
+}
+
+// caseLike returns p.to cased the way match, which matched p.from, is.
+func (p *pair) caseLike(match string) string {
+	switch {
+	case match == p.from:
+		return p.to
+	case isUpper(match) && !isUpper(p.from):
+		return strings.ToUpper(p.to)
+	}
+	return sameCase(p.to, match)
+}
@@ -0,0 +1,264 @@
+package rename
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// A mergeDecl is a top-level declaration that Merge considers, in f.
+type mergeDecl struct {
+	declSite
+	f     *File
+	gd    *ast.GenDecl // the declaration of a TypeSpec or ValueSpec
+	name  *ast.Ident
+	recvs bool // a type that methods are declared on
+}
+
+// Merge prepares for renaming from to to where a package already declares
+// both at the top level, by deleting the declaration of whichever stands
+// for the other, so that the rename merges the two rather than declaring
+// to twice. From may be qualified with a package name, as in Rename.
+//
+// The two must be compatible: one an alias of the other, as with
+// "type from = to", "const from = to", or "var from = to"; a function that
+// only calls the other, with the same signature and its own parameters in
+// order; or both the same but for the name, in which case from's is
+// deleted, unless it is a type with methods. Otherwise, or if no package
+// declares both, Merge returns an error. The changed files are reparsed,
+// so that Rename rewrites them as any other.
+func Merge(files []*File, from, to string) error {
+	qualifier := ""
+	if i := strings.LastIndex(from, "."); i >= 0 {
+		qualifier, from = from[:i], from[i+1:]
+	}
+	if j := strings.LastIndex(to, "."); j >= 0 {
+		to = to[j+1:]
+	}
+	pkgs := make(map[string][]*File) // by directory
+	for _, f := range files {
+		if f.broken || qualifier != "" && f.f.Name.Name != qualifier {
+			continue
+		}
+		dir := filepath.Dir(f.Path)
+		pkgs[dir] = append(pkgs[dir], f)
+	}
+	var dirs []string
+	for dir := range pkgs {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	merged := false
+	for _, dir := range dirs {
+		a, b := findMergeDecl(pkgs[dir], from), findMergeDecl(pkgs[dir], to)
+		if a == nil || b == nil {
+			continue
+		}
+		var dup *mergeDecl
+		switch {
+		case a.forwards(b):
+			dup = a
+		case b.forwards(a):
+			dup = b
+		case a.same(b) && !a.recvs:
+			dup = a
+		default:
+			return fmt.Errorf("rename: %s, at %s, can't be merged into %s, at %s: neither is an alias of the other, nor are they the same but for the name", from, a.pos(), to, b.pos())
+		}
+		if err := dup.delete(); err != nil {
+			return err
+		}
+		merged = true
+	}
+	if !merged {
+		return fmt.Errorf("rename: no package declares both %s and %s to merge", from, to)
+	}
+	return nil
+}
+
+// findMergeDecl returns the top-level declaration of name among files, or
+// nil.
+func findMergeDecl(files []*File, name string) *mergeDecl {
+	var found *mergeDecl
+	recvs := false
+	for _, f := range files {
+		for _, d := range f.f.Decls {
+			switch d := d.(type) {
+			case *ast.FuncDecl:
+				if d.Recv == nil && d.Name.Name == name {
+					found = &mergeDecl{declSite: declSite{node: d}, f: f, name: d.Name}
+				} else if d.Recv != nil && len(d.Recv.List) == 1 && recvType(d.Recv.List[0].Type) == name {
+					recvs = true
+				}
+			case *ast.GenDecl:
+				for _, spec := range d.Specs {
+					switch s := spec.(type) {
+					case *ast.TypeSpec:
+						if s.Name.Name == name {
+							found = &mergeDecl{declSite: declSite{node: s}, f: f, gd: d, name: s.Name}
+						}
+					case *ast.ValueSpec:
+						for _, n := range s.Names {
+							if n.Name == name {
+								found = &mergeDecl{declSite: declSite{node: s, tok: d.Tok}, f: f, gd: d, name: n}
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+	if found != nil {
+		found.recvs = recvs
+	}
+	return found
+}
+
+func (d *mergeDecl) pos() token.Position { return d.f.fset.Position(d.name.Pos()) }
+
+// forwards reports whether d only stands for e: it is an alias of e, or a
+// function that calls e with its own parameters and is otherwise the same.
+func (d *mergeDecl) forwards(e *mergeDecl) bool {
+	isTo := func(x ast.Expr) bool {
+		id, ok := x.(*ast.Ident)
+		return ok && id.Name == e.name.Name
+	}
+	switch n := d.node.(type) {
+	case *ast.TypeSpec:
+		_, ok := e.node.(*ast.TypeSpec)
+		return ok && n.Assign.IsValid() && n.TypeParams == nil && isTo(n.Type)
+	case *ast.ValueSpec:
+		return len(n.Names) == 1 && n.Type == nil && len(n.Values) == 1 && isTo(n.Values[0])
+	case *ast.FuncDecl:
+		m, ok := e.node.(*ast.FuncDecl)
+		if !ok || n.Type.TypeParams != nil || n.Body == nil || len(n.Body.List) != 1 ||
+			d.f.fieldTypes(n.Type.Params) != e.f.fieldTypes(m.Type.Params) || d.f.fieldTypes(n.Type.Results) != e.f.fieldTypes(m.Type.Results) {
+			return false
+		}
+		var call ast.Expr
+		switch s := n.Body.List[0].(type) {
+		case *ast.ReturnStmt:
+			if len(s.Results) == 1 {
+				call = s.Results[0]
+			}
+		case *ast.ExprStmt:
+			if n.Type.Results == nil {
+				call = s.X
+			}
+		}
+		c, ok := call.(*ast.CallExpr)
+		if !ok || !isTo(c.Fun) {
+			return false
+		}
+		var params []*ast.Ident
+		variadic := false
+		for _, p := range n.Type.Params.List {
+			params = append(params, p.Names...)
+			_, variadic = p.Type.(*ast.Ellipsis)
+		}
+		if len(params) != len(c.Args) {
+			return false
+		}
+		for i, p := range params {
+			if id, ok := c.Args[i].(*ast.Ident); !ok || p.Name == "_" || id.Name != p.Name {
+				return false
+			}
+		}
+		return variadic == c.Ellipsis.IsValid()
+	}
+	return false
+}
+
+// same reports whether d and e are declared the same but for their names.
+func (d *mergeDecl) same(e *mergeDecl) bool {
+	if d.tok != e.tok {
+		return false
+	}
+	without := func(d *mergeDecl) string {
+		src := d.f.src
+		return squeeze(src[d.f.offset(d.node.Pos()):d.f.offset(d.name.Pos())]) + " _ " + squeeze(src[d.f.offset(d.name.End()):d.f.offset(d.node.End())])
+	}
+	return without(d) == without(e)
+}
+
+// fieldTypes returns the types of the fields in fl, one for each name, as
+// they are written, so that "a, b int" and "x int, y int" have the same.
+func (f *File) fieldTypes(fl *ast.FieldList) string {
+	if fl == nil {
+		return ""
+	}
+	var ts []string
+	for _, field := range fl.List {
+		t := squeeze(f.src[f.offset(field.Type.Pos()):f.offset(field.Type.End())])
+		for i := 0; i < max(1, len(field.Names)); i++ {
+			ts = append(ts, t)
+		}
+	}
+	return strings.Join(ts, ", ")
+}
+
+// squeeze returns src with its runs of space made single spaces.
+func squeeze(src []byte) string { return strings.Join(strings.Fields(string(src)), " ") }
+
+// delete deletes d, with its doc comment, and reparses its file.
+func (d *mergeDecl) delete() error {
+	var c cut
+	switch n := d.node.(type) {
+	case *ast.FuncDecl:
+		c = d.f.cut(n, n.Doc, "")
+	case *ast.TypeSpec:
+		c = d.f.cutSpec(d.gd, n, n.Doc)
+	case *ast.ValueSpec:
+		if len(n.Names) > 1 {
+			return fmt.Errorf("rename: %s is declared along with others, at %s", d.name.Name, d.pos())
+		}
+		if d.tok == token.CONST && len(d.gd.Specs) > 1 && laterImplicit(d.gd, n) {
+			return fmt.Errorf("rename: %s is declared in a const block whose later iota or implicit values deleting it would change, at %s", d.name.Name, d.pos())
+		}
+		c = d.f.cutSpec(d.gd, n, n.Doc)
+	}
+	return d.f.reparse([]edit{{c.start, c.end, ""}})
+}
+
+// cutSpec returns the cut of spec, from gd: the whole declaration if spec
+// is all it declares.
+func (f *File) cutSpec(gd *ast.GenDecl, spec ast.Spec, doc *ast.CommentGroup) cut {
+	if len(gd.Specs) == 1 {
+		return f.cut(gd, gd.Doc, "")
+	}
+	return f.cut(spec, doc, gd.Tok.String())
+}
+
+// laterImplicit reports whether a spec after spec in the const block gd
+// repeats an earlier value or uses iota, either of which depends on where
+// it is in the block.
+func laterImplicit(gd *ast.GenDecl, spec *ast.ValueSpec) bool {
+	after := false
+	for _, s := range gd.Specs {
+		if s == ast.Spec(spec) {
+			after = true
+			continue
+		}
+		if !after {
+			continue
+		}
+		vs := s.(*ast.ValueSpec)
+		if len(vs.Values) == 0 {
+			return true
+		}
+		iota := false
+		ast.Inspect(vs, func(n ast.Node) bool {
+			if id, ok := n.(*ast.Ident); ok && id.Name == "iota" {
+				iota = true
+			}
+			return !iota
+		})
+		if iota {
+			return true
+		}
+	}
+	return false
+}
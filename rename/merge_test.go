@@ -0,0 +1,102 @@
+package rename_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jeremyschlatter/gorename-global/rename"
+)
+
+// mergeFS merges from into to in fsys and renames from to to, as -merge
+// does, and returns fsys with the changed files written back.
+func mergeFS(t *testing.T, fsys rename.MemFS, from, to string) (rename.MemFS, error) {
+	t.Helper()
+	_, err := changeFS(t, fsys, func(files []*rename.File) error {
+		if err := rename.Merge(files, from, to); err != nil {
+			return err
+		}
+		return rename.Rename(files, rename.Options{From: from, To: to})
+	})
+	return fsys, err
+}
+
+func TestMerge(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		from, to string
+		src      map[string]string
+		want     map[string]string
+	}{
+		{
+			name: "from is an alias",
+			from: "Client", to: "Conn",
+			src: map[string]string{
+				"p/conn.go": "package p\n\ntype Conn struct{ addr string }\n",
+				"p/old.go":  "package p\n\n// Client is the old name of Conn.\ntype Client = Conn\n\nfunc Dial() *Client { return &Client{} }\n",
+			},
+			want: map[string]string{
+				"p/conn.go": "package p\n\ntype Conn struct{ addr string }\n",
+				"p/old.go":  "package p\n\nfunc Dial() *Conn { return &Conn{} }\n",
+			},
+		},
+		{
+			name: "to is an alias",
+			from: "Client", to: "Conn",
+			src: map[string]string{
+				"p/p.go": "package p\n\ntype Client struct{ addr string }\n\ntype (\n\t// Conn is the new name of Client.\n\tConn = Client\n\tPort int\n)\n",
+			},
+			want: map[string]string{
+				"p/p.go": "package p\n\ntype Conn struct{ addr string }\n\ntype (\n\tPort int\n)\n",
+			},
+		},
+		{
+			name: "from is a forwarder",
+			from: "Sum", to: "Add",
+			src: map[string]string{
+				"p/p.go": "package p\n\nfunc Add(xs ...int) (n int) {\n\tfor _, x := range xs {\n\t\tn += x\n\t}\n\treturn n\n}\n\n// Sum is Add.\nfunc Sum(ys ...int) int { return Add(ys...) }\n\nvar Three = Sum(1, 2)\n",
+			},
+			want: map[string]string{
+				"p/p.go": "package p\n\nfunc Add(xs ...int) (n int) {\n\tfor _, x := range xs {\n\t\tn += x\n\t}\n\treturn n\n}\n\nvar Three = Add(1, 2)\n",
+			},
+		},
+		{
+			name: "the same but for the name",
+			from: "DefaultPort", to: "Port",
+			src: map[string]string{
+				"p/p.go": "package p\n\nconst (\n\tPort        = 8080\n\tDefaultPort = 8080\n)\n\nvar addr = DefaultPort\n",
+			},
+			want: map[string]string{
+				"p/p.go": "package p\n\nconst (\n\tPort = 8080\n)\n\nvar addr = Port\n",
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys := rename.MemFS{}
+			for name, src := range tt.src {
+				fsys[name] = []byte(src)
+			}
+			got, err := mergeFS(t, fsys, tt.from, tt.to)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkFS(t, got, tt.want)
+		})
+	}
+}
+
+func TestMergeDifferent(t *testing.T) {
+	for _, src := range []string{
+		"package p\n\nfunc Old() int { return 1 }\n\nfunc New() int { return 2 }\n",
+		"package p\n\nfunc Old(a, b int) int { return New(b, a) }\n\nfunc New(a, b int) int { return a - b }\n",
+		"package p\n\nconst Old = 1\n\nconst New = 2\n",
+	} {
+		fsys := rename.MemFS{"p/p.go": []byte(src)}
+		_, err := mergeFS(t, fsys, "Old", "New")
+		if err == nil || !strings.Contains(err.Error(), "can't be merged into New") {
+			t.Errorf("merging:\n%s\ngot %v, want a refusal", src, err)
+		}
+		if string(fsys["p/p.go"]) != src {
+			t.Errorf("p/p.go changed after a refusal:\n%s", fsys["p/p.go"])
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package rename
+
+import (
+	"go/ast"
+	"go/token"
+)
+
+// A MessageEdit is a change Options.Messages made to a string literal.
+type MessageEdit struct {
+	Pos string `json:"pos"` // file:line:column
+	Old string `json:"old"` // the literal, as written
+	New string `json:"new"`
+}
+
+// MessageEdits returns the changes Options.Messages made to f.
+func (f *File) MessageEdits() []MessageEdit { return f.messageEdits }
+
+// isMessageFunc reports whether pkg.fn builds an error or log message.
+func isMessageFunc(pkg, fn string) bool {
+	switch pkg {
+	case "fmt":
+		return fn == "Errorf"
+	case "errors":
+		return fn == "New"
+	case "log", "slog":
+		return true
+	}
+	return false
+}
+
+// rewriteMessages applies renames to the words of string literals passed
+// to the functions that isMessageFunc accepts.
+func rewriteMessages(f *File, renames map[string]string) (changed bool) {
+	ast.Inspect(f.f, func(node ast.Node) bool {
+		call, ok := node.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+		sel, ok := call.Fun.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+		// As in renameQualified, a package name has no Obj.
+		if x, ok := sel.X.(*ast.Ident); !ok || x.Obj != nil || !isMessageFunc(x.Name, sel.Sel.Name) {
+			return true
+		}
+		for _, arg := range call.Args {
+			lit, ok := arg.(*ast.BasicLit)
+			if !ok || lit.Kind != token.STRING {
+				continue
+			}
+			if v := replaceWords(lit.Value, renames); v != lit.Value {
+				f.messageEdits = append(f.messageEdits, MessageEdit{
+					Pos: f.fset.Position(lit.Pos()).String(),
+					Old: lit.Value,
+					New: v,
+				})
+				lit.Value = v
+				changed = true
+			}
+		}
+		return true
+	})
+	return changed
+}
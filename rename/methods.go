@@ -0,0 +1,138 @@
+package rename
+
+import (
+	"fmt"
+	"go/token"
+	"go/types"
+	"strings"
+)
+
+// RenameMethod renames the method from of the named type typ, such as
+// "Cache" or "store.Cache", to to, along with its calls, method values, and
+// method expressions, including those on types that embed typ. It
+// type-checks each package, as RenameField does, so that same-named methods
+// of other types are left alone. A call through an interface can't be
+// told apart from calls of other types' methods, so if typ, or a pointer to
+// it, may implement an interface in the checked packages or their imports
+// that has the method, nothing changes and the clash is reported with
+// File.Conflicts, as it is if typ already has a field or method named to.
+// It is an error if there is no type typ with the method. Broken and
+// generated files are skipped, except for mocks.
+func RenameMethod(files []*File, typ, from, to string) error {
+	if from == to {
+		return fmt.Errorf("rename: %s is already named %s", from, to)
+	}
+	qualifier, name := "", typ
+	if i := strings.LastIndex(typ, "."); i >= 0 {
+		qualifier, name = typ[:i], typ[i+1:]
+	}
+	fset, pkgs, err := checkPackages(files)
+	if err != nil {
+		return err
+	}
+	ifaces := interfaces(pkgs, from)
+
+	// Find the method, by the position of its declaration.
+	targets := make(map[token.Position]bool)
+	clashed := make(map[token.Position]bool)
+	named, methods := 0, 0
+	for _, tn := range lookupTypes(pkgs, qualifier, name) {
+		t, ok := tn.Type().(*types.Named)
+		if !ok {
+			continue
+		}
+		if types.IsInterface(t) {
+			return fmt.Errorf("rename: %s is an interface; rename the methods of its implementations instead", typ)
+		}
+		named++
+		for i := 0; i < t.NumMethods(); i++ {
+			m := t.Method(i)
+			if m.Name() != from {
+				continue
+			}
+			methods++
+			pos := fset.Position(m.Pos())
+			with, _, _ := types.LookupFieldOrMethod(t, true, tn.Pkg(), to)
+			if with == nil {
+				for _, iface := range ifaces {
+					if mayImplement(t, iface.Type().Underlying().(*types.Interface)) {
+						with = iface
+						break
+					}
+				}
+			}
+			if with != nil {
+				if !clashed[pos] {
+					clashed[pos] = true
+					if err := typedConflict(files, fset, m, with, typ, to); err != nil {
+						return err
+					}
+				}
+				continue
+			}
+			targets[pos] = true
+		}
+	}
+	switch {
+	case named == 0:
+		return fmt.Errorf("rename: no type %s", typ)
+	case methods == 0:
+		return fmt.Errorf("rename: type %s has no method %s", typ, from)
+	}
+	renameTyped(fset, pkgs, from, to, func(obj types.Object) bool {
+		fn, ok := obj.(*types.Func)
+		return ok && targets[fset.Position(fn.Origin().Pos())]
+	})
+	return nil
+}
+
+// interfaces returns the named interfaces declared in pkgs or the packages
+// they import that have a method called method.
+func interfaces(pkgs []*typedPkg, method string) []*types.TypeName {
+	var tns []*types.TypeName
+	seen := make(map[*types.Package]bool)
+	for _, p := range pkgs {
+		if p.pkg == nil {
+			continue
+		}
+		for _, pkg := range append([]*types.Package{p.pkg}, p.pkg.Imports()...) {
+			if seen[pkg] {
+				continue
+			}
+			seen[pkg] = true
+			scope := pkg.Scope()
+			for _, n := range scope.Names() {
+				tn, ok := scope.Lookup(n).(*types.TypeName)
+				if !ok || !types.IsInterface(tn.Type()) {
+					continue
+				}
+				iface := tn.Type().Underlying().(*types.Interface)
+				for i := 0; i < iface.NumMethods(); i++ {
+					if iface.Method(i).Name() == method {
+						tns = append(tns, tn)
+						break
+					}
+				}
+			}
+		}
+	}
+	return tns
+}
+
+// mayImplement reports whether t, or a pointer to it, has methods named
+// like all of iface's. Comparing names rather than signatures errs on the
+// side of safety, and works across the separate copies of a package that
+// its importers and its own check see.
+func mayImplement(t types.Type, iface *types.Interface) bool {
+	ms := types.NewMethodSet(types.NewPointer(t))
+	names := make(map[string]bool)
+	for i := 0; i < ms.Len(); i++ {
+		names[ms.At(i).Obj().Name()] = true
+	}
+	for i := 0; i < iface.NumMethods(); i++ {
+		if !names[iface.Method(i).Name()] {
+			return false
+		}
+	}
+	return true
+}
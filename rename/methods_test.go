@@ -0,0 +1,111 @@
+package rename_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/jeremyschlatter/gorename-global/rename"
+)
+
+func TestRenameMethod(t *testing.T) {
+	const src = `package a
+
+type Cache struct{}
+
+func (c *Cache) Get() int { return 0 }
+
+type Store struct{}
+
+func (s Store) Get() int { return 1 }
+
+func f(c *Cache, s Store) int {
+	g := c.Get
+	return c.Get() + s.Get() + g() + (*Cache).Get(c)
+}
+`
+	const getter = "\ntype Getter interface{ Get() int }\n"
+	tests := []struct {
+		name          string
+		src           string
+		typ, from, to string
+		want          string // the new source, if it changes
+		wantConflicts []string
+		wantErr       string
+	}{
+		{
+			name: "renamed",
+			src:  src,
+			typ:  "Cache", from: "Get", to: "Lookup",
+			want: `package a
+
+type Cache struct{}
+
+func (c *Cache) Lookup() int { return 0 }
+
+type Store struct{}
+
+func (s Store) Get() int { return 1 }
+
+func f(c *Cache, s Store) int {
+	g := c.Lookup
+	return c.Lookup() + s.Get() + g() + (*Cache).Lookup(c)
+}
+`,
+		},
+		{
+			name: "taken",
+			src:  src + "\nfunc (c *Cache) Lookup() int { return 2 }\n",
+			typ:  "Cache", from: "Get", to: "Lookup",
+			wantConflicts: []string{"Get -> Lookup"},
+		},
+		{
+			name: "interface",
+			src:  src + getter,
+			typ:  "Cache", from: "Get", to: "Lookup",
+			wantConflicts: []string{"Get -> Lookup"},
+		},
+		{
+			name: "of an interface",
+			src:  src + getter,
+			typ:  "Getter", from: "Get", to: "Lookup",
+			wantErr: "rename: Getter is an interface; rename the methods of its implementations instead",
+		},
+		{
+			name: "no type",
+			src:  src,
+			typ:  "Nope", from: "Get", to: "Lookup",
+			wantErr: "rename: no type Nope",
+		},
+		{
+			name: "no method",
+			src:  src,
+			typ:  "Cache", from: "Put", to: "Store",
+			wantErr: "rename: type Cache has no method Put",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys := rename.MemFS{"a/a.go": []byte(tt.src)}
+			files, err := changeFS(t, fsys, func(files []*rename.File) error {
+				return rename.RenameMethod(files, tt.typ, tt.from, tt.to)
+			})
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("got error %v, want %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := tt.want
+			if want == "" {
+				want = tt.src
+			}
+			checkFS(t, fsys, map[string]string{"a/a.go": want})
+			if got := conflicts(files); !reflect.DeepEqual(got, tt.wantConflicts) {
+				t.Errorf("conflicts %q, want %q", got, tt.wantConflicts)
+			}
+		})
+	}
+}
@@ -0,0 +1,559 @@
+package rename
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/token"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A Move says which top-level declaration MoveDecl moves, and where.
+type Move struct {
+	Name     string // the declaration
+	From, To string // the import paths of the packages to move it from and to
+	ToDir    string // the directory of the package To
+	ToName   string // the package name of To
+}
+
+// MoveDecl moves the top-level declaration m.Name, along with its methods
+// if it is a type, from the package m.From to the package m.To, both among
+// files, whose import paths are given by directory in importPaths. The
+// declaration is cut from its file and returned as the source of a new
+// file in m.ToDir, named after it. References to it are qualified in
+// m.From, unqualified in m.To, and requalified elsewhere, with the imports
+// they need added and those they no longer do removed; the changed files
+// are reparsed, so that they are written as any other change is.
+//
+// As with Rename, references are found by name: an unqualified name that
+// isn't declared locally is taken to be the package-level one. It is an
+// error for the declaration to refer to an unexported name that would be
+// left behind, or for the move to make the two packages import each other.
+func MoveDecl(files []*File, m Move, importPaths map[string]string) (map[string][]byte, error) {
+	if m.From == m.To {
+		return nil, fmt.Errorf("rename: %s is already in %s", m.Name, m.To)
+	}
+	pkgOf := func(f *File) string {
+		if strings.HasSuffix(f.f.Name.Name, "_test") {
+			return "" // an external test package
+		}
+		return importPaths[filepath.Dir(f.Path)]
+	}
+	var src, dst, others []*File
+	for _, f := range files {
+		switch {
+		case f.broken:
+		case pkgOf(f) == m.From:
+			src = append(src, f)
+		case pkgOf(f) == m.To:
+			dst = append(dst, f)
+		default:
+			others = append(others, f)
+		}
+	}
+	if len(src) == 0 {
+		return nil, fmt.Errorf("rename: package %s is not among those named", m.From)
+	}
+	srcName := src[0].f.Name.Name
+	declared := make(map[string]bool)
+	for _, f := range src {
+		for name := range f.f.Scope.Objects {
+			declared[name] = true
+		}
+	}
+	taken := make(map[string]bool)
+	for _, f := range dst {
+		if f.f.Scope.Lookup(m.Name) != nil {
+			return nil, fmt.Errorf("rename: %s already declares %s", f.Path, m.Name)
+		}
+		taken[filepath.Base(f.Path)] = true
+	}
+
+	var cuts []cut
+	for _, f := range src {
+		cs, err := f.cutDecls(m.Name)
+		if err != nil {
+			return nil, err
+		}
+		cuts = append(cuts, cs...)
+	}
+	if len(cuts) == 0 {
+		return nil, fmt.Errorf("rename: package %s declares no %s", m.From, m.Name)
+	}
+
+	// The moved declaration, with its references to what it leaves behind
+	// qualified and those to m.To unqualified.
+	imports := make(map[string]string) // import path -> name it is used by
+	var body bytes.Buffer
+	for _, c := range cuts {
+		f := c.f
+		es := []edit{}
+		if c.keyword != "" {
+			off := f.offset(c.node.Pos())
+			es = append(es, edit{off, off, c.keyword + " "})
+		}
+		for _, id := range f.uses(c.node, func(name string) bool { return declared[name] && name != m.Name }) {
+			if !ast.IsExported(id.Name) {
+				return nil, fmt.Errorf("rename: %s refers to %s, which is unexported and would be left behind in %s", m.Name, id.Name, m.From)
+			}
+			off := f.offset(id.Pos())
+			es = append(es, edit{off, off, srcName + "."})
+			imports[m.From] = srcName
+		}
+		for _, sel := range f.pkgSelectors(c.node) {
+			x := sel.X.(*ast.Ident)
+			ip := f.importPath(x.Name)
+			if ip == m.To {
+				es = append(es, edit{f.offset(x.Pos()), f.offset(sel.Sel.Pos()), ""})
+				continue
+			}
+			imports[ip] = x.Name
+		}
+		text, err := applyEdits(f.src[c.start:c.end], c.start, es)
+		if err != nil {
+			return nil, err
+		}
+		body.WriteString("\n")
+		body.Write(text)
+	}
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "package %s\n\n", m.ToName)
+	var paths []string
+	for ip := range imports {
+		paths = append(paths, ip)
+	}
+	sort.Strings(paths)
+	switch len(paths) {
+	case 0:
+	case 1:
+		fmt.Fprintf(&buf, "import %s\n", importSpec(imports[paths[0]], paths[0]))
+	default:
+		buf.WriteString("import (\n")
+		for _, ip := range paths {
+			fmt.Fprintf(&buf, "\t%s\n", importSpec(imports[ip], ip))
+		}
+		buf.WriteString(")\n")
+	}
+	buf.Write(body.Bytes())
+	out, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("rename: formatting the moved %s: %v", m.Name, err)
+	}
+	name := strings.ToLower(m.Name) + ".go"
+	for i := 2; taken[name]; i++ {
+		name = fmt.Sprintf("%s%d.go", strings.ToLower(m.Name), i)
+	}
+	created := map[string][]byte{filepath.Join(m.ToDir, name): out}
+
+	// The rest of m.From refers to the declaration through m.To.
+	srcImportsTo := false
+	for _, f := range src {
+		var es []edit
+		var removed []cut
+		for _, c := range cuts {
+			if c.f == f {
+				es = append(es, edit{c.start, c.end, ""})
+				removed = append(removed, c)
+			}
+		}
+		local, add := f.importName(m.To, m.ToName)
+		qualified := false
+		for _, id := range f.uses(f.f, func(name string) bool { return name == m.Name }) {
+			if inCuts(removed, f.offset(id.Pos())) {
+				continue
+			}
+			off := f.offset(id.Pos())
+			es = append(es, edit{off, off, local + "."})
+			qualified = true
+		}
+		srcImportsTo = srcImportsTo || qualified || f.imports(m.To)
+		if len(es) == 0 {
+			continue
+		}
+		var adds []string
+		if add && qualified {
+			adds = append(adds, importSpec(local, m.To))
+		}
+		unused := f.unusedImports(removed, nil)
+		if qualified {
+			// What stays refers to m.To now, even if only the moved code
+			// did before.
+			var keep []*ast.ImportSpec
+			for _, spec := range unused {
+				if !containsImport([]*ast.ImportSpec{spec}, m.To) {
+					keep = append(keep, spec)
+				}
+			}
+			unused = keep
+		}
+		es = append(es, f.importEdits(adds, unused)...)
+		if err := f.reparse(es); err != nil {
+			return nil, err
+		}
+	}
+
+	// m.To refers to it by its name alone, and everything else through
+	// m.To rather than m.From.
+	dstImportsFrom := len(imports[m.From]) > 0
+	for _, f := range append(dst, others...) {
+		inDst := pkgOf(f) == m.To
+		var es []edit
+		var dropped []*ast.SelectorExpr
+		local, add := f.importName(m.To, m.ToName)
+		for _, sel := range f.pkgSelectors(f.f) {
+			x := sel.X.(*ast.Ident)
+			if sel.Sel.Name != m.Name || f.importPath(x.Name) != m.From {
+				continue
+			}
+			dropped = append(dropped, sel)
+			if inDst {
+				es = append(es, edit{f.offset(x.Pos()), f.offset(sel.Sel.Pos()), ""})
+			} else {
+				es = append(es, edit{f.offset(x.Pos()), f.offset(x.End()), local})
+			}
+		}
+		if len(es) == 0 {
+			if inDst && f.imports(m.From) {
+				dstImportsFrom = true
+			}
+			continue
+		}
+		var adds []string
+		if add && !inDst {
+			adds = append(adds, importSpec(local, m.To))
+		}
+		unused := f.unusedImports(nil, dropped)
+		if inDst && !containsImport(unused, m.From) && f.imports(m.From) {
+			dstImportsFrom = true
+		}
+		es = append(es, f.importEdits(adds, unused)...)
+		if err := f.reparse(es); err != nil {
+			return nil, err
+		}
+	}
+	if srcImportsTo && dstImportsFrom {
+		return nil, fmt.Errorf("rename: moving %s would make %s and %s import each other", m.Name, m.From, m.To)
+	}
+	return created, nil
+}
+
+// A cut is a declaration that MoveDecl moves, from start to end in f's
+// source, which are the starts of the lines it begins on and follows.
+type cut struct {
+	f          *File
+	node       ast.Node // the declaration, or spec in its group
+	start, end int
+	keyword    string // "type" or "var", for a spec taken from a group
+}
+
+// cutDecls returns the cuts of the top-level declaration of name in f, and,
+// for a type, of its methods.
+func (f *File) cutDecls(name string) ([]cut, error) {
+	var cs []cut
+	for _, d := range f.f.Decls {
+		switch d := d.(type) {
+		case *ast.FuncDecl:
+			if d.Recv == nil && d.Name.Name == name || d.Recv != nil && len(d.Recv.List) == 1 && recvType(d.Recv.List[0].Type) == name {
+				cs = append(cs, f.cut(d, d.Doc, ""))
+			}
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				var names []*ast.Ident
+				var doc *ast.CommentGroup
+				switch s := spec.(type) {
+				case *ast.TypeSpec:
+					names, doc = []*ast.Ident{s.Name}, s.Doc
+				case *ast.ValueSpec:
+					names, doc = s.Names, s.Doc
+				}
+				found := false
+				for _, n := range names {
+					found = found || n.Name == name
+				}
+				switch {
+				case !found:
+					continue
+				case len(names) > 1:
+					return nil, fmt.Errorf("rename: %s is declared along with others, at %s", name, f.fset.Position(spec.Pos()))
+				case len(d.Specs) == 1:
+					cs = append(cs, f.cut(d, d.Doc, ""))
+				case d.Tok == token.CONST:
+					return nil, fmt.Errorf("rename: %s is declared in a const block, on whose iota or implicit values it may depend, at %s", name, f.fset.Position(spec.Pos()))
+				default:
+					cs = append(cs, f.cut(spec, doc, d.Tok.String()))
+				}
+			}
+		}
+	}
+	return cs, nil
+}
+
+// cut returns the cut of the lines of node and its doc comment.
+func (f *File) cut(node ast.Node, doc *ast.CommentGroup, keyword string) cut {
+	first := node.Pos()
+	if doc != nil {
+		first = doc.Pos()
+	}
+	tf := f.fset.File(first)
+	start := tf.Offset(tf.LineStart(tf.Line(first)))
+	end := f.offset(node.End())
+	if i := bytes.IndexByte(f.src[end:], '\n'); i >= 0 {
+		end += i + 1
+	} else {
+		end = len(f.src)
+	}
+	return cut{f: f, node: node, start: start, end: end, keyword: keyword}
+}
+
+func inCuts(cs []cut, off int) bool {
+	for _, c := range cs {
+		if c.start <= off && off < c.end {
+			return true
+		}
+	}
+	return false
+}
+
+// offset returns the offset of pos in f's source.
+func (f *File) offset(pos token.Pos) int { return f.fset.Position(pos).Offset }
+
+// uses returns the identifiers in node that refer by themselves, rather
+// than as a selector, field, key, or label, to package-level names for
+// which want is true: those not declared in any scope but the file's.
+func (f *File) uses(node ast.Node, want func(string) bool) []*ast.Ident {
+	skip := make(map[*ast.Ident]bool)
+	var ids []*ast.Ident
+	ast.Inspect(node, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.SelectorExpr:
+			skip[n.Sel] = true
+		case *ast.Field:
+			for _, id := range n.Names {
+				skip[id] = true
+			}
+		case *ast.FuncDecl:
+			skip[n.Name] = true
+		case *ast.TypeSpec:
+			skip[n.Name] = true
+		case *ast.ValueSpec:
+			for _, id := range n.Names {
+				skip[id] = true
+			}
+		case *ast.KeyValueExpr:
+			if id, ok := n.Key.(*ast.Ident); ok && id.Obj == nil {
+				skip[id] = true
+			}
+		case *ast.LabeledStmt:
+			skip[n.Label] = true
+		case *ast.BranchStmt:
+			if n.Label != nil {
+				skip[n.Label] = true
+			}
+		case *ast.Ident:
+			if !skip[n] && want(n.Name) && (n.Obj == nil || f.f.Scope.Lookup(n.Name) == n.Obj) {
+				ids = append(ids, n)
+			}
+		}
+		return true
+	})
+	return ids
+}
+
+// pkgSelectors returns the selectors in node on an imported package.
+func (f *File) pkgSelectors(node ast.Node) []*ast.SelectorExpr {
+	var sels []*ast.SelectorExpr
+	ast.Inspect(node, func(n ast.Node) bool {
+		if sel, ok := n.(*ast.SelectorExpr); ok {
+			if x, ok := sel.X.(*ast.Ident); ok && x.Obj == nil && f.importPath(x.Name) != "" {
+				sels = append(sels, sel)
+			}
+		}
+		return true
+	})
+	return sels
+}
+
+// importName returns the name f refers to the package at path by, and
+// whether it must be imported as that name, which is name.
+func (f *File) importName(ip, name string) (string, bool) {
+	for _, spec := range f.f.Imports {
+		if p, err := strconv.Unquote(spec.Path.Value); err == nil && p == ip {
+			if spec.Name != nil {
+				return spec.Name.Name, false
+			}
+			return path.Base(ip), false
+		}
+	}
+	return name, true
+}
+
+// imports reports whether f imports the package at path.
+func (f *File) imports(ip string) bool {
+	_, add := f.importName(ip, "")
+	return !add
+}
+
+// unusedImports returns the imports of f that are used only within cs or by
+// the selectors in sels, and so will be unused once those are gone.
+func (f *File) unusedImports(cs []cut, sels []*ast.SelectorExpr) []*ast.ImportSpec {
+	gone := make(map[*ast.SelectorExpr]bool)
+	for _, sel := range sels {
+		gone[sel] = true
+	}
+	used := make(map[string]int)    // by the things that stay
+	dropped := make(map[string]int) // by the things that go
+	for _, sel := range f.pkgSelectors(f.f) {
+		x := sel.X.(*ast.Ident)
+		if gone[sel] || inCuts(cs, f.offset(sel.Pos())) {
+			dropped[x.Name]++
+		} else {
+			used[x.Name]++
+		}
+	}
+	var specs []*ast.ImportSpec
+	for _, spec := range f.f.Imports {
+		ip, err := strconv.Unquote(spec.Path.Value)
+		if err != nil {
+			continue
+		}
+		name := path.Base(ip)
+		if spec.Name != nil {
+			name = spec.Name.Name
+		}
+		if dropped[name] > 0 && used[name] == 0 {
+			specs = append(specs, spec)
+		}
+	}
+	return specs
+}
+
+func containsImport(specs []*ast.ImportSpec, ip string) bool {
+	for _, spec := range specs {
+		if p, err := strconv.Unquote(spec.Path.Value); err == nil && p == ip {
+			return true
+		}
+	}
+	return false
+}
+
+// importSpec returns the text of an import of ip by name.
+func importSpec(name, ip string) string {
+	if name != path.Base(ip) {
+		return name + " " + strconv.Quote(ip)
+	}
+	return strconv.Quote(ip)
+}
+
+// importEdits returns the edits to f that add the imports adds, as import
+// spec text, and remove the imports removes. An added import takes the
+// place of a removed one where it can, so that the edits never overlap.
+// The rest go into the first import group, as astutil.AddImport puts
+// them, which a lone import declaration is made into.
+func (f *File) importEdits(adds []string, removes []*ast.ImportSpec) []edit {
+	var es []edit
+	kept := make(map[ast.Spec]bool) // in place, with another import's text
+	for len(adds) > 0 && len(removes) > 0 {
+		spec := removes[0]
+		es = append(es, edit{f.offset(spec.Pos()), f.offset(spec.End()), adds[0]})
+		kept[spec] = true
+		adds, removes = adds[1:], removes[1:]
+	}
+	for _, spec := range removes {
+		es = append(es, f.removeImport(spec))
+	}
+	if len(adds) == 0 {
+		return es
+	}
+	var lone *ast.GenDecl
+	for _, d := range f.f.Decls {
+		gd, ok := d.(*ast.GenDecl)
+		if !ok || gd.Tok != token.IMPORT {
+			continue
+		}
+		if gd.Lparen.IsValid() {
+			off := f.offset(gd.Rparen)
+			return append(es, edit{off, off, "\n\t" + strings.Join(adds, "\n\t") + "\n"})
+		}
+		if lone == nil && !kept[gd.Specs[0]] {
+			lone = gd
+		}
+	}
+	if lone != nil {
+		spec := lone.Specs[0].(*ast.ImportSpec)
+		off, end := f.offset(spec.Pos()), f.offset(spec.End())
+		if spec.Comment != nil {
+			end = f.offset(spec.Comment.End())
+		}
+		text := "(\n\t" + string(f.src[off:end]) + "\n\t" + strings.Join(adds, "\n\t") + "\n)"
+		return append(es, edit{off, end, text})
+	}
+	// After the package clause, as a group of their own if there are more
+	// than one.
+	off, text := f.offset(f.f.Name.End()), adds[0]
+	if len(adds) > 1 {
+		text = "(\n\t" + strings.Join(adds, "\n\t") + "\n)"
+	}
+	return append(es, edit{off, off, "\n\nimport " + text})
+}
+
+// removeImport returns the edit that removes spec from f: its line of an
+// import group, or its whole declaration.
+func (f *File) removeImport(spec *ast.ImportSpec) edit {
+	var node ast.Node = spec
+	for _, d := range f.f.Decls {
+		if gd, ok := d.(*ast.GenDecl); ok && gd.Tok == token.IMPORT && len(gd.Specs) == 1 && gd.Specs[0] == spec {
+			node = gd
+		}
+	}
+	c := f.cut(node, nil, "")
+	return edit{c.start, c.end, ""}
+}
+
+// An edit replaces src[off:end] with text.
+type edit struct {
+	off, end int
+	text     string
+}
+
+// applyEdits returns src, which starts at offset base of the source the
+// edits are to, with the edits applied.
+func applyEdits(src []byte, base int, es []edit) ([]byte, error) {
+	sort.SliceStable(es, func(i, j int) bool { return es[i].off < es[j].off })
+	var buf bytes.Buffer
+	last := 0
+	for _, e := range es {
+		off, end := e.off-base, e.end-base
+		if off < last {
+			return nil, fmt.Errorf("rename: overlapping edits at offset %d", e.off)
+		}
+		buf.Write(src[last:off])
+		buf.WriteString(e.text)
+		last = end
+	}
+	buf.Write(src[last:])
+	return buf.Bytes(), nil
+}
+
+// reparse applies es to f's source and parses the result as f's syntax,
+// so that f is written with the changes.
+func (f *File) reparse(es []edit) error {
+	src, err := applyEdits(f.src, 0, es)
+	if err != nil {
+		return err
+	}
+	if fmtd, err := format.Source(src); err == nil {
+		src = fmtd
+	}
+	g, err := ParseFile(f.Path, src)
+	if err != nil {
+		return fmt.Errorf("rename: editing left %s unparsable: %v", f.Path, err)
+	}
+	f.f, f.fset = g.f, g.fset
+	f.changed = true
+	return nil
+}
@@ -0,0 +1,96 @@
+package rename_test
+
+import (
+	"path"
+	"testing"
+
+	"github.com/jeremyschlatter/gorename-global/rename"
+)
+
+// moveFS moves m.Name as MoveDecl does in the module example.com/t1 of fsys,
+// and returns fsys with the changed and created files written back.
+func moveFS(t *testing.T, fsys rename.MemFS, m rename.Move) (rename.MemFS, error) {
+	t.Helper()
+	importPaths := make(map[string]string)
+	for name := range fsys {
+		dir := path.Dir(name)
+		importPaths[dir] = "example.com/t1/" + dir
+	}
+	m.From, m.To = "example.com/t1/"+m.From, "example.com/t1/"+m.ToDir
+	if m.ToName == "" {
+		m.ToName = path.Base(m.ToDir)
+	}
+	var created map[string][]byte
+	_, err := changeFS(t, fsys, func(files []*rename.File) (err error) {
+		created, err = rename.MoveDecl(files, m, importPaths)
+		return err
+	})
+	for name, src := range created {
+		fsys[name] = src
+	}
+	return fsys, err
+}
+
+func TestMoveIntoImported(t *testing.T) {
+	// a imports c already, and b imports a alone; moving Old from a to c
+	// adds c to b's import, rather than a declaration of its own.
+	got, err := moveFS(t, rename.MemFS{
+		"a/a.go": []byte("package a\n\nimport \"example.com/t1/c\"\n\ntype Old struct{ T c.Thing }\n\nfunc Use() Old { return Old{} }\n"),
+		"b/b.go": []byte("package b\n\nimport \"example.com/t1/a\" // for Use\n\nvar X a.Old\n\nfunc F() { a.Use() }\n"),
+		"c/c.go": []byte("package c\n\ntype Thing int\n"),
+	}, rename.Move{Name: "Old", From: "a", ToDir: "c"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkFS(t, got, map[string]string{
+		"a/a.go":   "package a\n\nimport \"example.com/t1/c\"\n\nfunc Use() c.Old { return c.Old{} }\n",
+		"b/b.go":   "package b\n\nimport (\n\t\"example.com/t1/a\" // for Use\n\t\"example.com/t1/c\"\n)\n\nvar X c.Old\n\nfunc F() { a.Use() }\n",
+		"c/old.go": "package c\n\ntype Old struct{ T Thing }\n",
+	})
+}
+
+func TestMoveOutOfImporter(t *testing.T) {
+	// a imports c and strings; moving Thing from a to c, which a imports,
+	// leaves a qualifying it, and the file that moved it in with two
+	// imports in one group.
+	got, err := moveFS(t, rename.MemFS{
+		"a/a.go": []byte("package a\n\nimport \"strings\"\n\nimport \"example.com/t1/c\"\n\ntype Thing struct{ U c.Unit }\n\nvar Upper = strings.ToUpper\n\nvar Zero Thing\n"),
+		"b/b.go": []byte("package b\n\nimport \"example.com/t1/a\"\n\nvar Y a.Thing\n\nvar Up = a.Upper\n"),
+		"c/c.go": []byte("package c\n\ntype Unit int\n"),
+	}, rename.Move{Name: "Thing", From: "a", ToDir: "c"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkFS(t, got, map[string]string{
+		"a/a.go":     "package a\n\nimport \"strings\"\n\nimport \"example.com/t1/c\"\n\nvar Upper = strings.ToUpper\n\nvar Zero c.Thing\n",
+		"b/b.go":     "package b\n\nimport (\n\t\"example.com/t1/a\"\n\t\"example.com/t1/c\"\n)\n\nvar Y c.Thing\n\nvar Up = a.Upper\n",
+		"c/thing.go": "package c\n\ntype Thing struct{ U Unit }\n",
+	})
+}
+
+func TestMoveCreatedImports(t *testing.T) {
+	// The moved declaration needs both the package it leaves and another;
+	// its new file imports them in one group.
+	got, err := moveFS(t, rename.MemFS{
+		"a/a.go": []byte("package a\n\nimport \"strings\"\n\ntype Name string\n\nfunc Up(n Name) string { return strings.ToUpper(string(n)) }\n"),
+		"d/d.go": []byte("package d\n"),
+	}, rename.Move{Name: "Up", From: "a", ToDir: "d"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkFS(t, got, map[string]string{
+		"d/up.go": "package d\n\nimport (\n\t\"example.com/t1/a\"\n\t\"strings\"\n)\n\nfunc Up(n a.Name) string { return strings.ToUpper(string(n)) }\n",
+	})
+}
+
+func TestMoveCycle(t *testing.T) {
+	// c imports a already, so a can't refer to what moves to c.
+	_, err := moveFS(t, rename.MemFS{
+		"a/a.go": []byte("package a\n\ntype Old int\n\nvar Zero Old\n"),
+		"c/c.go": []byte("package c\n\nimport \"example.com/t1/a\"\n\nvar One = a.Zero + 1\n"),
+	}, rename.Move{Name: "Old", From: "a", ToDir: "c"})
+	want := "rename: moving Old would make example.com/t1/a and example.com/t1/c import each other"
+	if err == nil || err.Error() != want {
+		t.Errorf("MoveDecl: %v, want %s", err, want)
+	}
+}
@@ -0,0 +1,62 @@
+package rename
+
+import (
+	"strings"
+	"unicode"
+)
+
+// irregularPlurals are common words whose plurals don't follow the rules in
+// pluralWord.
+var irregularPlurals = map[string]string{
+	"child":  "children",
+	"datum":  "data",
+	"index":  "indices",
+	"man":    "men",
+	"person": "people",
+	"woman":  "women",
+}
+
+// pluralize returns name with its last word made plural, using overrides,
+// which maps lower-case singular words to plurals, before the built-in
+// rules. Initialisms get a lower-case "s", as in "IDs".
+func pluralize(name string, overrides map[string]string) string {
+	ws := words(name)
+	if len(ws) == 0 {
+		return name
+	}
+	last := ws[len(ws)-1]
+	if strings.Trim(last, "_") == "" {
+		return name
+	}
+	var p string
+	switch lower := strings.ToLower(last); {
+	case commonInitialisms[last]:
+		p = last + "s"
+	case isUpper(last):
+		p = strings.ToUpper(pluralWord(lower, overrides))
+	default:
+		p = pluralWord(lower, overrides)
+		if unicode.IsUpper([]rune(last)[0]) {
+			p = export(p)
+		}
+	}
+	return strings.Join(ws[:len(ws)-1], "") + p
+}
+
+// pluralWord returns the plural of the lower-case word w.
+func pluralWord(w string, overrides map[string]string) string {
+	if p, ok := overrides[w]; ok {
+		return strings.ToLower(p)
+	}
+	if p, ok := irregularPlurals[w]; ok {
+		return p
+	}
+	switch {
+	case strings.HasSuffix(w, "s"), strings.HasSuffix(w, "x"), strings.HasSuffix(w, "z"),
+		strings.HasSuffix(w, "ch"), strings.HasSuffix(w, "sh"):
+		return w + "es"
+	case strings.HasSuffix(w, "y") && len(w) > 1 && !strings.ContainsRune("aeiou", rune(w[len(w)-2])):
+		return w[:len(w)-1] + "ies"
+	}
+	return w + "s"
+}
@@ -0,0 +1,109 @@
+package rename_test
+
+import (
+	"testing"
+
+	"github.com/jeremyschlatter/gorename-global/rename"
+)
+
+func TestRenameQualified(t *testing.T) {
+	const pkg = "package pkg\n\ntype Old struct{}\n"
+	tests := []struct {
+		name  string
+		files rename.MemFS
+		want  map[string]string
+	}{
+		{
+			name: "selectors",
+			files: rename.MemFS{
+				"pkg/pkg.go": []byte(pkg),
+				"a/a.go": []byte(`package a
+
+import "example.com/pkg"
+
+func f() {
+	_ = pkg.Old{}
+	var pkg struct{ Old int }
+	_ = pkg.Old
+}
+`),
+				"b/b.go": []byte("package b\n\ntype Old int\n"),
+			},
+			want: map[string]string{
+				"pkg/pkg.go": "package pkg\n\ntype New struct{}\n",
+				"a/a.go": `package a
+
+import "example.com/pkg"
+
+func f() {
+	_ = pkg.New{}
+	var pkg struct{ Old int }
+	_ = pkg.Old
+}
+`,
+				"b/b.go": "package b\n\ntype Old int\n",
+			},
+		},
+		{
+			name: "embedded",
+			files: rename.MemFS{
+				"pkg/pkg.go": []byte(pkg),
+				"a/a.go": []byte(`package a
+
+import (
+	"fmt"
+
+	"example.com/pkg"
+)
+
+type E struct{ pkg.Old }
+
+type P struct{ *pkg.Old }
+
+type T struct{ Old int }
+
+func f(e E, p P, t T) {
+	_ = e.Old
+	_ = E{Old: pkg.Old{}}
+	_ = p.Old
+	_ = T{Old: 1}
+	_ = t.Old
+	fmt.Old()
+}
+`),
+			},
+			want: map[string]string{
+				"pkg/pkg.go": "package pkg\n\ntype New struct{}\n",
+				"a/a.go": `package a
+
+import (
+	"fmt"
+
+	"example.com/pkg"
+)
+
+type E struct{ pkg.New }
+
+type P struct{ *pkg.New }
+
+type T struct{ Old int }
+
+func f(e E, p P, t T) {
+	_ = e.New
+	_ = E{New: pkg.New{}}
+	_ = p.New
+	_ = T{Old: 1}
+	_ = t.Old
+	fmt.Old()
+}
+`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := renameFS(t, tt.files, rename.Options{From: "pkg.Old", To: "pkg.New"})
+			checkFS(t, got, tt.want)
+		})
+	}
+}
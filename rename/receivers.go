@@ -0,0 +1,91 @@
+package rename
+
+import (
+	"go/ast"
+	"strings"
+)
+
+// RenameReceivers names the receiver of every method on the type typ, such
+// as "Server" or "*Server", name, and renames its uses to match. Value and
+// pointer receivers are treated alike. A method in which name already
+// means something else is left alone, and reported with File.Conflicts.
+// Broken and generated files are skipped, except for mocks.
+func RenameReceivers(files []*File, typ, name string) error {
+	typ = strings.TrimPrefix(typ, "*")
+	return eachFile(files, func(f *File) error {
+		if f.broken || f.Generated() && !f.Mock() {
+			return nil
+		}
+		for _, d := range f.f.Decls {
+			fd, ok := d.(*ast.FuncDecl)
+			if ok && fd.Recv != nil && len(fd.Recv.List) == 1 && recvType(fd.Recv.List[0].Type) == typ {
+				f.renameReceiver(fd, name)
+			}
+		}
+		return nil
+	})
+}
+
+// renameReceiver renames the receiver of fd, and its uses, to name.
+func (f *File) renameReceiver(fd *ast.FuncDecl, name string) {
+	field := fd.Recv.List[0]
+	var recv *ast.Ident
+	if len(field.Names) > 0 && field.Names[0].Name != "_" {
+		recv = field.Names[0]
+		if recv.Name == name {
+			return
+		}
+	}
+	if clash := f.renameInFunc(fd, recv, name); clash != nil {
+		old, pos := "_", fd.Recv.Pos()
+		if recv != nil {
+			old, pos = recv.Name, recv.Pos()
+		}
+		f.conflicts = append(f.conflicts, Conflict{
+			Old:  old,
+			New:  name,
+			Pos:  f.fset.Position(pos).String(),
+			With: f.fset.Position(clash.Pos()).String(),
+		})
+		return
+	}
+	if recv == nil {
+		field.Names = []*ast.Ident{ast.NewIdent(name)}
+		f.changed = true
+	}
+}
+
+// renameInFunc renames id, a receiver, parameter or result of fd, and its uses to
+// name, unless some other identifier in fd that isn't a field or method
+// selector is already named name, in which case it returns that one and
+// changes nothing. Declaring name in an inner block would be enough to
+// break a naked return, so this errs on the side of safety. If id is nil,
+// it only checks.
+func (f *File) renameInFunc(fd *ast.FuncDecl, id *ast.Ident, name string) (clash *ast.Ident) {
+	var uses []*ast.Ident
+	sels := make(map[*ast.Ident]bool)
+	ast.Inspect(fd, func(node ast.Node) bool {
+		switch n := node.(type) {
+		case *ast.SelectorExpr:
+			sels[n.Sel] = true
+		case *ast.Ident:
+			switch {
+			case id != nil && n.Obj != nil && n.Obj == id.Obj:
+				uses = append(uses, n)
+			case n.Name == name && !sels[n]:
+				clash = n
+			}
+		}
+		return clash == nil
+	})
+	if clash != nil || id == nil {
+		return clash
+	}
+	old := id.Name
+	for _, u := range uses {
+		u.Name = name
+		f.renamed(old, name)
+	}
+	f.changed = true
+	return nil
+}
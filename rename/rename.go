@@ -0,0 +1,953 @@
+// Package rename is the engine behind gorename-global. It renames identifiers
+// in parsed Go source files without touching the file system, so it works
+// equally well on files read from disk and on snippets held in memory, as in
+// a js/wasm build.
+package rename
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"path"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"go4.org/syncutil"
+)
+
+// Options says what to rename.
+type Options struct {
+	// From and To are the current and new names. From may be qualified
+	// with a package name, as in "pkg.Old", in which case To may be
+	// either "pkg.New" or "New".
+	From, To string
+
+	// ToTemplate, if set instead of To, makes the new name of each
+	// identifier that From matches, and of its plurals and companions,
+	// from its current name. The placeholders are {orig}, the name as it
+	// is; {exported} and {unexported}, with its first word capitalized or
+	// not; and {snake} and {SNAKE}, in lower or upper snake_case. So
+	// ToTemplate "{orig}Legacy" renames "Client" to "ClientLegacy" and,
+	// with Companions "New{}", "NewClient" to "NewClientLegacy". It may not
+	// be combined with Word.
+	ToTemplate string
+
+	// Auto renames every identifier that 'go lint' would flag. It may not
+	// be combined with From and To. AutoRules, if set, limits it to the
+	// named rules. Auto leaves alone names declared by generated code,
+	// including protobuf, gRPC, and Thrift output that lacks a
+	// "DO NOT EDIT" comment, since renaming them breaks the generated glue.
+	// It also skips, and reports with File.Conflicts, renames whose new
+	// name is already declared in the same scope.
+	Auto      bool
+	AutoRules []string
+
+	// ExportedOnly limits Auto to exported identifiers.
+	ExportedOnly bool
+
+	// Initialisms, if set, are the initialisms, such as "ID", that the
+	// initialisms rule of Auto upper-cases, rather than those 'go lint'
+	// knows, as a linter's configuration may say.
+	Initialisms []string
+
+	// HungarianPrefixes are the prefixes that the hungarian rule of Auto
+	// strips, HungarianPrefixes if empty.
+	HungarianPrefixes []string
+
+	// Word matches From against the camelCase words of identifiers
+	// rather than whole identifiers, so that From "Color" and To
+	// "Colour" rename "ColorPicker" to "ColourPicker" and
+	// "backgroundColor" to "backgroundColour". From may be several
+	// words, which must appear consecutively. Words match regardless of
+	// case, and the replacement follows the case of the words it
+	// replaces: "color", "Color", and "COLOR" become "colour", "Colour",
+	// and "COLOUR".
+	Word bool
+
+	// Plurals also renames the plural of From to the plural of To, so
+	// that From "Foo" and To "Bar" rename "Foos" to "Bars".
+	// PluralOverrides maps lower-case singular words to plurals that the
+	// simple English rules get wrong, such as "person" to "people".
+	Plurals         bool
+	PluralOverrides map[string]string
+
+	// Companions are templates for names derived from From that are
+	// renamed along with it, such as "New{}", "{}Error", and "{}Impl".
+	// The {} stands for From, or To, capitalized unless it begins the
+	// template, so that From "Foo" and To "Bar" also rename "NewFoo" to
+	// "NewBar". Each companion is renamed in both its exported and
+	// unexported forms, as with "FooImpl" and "fooImpl". Each template
+	// must contain exactly one {}.
+	Companions []string
+
+	// IgnoreCase matches From regardless of case, so "userid" also
+	// matches "UserID" and "userId". Each match is renamed to To,
+	// exported or not to agree with the identifier it replaces. A match
+	// whose new name is already declared in the same scope, or is what
+	// another match there is also renamed to, is left alone and reported
+	// with File.Conflicts.
+	IgnoreCase bool
+
+	// Generated allows renaming in files marked as generated by a
+	// "// Code generated ... DO NOT EDIT." comment. Otherwise they are
+	// consulted but left alone, except for mocks; see File.Mock.
+	Generated bool
+
+	// Messages also renames the words of string literals passed to
+	// fmt.Errorf, errors.New, and the functions of the log and slog
+	// packages, so that messages keep naming what they describe. Each
+	// change is recorded in File.MessageEdits.
+	Messages bool
+
+	// Include, if set, limits the files that are rewritten to those whose
+	// names match one of these patterns, in the syntax of path.Match, such
+	// as "*_handler.go". A pattern with a slash is matched against the
+	// whole slash-separated path instead. Other files are still consulted.
+	Include []string
+
+	// Match, if set, decides what to rename each candidate identifier to.
+	// It returns the new name, which is c.Name to leave the identifier
+	// alone. If From and Auto are unset, every identifier is a candidate.
+	// Match may be called concurrently.
+	Match func(c Candidate) (string, error)
+
+	// MinConfidence, if set, leaves alone the identifiers whose rename
+	// Rename is less sure of than this, from 0 to 1, as scored by the
+	// heuristics of Candidate.Confidence. They are listed by
+	// File.LowConfidence for review by hand.
+	MinConfidence float64
+
+	// WarnSelectors lists with File.SelectorUses each identifier renamed
+	// where it is selected from something other than an imported package,
+	// as in x.Name, since it is a field or method of a value of a type that
+	// a rename by name can't know, and so most often renamed by mistake.
+	WarnSelectors bool
+
+	// ResolveDotImports type-checks the packages of the files that
+	// dot-import others, importing from source, so that an unqualified
+	// identifier found to be declared by a dot-imported package is renamed
+	// only as a selector on the package would be: with a qualified From
+	// that names the package, or with an unqualified one if the package is
+	// among the files. Otherwise, such an identifier is renamed by its name
+	// alone, as is one with a qualified From whose package name is the
+	// last element of a dot-imported path, and each is listed by
+	// File.DotImportUses.
+	ResolveDotImports bool
+
+	// Filter, if set, is consulted before each candidate identifier is
+	// renamed, and the identifier is left alone if it returns false.
+	// Ancestors are the nodes enclosing the identifier, innermost first,
+	// ending with its *ast.File. Filter may be called concurrently, but
+	// not for the same file.
+	Filter func(id *ast.Ident, ancestors []ast.Node) bool
+
+	// Trace, if set, is told why each candidate identifier was or was not
+	// renamed, and why any file was skipped, in which case name is "".
+	// Trace may be called concurrently.
+	Trace func(pos, name, reason string)
+
+	// OnChange, if set, is called with each identifier as it is renamed,
+	// so that a caller can show progress rather than wait for Rename to
+	// return. If OnChangeAfterWrite is also set, the calls for each file
+	// are instead held until the caller reports with File.Written that it
+	// wrote the file, so that none are seen for files that fail to write.
+	// OnChange may be called concurrently.
+	OnChange           func(Change)
+	OnChangeAfterWrite bool
+}
+
+// A Change is an identifier renamed by Rename, reported to Options.OnChange.
+type Change struct {
+	Path string `json:"path"`
+	Pos  string `json:"pos"` // file:line:column
+	Old  string `json:"old"`
+	New  string `json:"new"`
+}
+
+// A Candidate describes an identifier to Options.Match.
+type Candidate struct {
+	Name string `json:"name"`
+	New  string `json:"new"`            // the name the other options would give it
+	Kind string `json:"kind,omitempty"` // "var", "func", etc., if known
+	Pos  string `json:"pos"`            // file:line:column
+	Decl string `json:"decl,omitempty"` // the enclosing top-level declaration
+
+	// Qualifier is the import path of the package an identifier is
+	// selected from, as in pkg.Name, if known.
+	Qualifier string `json:"qualifier,omitempty"`
+
+	// Confidence is how sure Rename is, from 0 to 1, that the identifier
+	// is the one being renamed rather than something else with its name.
+	Confidence float64 `json:"confidence"`
+}
+
+// A File is a parsed Go source file.
+type File struct {
+	Path string
+
+	src       []byte
+	fset      *token.FileSet
+	f         *ast.File
+	renames   map[string]string // old name -> new name, for each rename made
+	counts    map[string]int    // old name -> identifiers renamed
+	err       error             // the first error from Options.Match
+	broken    bool              // the file has syntax errors
+	conflicts []Conflict
+	changed   bool
+	crlf      bool // most lines of src end in \r\n
+
+	// A lite file, from ParseFileFor, can't change; head holds its
+	// header comments, which f lacks.
+	lite bool
+	head *ast.File
+
+	labels       map[*ast.Ident]bool     // statement labels, which Rename leaves alone
+	parents      map[ast.Node]ast.Node   // for Options.Filter, Match, MinConfidence, and WarnSelectors
+	decls        map[*ast.Ident]declSite // top-level declarations, by name
+	declRenames  []declRename
+	messageEdits []MessageEdit
+
+	lowConfidence []LowConfidence // left alone by Options.MinConfidence
+
+	dot           *dotInfo // if the file dot-imports packages
+	dotImportUses []DotImportUse
+	selectorUses  []SelectorUse // listed by Options.WarnSelectors
+
+	onChange func(Change) // Options.OnChange, if OnChangeAfterWrite is set
+	pending  []Change     // held for onChange until Written
+}
+
+// ParseFile parses the Go source src. Path is used in positions and error
+// messages.
+//
+// If src has syntax errors, ParseFile returns them all, along with a File
+// holding as much of the syntax tree as the parser could recover. Rename
+// consults such a File but never modifies it.
+func ParseFile(path string, src []byte) (*File, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, path, src, parser.ParseComments|parser.AllErrors)
+	if f == nil {
+		return nil, err
+	}
+	file := &File{Path: path, src: src, fset: fset, f: f, renames: make(map[string]string), counts: make(map[string]int)}
+	file.broken = err != nil
+	file.crlf = bytes.Count(src, []byte("\r\n"))*2 > bytes.Count(src, []byte("\n"))
+	return file, err
+}
+
+// Original returns the source the file was parsed from.
+func (f *File) Original() []byte { return f.src }
+
+// PackageNames returns the package name of the file before Rename and
+// after.
+func (f *File) PackageNames() (old, new string) {
+	old = f.f.Name.Name
+	if orig, err := parser.ParseFile(token.NewFileSet(), f.Path, f.src, parser.PackageClauseOnly); err == nil {
+		old = orig.Name.Name
+	}
+	return old, f.f.Name.Name
+}
+
+// Broken reports whether the file has syntax errors.
+func (f *File) Broken() bool { return f.broken }
+
+// Generated reports whether the file is marked as generated code.
+func (f *File) Generated() bool { return ast.IsGenerated(f.header()) }
+
+// Changed reports whether Rename modified the file.
+func (f *File) Changed() bool { return f.changed }
+
+// Renames returns the renames Rename made in the file, from old name to new.
+func (f *File) Renames() map[string]string { return f.renames }
+
+// Occurrences returns how many identifiers were renamed in the file, by
+// old name.
+func (f *File) Occurrences() map[string]int { return f.counts }
+
+// Declared returns the kinds, such as "var" or "func", of the names
+// declared at the top level of the file, by name. Methods are not among
+// them.
+func (f *File) Declared() map[string]string {
+	if f.lite {
+		return declaredKinds(f.f)
+	}
+	m := make(map[string]string)
+	for name, obj := range f.f.Scope.Objects {
+		m[name] = obj.Kind.String()
+	}
+	return m
+}
+
+// ConstFamily returns the names of the constants declared in the file's
+// parenthesized const blocks that start with prefix as a camelCase word,
+// as ColorRed and Color_RED do with Color, in the order declared.
+func (f *File) ConstFamily(prefix string) []string {
+	var names []string
+	for _, d := range f.f.Decls {
+		gd, ok := d.(*ast.GenDecl)
+		if !ok || gd.Tok != token.CONST || !gd.Lparen.IsValid() {
+			continue
+		}
+		for _, spec := range gd.Specs {
+			for _, n := range spec.(*ast.ValueSpec).Names {
+				if rest, ok := strings.CutPrefix(n.Name, prefix); ok && rest != "" {
+					if r, _ := utf8.DecodeRuneInString(rest); unicode.IsUpper(r) || unicode.IsDigit(r) || r == '_' {
+						names = append(names, n.Name)
+					}
+				}
+			}
+		}
+	}
+	return names
+}
+
+// renamed records that an identifier named old was renamed to new.
+func (f *File) renamed(old, new string) {
+	f.renames[old] = new
+	f.counts[old]++
+}
+
+// Written tells Rename's Options.OnChange, if OnChangeAfterWrite is set,
+// about the renames in f, which the caller has written.
+func (f *File) Written() {
+	for _, c := range f.pending {
+		f.onChange(c)
+	}
+	f.pending = nil
+}
+
+// Format returns the file's current source, laid out by gofmt, with CRLF
+// line endings if most of its original lines had them.
+func (f *File) Format() ([]byte, error) { return f.FormatStyle(Style{}) }
+
+// A Style says how FormatStyle lays out source. The zero Style is gofmt's.
+type Style struct {
+	IndentSpaces bool // indent with spaces rather than tabs
+	TabWidth     int  // the width of an indent, 8 if zero
+}
+
+// FormatStyle is like Format, but lays out the source in style s.
+func (f *File) FormatStyle(s Style) ([]byte, error) {
+	printerConf := printer.Config{
+		Mode:     printer.UseSpaces | printer.TabIndent,
+		Tabwidth: 8,
+	}
+	if s.IndentSpaces {
+		printerConf.Mode = printer.UseSpaces
+	}
+	if s.TabWidth > 0 {
+		printerConf.Tabwidth = s.TabWidth
+	}
+	var buf bytes.Buffer
+	if err := printerConf.Fprint(&buf, f.fset, f.f); err != nil {
+		return nil, err
+	}
+	if f.crlf {
+		return bytes.ReplaceAll(buf.Bytes(), []byte("\n"), []byte("\r\n")), nil
+	}
+	return buf.Bytes(), nil
+}
+
+// A renamer holds the state of one call to Rename.
+type renamer struct {
+	Options
+
+	// qualifier is the package name from a qualified From, such as
+	// "pkg" in "pkg.Name". It is empty if From is a bare identifier.
+	qualifier string
+
+	// embedded is set if some struct embeds qualifier.From, and promoted
+	// holds the offsets in each file of the selectors and keys that refer
+	// to such an embedded field.
+	embedded bool
+	promoted map[*File]map[int]bool
+
+	// pairs are the renames that From and To expand to.
+	pairs []pair
+
+	// pkgLevel holds the names declared at the top level of each
+	// directory, whose shadowing locals an unqualified From leaves alone.
+	pkgLevel map[string]map[string]bool
+
+	// declared holds the same for any From, if some file dot-imports
+	// packages, so that the names they may declare can be told apart.
+	declared map[string]map[string]bool
+
+	// generated holds names that Auto leaves alone because generated
+	// code declares them.
+	generated map[string]bool
+
+	// conflicts holds the old -> new pairs that Auto skips because the
+	// new name is taken.
+	conflicts map[[2]string]bool
+
+	// initialisms are those that Auto upper-cases.
+	initialisms map[string]bool
+}
+
+// Rename renames identifiers in files according to opts.
+func Rename(files []*File, opts Options) error {
+	r := &renamer{Options: opts}
+	switch {
+	case r.Auto && (r.From != "" || r.To != "" || r.ToTemplate != ""):
+		return fmt.Errorf("rename: Auto cannot be combined with From and To")
+	case r.To != "" && r.ToTemplate != "":
+		return fmt.Errorf("rename: To cannot be combined with ToTemplate")
+	case r.ToTemplate != "" && r.Word:
+		return fmt.Errorf("rename: ToTemplate cannot be combined with Word")
+	case (r.From == "") != (r.To == "" && r.ToTemplate == ""):
+		return fmt.Errorf("rename: From and To must be set together")
+	case !r.Auto && r.From == "" && r.Match == nil:
+		return fmt.Errorf("rename: nothing to rename")
+	}
+	if r.Auto {
+		if len(r.AutoRules) == 0 {
+			r.AutoRules = LintRules
+		}
+		for _, rule := range r.AutoRules {
+			if !isRule(rule) {
+				return fmt.Errorf("rename: unknown rule %q", rule)
+			}
+		}
+		r.initialisms = commonInitialisms
+		if r.Initialisms != nil {
+			r.initialisms = make(map[string]bool)
+			for _, i := range r.Initialisms {
+				r.initialisms[strings.ToUpper(i)] = true
+			}
+		}
+		r.generated = r.generatedNames(files)
+		r.conflicts = r.findConflicts(files, r.autoConflictName)
+	}
+	if r.ToTemplate != "" {
+		if err := checkTemplate(r.ToTemplate); err != nil {
+			return err
+		}
+	}
+	if i := strings.LastIndex(r.From, "."); i >= 0 {
+		r.qualifier, r.From = r.From[:i], r.From[i+1:]
+		if j := strings.LastIndex(r.To, "."); j >= 0 {
+			if r.To[:j] != r.qualifier {
+				return fmt.Errorf("rename: %s and %s are in different packages", opts.From, opts.To)
+			}
+			r.To = r.To[j+1:]
+		}
+	}
+	for _, t := range r.Companions {
+		if strings.Count(t, "{}") != 1 {
+			return fmt.Errorf("rename: companion template %q must contain exactly one {}", t)
+		}
+	}
+	if r.ToTemplate != "" {
+		r.To = fillTemplate(r.ToTemplate, r.From)
+	}
+	if r.From != "" {
+		r.pairs = r.expand(r.From, r.To)
+		if r.IgnoreCase {
+			r.conflicts = r.findConflicts(files, r.caseConflictName)
+		}
+	}
+	if r.qualifier != "" {
+		if r.embedded = r.embedsQualified(files); r.embedded {
+			var err error
+			if r.promoted, err = embeddedUses(files, r.qualifier, r.matches); err != nil {
+				return err
+			}
+		}
+	} else if r.From != "" {
+		r.pkgLevel = packageLevel(files)
+	}
+	if r.From != "" {
+		r.findDotImports(files)
+	}
+	for _, p := range r.Include {
+		if _, err := path.Match(p, ""); err != nil {
+			return fmt.Errorf("rename: bad Include pattern %q", p)
+		}
+	}
+	return eachFile(files, func(f *File) error {
+		if f.broken {
+			r.trace(f, nil, "file skipped: syntax errors")
+			return nil
+		}
+		if f.lite {
+			return nil // none of the names is in it
+		}
+		if !r.included(f.Path) {
+			r.trace(f, nil, "file skipped: not included")
+			return nil
+		}
+		if !r.Generated && f.Generated() && !f.Mock() {
+			r.trace(f, nil, "file skipped: generated")
+			return nil
+		}
+		return r.rewrite(f)
+	})
+}
+
+// included reports whether Options.Include allows rewriting the file at p.
+func (r *renamer) included(p string) bool {
+	if len(r.Include) == 0 {
+		return true
+	}
+	p = filepath.ToSlash(p)
+	for _, pat := range r.Include {
+		name := path.Base(p)
+		if strings.Contains(pat, "/") {
+			name = p
+		}
+		if ok, _ := path.Match(pat, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// eachFile calls fn on each of files concurrently and returns the first
+// error. The work is all in memory, so at most GOMAXPROCS calls run at once.
+func eachFile(files []*File, fn func(*File) error) error {
+	gate := syncutil.NewGate(runtime.GOMAXPROCS(0))
+	var wg syncutil.Group
+	for _, f := range files {
+		f := f
+		gate.Start()
+		wg.Go(func() error {
+			defer gate.Done()
+			return fn(f)
+		})
+	}
+	return wg.Err()
+}
+
+// Source renames identifiers in srcs, which maps file names to Go source,
+// and returns the new source of each file that changed. It counts each file
+// as written, for Options.OnChangeAfterWrite, once it is formatted.
+func Source(srcs map[string][]byte, opts Options) (map[string][]byte, error) {
+	var paths []string
+	for path := range srcs {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	var files []*File
+	for _, path := range paths {
+		f, err := ParseFile(path, srcs[path])
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, f)
+	}
+	if err := Rename(files, opts); err != nil {
+		return nil, err
+	}
+	out := make(map[string][]byte)
+	for _, f := range files {
+		if !f.changed {
+			continue
+		}
+		src, err := f.Format()
+		if err != nil {
+			return nil, err
+		}
+		out[f.Path] = src
+		f.Written()
+	}
+	return out, nil
+}
+
+// rename renames i to n, unless Options.Match decides otherwise.
+// It reports whether i changed.
+func (r *renamer) rename(f *File, i *ast.Ident, n string) bool {
+	if f.labels[i] {
+		r.trace(f, i, "left alone: label")
+		return false
+	}
+	if r.Filter != nil && f.err == nil && !r.Filter(i, f.ancestors(i)) {
+		r.trace(f, i, "left alone by Filter")
+		return false
+	}
+	if r.Match != nil && f.err == nil {
+		c := Candidate{
+			Name: i.Name,
+			New:  n,
+			Pos:  f.fset.Position(i.Pos()).String(),
+			Decl: enclosingDecl(f.f, i.Pos()),
+		}
+		if i.Obj != nil {
+			c.Kind = i.Obj.Kind.String()
+		}
+		if sel, ok := f.parents[i].(*ast.SelectorExpr); ok && sel.Sel == i {
+			if x, ok := sel.X.(*ast.Ident); ok && x.Obj == nil {
+				c.Qualifier = f.importPath(x.Name)
+			}
+		}
+		c.Confidence = f.confidence(i)
+		var err error
+		if n, err = r.Match(c); err != nil {
+			f.err = err
+			return false
+		}
+		if n == i.Name && c.New != i.Name {
+			r.trace(f, i, "left alone by Match")
+		}
+	}
+	if f.err != nil || n == i.Name {
+		return false
+	}
+	if r.MinConfidence > 0 {
+		if c := f.confidence(i); c < r.MinConfidence {
+			f.lowConfidence = append(f.lowConfidence, LowConfidence{
+				Pos:        f.fset.Position(i.Pos()).String(),
+				Old:        i.Name,
+				New:        n,
+				Confidence: c,
+			})
+			r.trace(f, i, fmt.Sprintf("left alone: confidence %.2f is below MinConfidence", c))
+			return false
+		}
+	}
+	if r.WarnSelectors {
+		f.noteSelector(i, n)
+	}
+	r.trace(f, i, "renamed to "+n)
+	r.change(f, i, n)
+	if d, ok := f.decls[i]; ok {
+		f.declRenames = append(f.declRenames, declRename{declSite: d, old: i.Name, new: n})
+	}
+	f.renamed(i.Name, n)
+	i.Name = n
+	return true
+}
+
+// change tells Options.OnChange that i is being renamed to n, or holds the
+// news until f is written.
+func (r *renamer) change(f *File, i *ast.Ident, n string) {
+	if r.OnChange == nil {
+		return
+	}
+	c := Change{Path: f.Path, Pos: f.fset.Position(i.Pos()).String(), Old: i.Name, New: n}
+	if r.OnChangeAfterWrite {
+		f.onChange = r.OnChange
+		f.pending = append(f.pending, c)
+		return
+	}
+	r.OnChange(c)
+}
+
+// trace tells Options.Trace about i, or about f if i is nil.
+func (r *renamer) trace(f *File, i *ast.Ident, reason string) {
+	if r.Trace == nil {
+		return
+	}
+	if i == nil {
+		r.Trace(f.Path, "", reason)
+		return
+	}
+	r.Trace(f.fset.Position(i.Pos()).String(), i.Name, reason)
+}
+
+// embedsQualified reports whether any struct in files embeds qualifier.From,
+// directly or through a pointer. If one does, the embedded field is also
+// named From, and accesses to it look like ordinary field selectors.
+func (r *renamer) embedsQualified(files []*File) bool {
+	found := false
+	for _, f := range files {
+		ast.Inspect(f.f, func(node ast.Node) bool {
+			st, ok := node.(*ast.StructType)
+			if !ok || found {
+				return !found
+			}
+			for _, field := range st.Fields.List {
+				if len(field.Names) > 0 {
+					continue
+				}
+				t := field.Type
+				if star, ok := t.(*ast.StarExpr); ok {
+					t = star.X
+				}
+				if sel, ok := t.(*ast.SelectorExpr); ok && r.matches(sel.Sel.Name) {
+					if x, ok := sel.X.(*ast.Ident); ok && x.Name == r.qualifier {
+						found = true
+					}
+				}
+			}
+			return true
+		})
+	}
+	return found
+}
+
+func (r *renamer) rewrite(f *File) error {
+	f.decls = topLevelDecls(f.f)
+	f.labels = labelIdents(f.f)
+	if r.Filter != nil || r.Match != nil || r.MinConfidence > 0 || r.WarnSelectors {
+		f.parents = parents(f.f)
+	}
+	var changed bool
+	switch {
+	case r.Auto:
+		changed = r.renameAuto(f)
+	case r.From != "" && r.qualifier != "":
+		changed = r.renameQualified(f)
+	case r.From != "":
+		changed = r.renameIdents(f)
+	default:
+		changed = r.renameAll(f)
+	}
+	if f.err != nil {
+		return f.err
+	}
+	renames := make(map[string]string)
+	for _, p := range r.pairs {
+		renames[p.from] = p.to
+	}
+	for old, n := range f.renames {
+		renames[old] = n
+	}
+	if rewriteDocs(f) {
+		changed = true
+	}
+	if rewriteDirectives(f.f, renames) {
+		changed = true
+	}
+	if r.Messages && rewriteMessages(f, renames) {
+		changed = true
+	}
+	f.changed = f.changed || changed // as by Merge
+	return nil
+}
+
+// autoExcluded returns why Auto may not rename i to n, or "" if it may.
+func (r *renamer) autoExcluded(i *ast.Ident, n string) string {
+	switch {
+	case r.ExportedOnly && !i.IsExported():
+		return "not exported"
+	case r.generated[i.Name]:
+		return "declared by generated code"
+	case strings.HasPrefix(i.Name, "XXX_"):
+		return "protobuf internal name"
+	case r.conflicts[[2]string{i.Name, n}]:
+		return n + " is already declared"
+	}
+	return ""
+}
+
+func (r *renamer) renameAuto(f *File) (changed bool) {
+	ast.Inspect(f.f, func(node ast.Node) bool {
+		i, ok := node.(*ast.Ident)
+		if !ok {
+			return true
+		}
+		n := r.autoName(i.Name)
+		if n == i.Name {
+			return true
+		}
+		if why := r.autoExcluded(i, n); why != "" {
+			r.trace(f, i, "left alone: "+why)
+		} else if r.rename(f, i, n) {
+			changed = true
+		}
+		return true
+	})
+	return changed
+}
+
+// renameTo renames i according to From and To, if it matches.
+func (r *renamer) renameTo(f *File, i *ast.Ident) bool {
+	n, ok := r.newName(i.Name)
+	if ok && r.conflicts[[2]string{i.Name, n}] {
+		r.trace(f, i, "left alone: "+n+" is already declared")
+		return false
+	}
+	return ok && r.rename(f, i, n)
+}
+
+// renameIdents renames each identifier in f that From matches, except the
+// locals that shadow a package-level declaration of the same name, which
+// are different things that happen to share it.
+func (r *renamer) renameIdents(f *File) (changed bool) {
+	shadowed := r.pkgLevel[filepath.Dir(f.Path)]
+	locals := localObjects(f.f)
+	ast.Inspect(f.f, func(node ast.Node) bool {
+		i, ok := node.(*ast.Ident)
+		if !ok {
+			return true
+		}
+		if i.Obj != nil && locals[i.Obj] && shadowed[i.Name] && r.matches(i.Name) {
+			r.trace(f, i, "left alone: local that shadows a package-level declaration")
+			return true
+		}
+		if dotted, c := r.renameDotImported(f, i); dotted {
+			changed = changed || c
+			return true
+		}
+		if r.renameTo(f, i) {
+			changed = true
+		}
+		return true
+	})
+	return changed
+}
+
+// renameAll offers every identifier in f to Options.Match.
+func (r *renamer) renameAll(f *File) (changed bool) {
+	ast.Inspect(f.f, func(node ast.Node) bool {
+		if i, ok := node.(*ast.Ident); ok && r.rename(f, i, i.Name) {
+			changed = true
+		}
+		return true
+	})
+	return changed
+}
+
+// renameQualified renames qualifier.From to qualifier.To. Inside the package
+// named qualifier, every identifier named From is renamed, as with an
+// unqualified From. Elsewhere, only selectors on the package are renamed,
+// plus, if a struct embeds the type, the field selectors and composite
+// literal keys that type checking finds refer to the embedded field.
+func (r *renamer) renameQualified(f *File) (changed bool) {
+	if f.f.Name.Name == r.qualifier {
+		return r.renameIdents(f)
+	}
+	inScope := make(map[*ast.Ident]bool)
+	promoted := func(i *ast.Ident) bool { return r.promoted[f][f.fset.Position(i.Pos()).Offset] }
+	ast.Inspect(f.f, func(node ast.Node) bool {
+		switch n := node.(type) {
+		case *ast.Ident:
+			if dotted, c := r.renameDotImported(f, n); dotted {
+				inScope[n] = true
+				changed = changed || c
+			}
+		case *ast.SelectorExpr:
+			// Package names are never resolved by the parser, so an
+			// identifier with an Obj is a local that shadows the import.
+			if x, ok := n.X.(*ast.Ident); ok && x.Name == r.qualifier && x.Obj == nil || promoted(n.Sel) {
+				inScope[n.Sel] = true
+				if r.renameTo(f, n.Sel) {
+					changed = true
+				}
+			}
+		case *ast.CompositeLit:
+			if !r.embedded {
+				break
+			}
+			for _, elt := range n.Elts {
+				if kv, ok := elt.(*ast.KeyValueExpr); ok {
+					if k, ok := kv.Key.(*ast.Ident); ok && promoted(k) {
+						inScope[k] = true
+						if r.renameTo(f, k) {
+							changed = true
+						}
+					}
+				}
+			}
+		}
+		return true
+	})
+	if r.Trace != nil {
+		ast.Inspect(f.f, func(node ast.Node) bool {
+			if i, ok := node.(*ast.Ident); ok && !inScope[i] && !f.labels[i] {
+				if _, ok := r.newName(i.Name); ok {
+					r.trace(f, i, "left alone: not qualified by "+r.qualifier)
+				}
+			}
+			return true
+		})
+	}
+	return changed
+}
+
+// rewriteDirectives applies renames to the words of any //go:generate
+// directives in f, so that regenerated code is built from the new names.
+func rewriteDirectives(f *ast.File, renames map[string]string) (changed bool) {
+	for _, cg := range f.Comments {
+		for _, c := range cg.List {
+			if !strings.HasPrefix(c.Text, "//go:generate ") {
+				continue
+			}
+			if t := replaceWords(c.Text, renames); t != c.Text {
+				changed = true
+				c.Text = t
+			}
+		}
+	}
+	return changed
+}
+
+// parents maps each node in f to the node that encloses it.
+func parents(f *ast.File) map[ast.Node]ast.Node {
+	m := make(map[ast.Node]ast.Node)
+	var stack []ast.Node
+	ast.Inspect(f, func(n ast.Node) bool {
+		if n == nil {
+			stack = stack[:len(stack)-1]
+			return false
+		}
+		if len(stack) > 0 {
+			m[n] = stack[len(stack)-1]
+		}
+		stack = append(stack, n)
+		return true
+	})
+	return m
+}
+
+// ancestors returns the nodes enclosing n, innermost first.
+func (f *File) ancestors(n ast.Node) []ast.Node {
+	var path []ast.Node
+	for p, ok := f.parents[n]; ok; p, ok = f.parents[p] {
+		path = append(path, p)
+	}
+	return path
+}
+
+// enclosingDecl returns the name of the top-level declaration in f that
+// contains pos, or "" if there is none.
+func enclosingDecl(f *ast.File, pos token.Pos) string {
+	for _, d := range f.Decls {
+		if pos < d.Pos() || pos >= d.End() {
+			continue
+		}
+		switch d := d.(type) {
+		case *ast.FuncDecl:
+			if d.Recv != nil && len(d.Recv.List) > 0 {
+				return "(" + exprString(d.Recv.List[0].Type) + ")." + d.Name.Name
+			}
+			return d.Name.Name
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				if pos < spec.Pos() || pos >= spec.End() {
+					continue
+				}
+				switch spec := spec.(type) {
+				case *ast.TypeSpec:
+					return spec.Name.Name
+				case *ast.ValueSpec:
+					return spec.Names[0].Name
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// exprString renders a receiver type expression such as *T or T[K].
+func exprString(e ast.Expr) string {
+	switch e := e.(type) {
+	case *ast.Ident:
+		return e.Name
+	case *ast.StarExpr:
+		return "*" + exprString(e.X)
+	case *ast.IndexExpr:
+		return exprString(e.X)
+	case *ast.IndexListExpr:
+		return exprString(e.X)
+	}
+	return ""
+}
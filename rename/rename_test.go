@@ -0,0 +1,79 @@
+package rename_test
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/jeremyschlatter/gorename-global/rename"
+)
+
+// names returns the names of the files of fsys, sorted.
+func names(fsys rename.MemFS) []string {
+	var ns []string
+	for name := range fsys {
+		ns = append(ns, name)
+	}
+	sort.Strings(ns)
+	return ns
+}
+
+// renameFS renames in the files of fsys with opts, failing t on any error,
+// and returns fsys with the renamed files written back.
+func renameFS(t *testing.T, fsys rename.MemFS, opts rename.Options) rename.MemFS {
+	t.Helper()
+	if _, err := rename.RenameFS(fsys, names(fsys), opts); err != nil {
+		t.Fatal(err)
+	}
+	return fsys
+}
+
+// changeFS parses the files of fsys, changes them with do, and writes back
+// those that changed. It returns the files, with do's error.
+func changeFS(t *testing.T, fsys rename.MemFS, do func([]*rename.File) error) ([]*rename.File, error) {
+	t.Helper()
+	var files []*rename.File
+	for _, name := range names(fsys) {
+		f, err := rename.ParseFS(fsys, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, f)
+	}
+	if err := do(files); err != nil {
+		return files, err
+	}
+	for _, f := range files {
+		if !f.Changed() {
+			continue
+		}
+		src, err := f.Format()
+		if err != nil {
+			t.Fatal(err)
+		}
+		fsys.WriteFile(f.Path, src, 0666)
+	}
+	return files, nil
+}
+
+// conflicts returns the old -> new renames of the conflicts found in files,
+// sorted.
+func conflicts(files []*rename.File) []string {
+	var cs []string
+	for _, f := range files {
+		for _, c := range f.Conflicts() {
+			cs = append(cs, c.Old+" -> "+c.New)
+		}
+	}
+	sort.Strings(cs)
+	return cs
+}
+
+// checkFS reports the files of got whose contents aren't as want says.
+func checkFS(t *testing.T, got rename.MemFS, want map[string]string) {
+	t.Helper()
+	for name, w := range want {
+		if g := string(got[name]); g != w {
+			t.Errorf("%s:\n%s\nwant:\n%s", name, g, w)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package rename
+
+import (
+	"fmt"
+	"go/ast"
+	"strings"
+)
+
+// RenameResults renames the named result from of the function fn, and its
+// uses, to to. fn is a function name, or a method name qualified by its
+// receiver type, as in "Server.Close" or "(*Server).Close". If to is
+// already used in the function, the result is left alone and the clash
+// reported with File.Conflicts.
+func RenameResults(files []*File, fn, from, to string) error {
+	if from == to {
+		return fmt.Errorf("rename: %s is already named %s", from, to)
+	}
+	return eachFile(files, func(f *File) error {
+		if f.broken || f.Generated() && !f.Mock() {
+			return nil
+		}
+		for _, d := range f.f.Decls {
+			if fd, ok := d.(*ast.FuncDecl); ok && isFunc(fd, fn) && fd.Type.Results != nil {
+				f.renameResult(fd, from, to)
+			}
+		}
+		return nil
+	})
+}
+
+// isFunc reports whether fd declares fn, a function name or a method name
+// qualified by its receiver type, as in "Server.Close" or "(*Server).Close".
+func isFunc(fd *ast.FuncDecl, fn string) bool {
+	recv, name := "", fn
+	if i := strings.LastIndex(fn, "."); i >= 0 {
+		recv, name = strings.Trim(fn[:i], "(*)"), fn[i+1:]
+	}
+	r := ""
+	if fd.Recv != nil && len(fd.Recv.List) > 0 {
+		r = recvType(fd.Recv.List[0].Type)
+	}
+	return fd.Name.Name == name && r == recv
+}
+
+// renameResult renames fd's result from to to.
+func (f *File) renameResult(fd *ast.FuncDecl, from, to string) {
+	for _, field := range fd.Type.Results.List {
+		for _, id := range field.Names {
+			if id.Name != from {
+				continue
+			}
+			if clash := f.renameInFunc(fd, id, to); clash != nil {
+				f.conflicts = append(f.conflicts, Conflict{
+					Old:  from,
+					New:  to,
+					Pos:  f.fset.Position(id.Pos()).String(),
+					With: f.fset.Position(clash.Pos()).String(),
+				})
+			}
+			return
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package rename
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// The rules that Options.AutoRules can name.
+const (
+	RuleUnderscores = "underscores" // user_name becomes userName
+	RuleInitialisms = "initialisms" // userId becomes userID
+	RuleSpelling    = "spelling"    // recieveMsg becomes receiveMsg
+	RuleHungarian   = "hungarian"   // strName becomes name
+)
+
+// LintRules are the rules that together match what 'go lint' flags. They
+// are the default for Options.Auto.
+var LintRules = []string{RuleUnderscores, RuleInitialisms}
+
+// AllRules are all the rules Options.AutoRules can name.
+var AllRules = []string{RuleUnderscores, RuleInitialisms, RuleSpelling, RuleHungarian}
+
+func isRule(rule string) bool {
+	for _, r := range append(AllRules, LocaleRules...) {
+		if r == rule {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *renamer) hasRule(rule string) bool {
+	for _, x := range r.AutoRules {
+		if x == rule {
+			return true
+		}
+	}
+	return false
+}
+
+// autoName returns the name that the selected rules would give name.
+func (r *renamer) autoName(name string) string {
+	underscores, initialisms := r.hasRule(RuleUnderscores), r.hasRule(RuleInitialisms)
+	if r.hasRule(RuleSpelling) {
+		name = correctSpelling(name)
+	}
+	switch {
+	case r.hasRule(RuleUSSpelling):
+		name = respell(name, ukToUS)
+	case r.hasRule(RuleUKSpelling):
+		name = respell(name, usToUK)
+	}
+	if r.hasRule(RuleHungarian) {
+		prefixes := r.HungarianPrefixes
+		if len(prefixes) == 0 {
+			prefixes = HungarianPrefixes
+		}
+		name = stripHungarian(name, prefixes)
+	}
+	switch {
+	case underscores && initialisms:
+		return lintName(name, r.initialisms)
+	case underscores:
+		return removeUnderscores(name)
+	case initialisms:
+		return fixInitialisms(name, r.initialisms)
+	}
+	return name
+}
+
+// removeUnderscores does the part of lintName that joins words separated
+// by underscores, capitalizing each word after the first. Leading
+// underscores, and single underscores between digits, are kept.
+func removeUnderscores(name string) string {
+	ws := words(name)
+	var b strings.Builder
+	seen := false // whether a word other than underscores has been written
+	for i, w := range ws {
+		switch {
+		case strings.Trim(w, "_") != "":
+			if seen && ws[i-1][0] == '_' && strings.ToLower(w) == w {
+				r, n := utf8.DecodeRuneInString(w)
+				w = string(unicode.ToUpper(r)) + w[n:]
+			}
+			b.WriteString(w)
+			seen = true
+		case !seen:
+			b.WriteString(w)
+		case i+1 < len(ws) && isDigit(lastRune(ws[i-1])) && isDigit([]rune(ws[i+1])[0]):
+			b.WriteByte('_')
+		}
+	}
+	return b.String()
+}
+
+// fixInitialisms does the part of lintName that upper-cases common
+// initialisms, leaving underscores alone.
+func fixInitialisms(name string, initialisms map[string]bool) string {
+	ws := words(name)
+	for i, w := range ws {
+		u := strings.ToUpper(w)
+		if !initialisms[u] {
+			continue
+		}
+		// Keep consistent case, which is lowercase only at the start.
+		if i == 0 && unicode.IsLower([]rune(w)[0]) {
+			u = strings.ToLower(u)
+		}
+		ws[i] = u
+	}
+	return strings.Join(ws, "")
+}
+
+func isDigit(r rune) bool { return unicode.IsDigit(r) }
+
+func lastRune(s string) rune {
+	r := []rune(s)
+	return r[len(r)-1]
+}
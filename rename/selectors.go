@@ -0,0 +1,39 @@
+package rename
+
+import (
+	"go/ast"
+	"go/types"
+)
+
+// A SelectorUse is an identifier that Rename renamed where it is selected
+// from something other than an imported package, as in x.Name, so that it
+// is a field or method of a value whose type a rename by name can't know,
+// and may well be another type's that shares the name.
+type SelectorUse struct {
+	Pos       string `json:"pos"` // file:line:column
+	Qualifier string `json:"qualifier"`
+	Old       string `json:"old"`
+	New       string `json:"new"`
+}
+
+// SelectorUses returns the identifiers in f that Options.WarnSelectors
+// listed.
+func (f *File) SelectorUses() []SelectorUse { return f.selectorUses }
+
+// noteSelector records i, which is being renamed to n, in f.selectorUses
+// if it is selected from something other than an imported package.
+func (f *File) noteSelector(i *ast.Ident, n string) {
+	sel, ok := f.parents[i].(*ast.SelectorExpr)
+	if !ok || sel.Sel != i {
+		return
+	}
+	if x, ok := sel.X.(*ast.Ident); ok && x.Obj == nil && f.importPath(x.Name) != "" {
+		return // qualified by a package
+	}
+	f.selectorUses = append(f.selectorUses, SelectorUse{
+		Pos:       f.fset.Position(i.Pos()).String(),
+		Qualifier: types.ExprString(sel.X),
+		Old:       i.Name,
+		New:       n,
+	})
+}
@@ -0,0 +1,88 @@
+package rename
+
+import (
+	"go/ast"
+	"go/token"
+	"path/filepath"
+)
+
+// packageLevel returns the names declared at the top level of each
+// directory's files, by directory.
+func packageLevel(files []*File) map[string]map[string]bool {
+	m := make(map[string]map[string]bool)
+	for _, f := range files {
+		dir := filepath.Dir(f.Path)
+		if m[dir] == nil {
+			m[dir] = make(map[string]bool)
+		}
+		for name := range f.Declared() {
+			m[dir][name] = true
+		}
+	}
+	return m
+}
+
+// localObjects returns the objects that f declares inside functions: their
+// receivers, parameters, and results, and the variables, constants, and
+// types declared in their bodies. Struct fields are not among them, even in
+// types declared in functions, since selectors reach them without any
+// object to go by.
+func localObjects(f *ast.File) map[*ast.Object]bool {
+	locals := make(map[*ast.Object]bool)
+	add := func(ids ...*ast.Ident) {
+		for _, id := range ids {
+			if id.Obj != nil {
+				locals[id.Obj] = true
+			}
+		}
+	}
+	addFields := func(fl *ast.FieldList) {
+		if fl != nil {
+			for _, field := range fl.List {
+				add(field.Names...)
+			}
+		}
+	}
+	var inFunc func(n ast.Node) bool
+	inFunc = func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncLit:
+			addFields(n.Type.Params)
+			addFields(n.Type.Results)
+		case *ast.AssignStmt:
+			if n.Tok == token.DEFINE {
+				for _, e := range n.Lhs {
+					if id, ok := e.(*ast.Ident); ok {
+						add(id)
+					}
+				}
+			}
+		case *ast.RangeStmt:
+			if n.Tok == token.DEFINE {
+				for _, e := range []ast.Expr{n.Key, n.Value} {
+					if id, ok := e.(*ast.Ident); ok {
+						add(id)
+					}
+				}
+			}
+		case *ast.ValueSpec:
+			add(n.Names...)
+		case *ast.TypeSpec:
+			add(n.Name)
+		}
+		return true
+	}
+	for _, d := range f.Decls {
+		fd, ok := d.(*ast.FuncDecl)
+		if !ok {
+			continue
+		}
+		addFields(fd.Recv)
+		addFields(fd.Type.Params)
+		addFields(fd.Type.Results)
+		if fd.Body != nil {
+			ast.Inspect(fd.Body, inFunc)
+		}
+	}
+	return locals
+}
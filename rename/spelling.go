@@ -0,0 +1,142 @@
+package rename
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// misspellings maps common misspellings of words found in identifiers, in
+// lower case, to their corrections, in the manner of the misspell tool.
+var misspellings = map[string]string{
+	"accomodate":    "accommodate",
+	"accross":       "across",
+	"acheive":       "achieve",
+	"adress":        "address",
+	"agressive":     "aggressive",
+	"aquire":        "acquire",
+	"arguement":     "argument",
+	"assosiate":     "associate",
+	"attribue":      "attribute",
+	"availabe":      "available",
+	"availible":     "available",
+	"begining":      "beginning",
+	"beleive":       "believe",
+	"calender":      "calendar",
+	"cancelation":   "cancellation",
+	"comming":       "coming",
+	"commited":      "committed",
+	"committment":   "commitment",
+	"compatability": "compatibility",
+	"compatable":    "compatible",
+	"completly":     "completely",
+	"concurent":     "concurrent",
+	"conection":     "connection",
+	"consistant":    "consistent",
+	"containg":      "containing",
+	"curent":        "current",
+	"defualt":       "default",
+	"definately":    "definitely",
+	"dependancy":    "dependency",
+	"dependant":     "dependent",
+	"destory":       "destroy",
+	"diffrent":      "different",
+	"enviroment":    "environment",
+	"existant":      "existent",
+	"explicitely":   "explicitly",
+	"failiure":      "failure",
+	"familar":       "familiar",
+	"finaly":        "finally",
+	"foward":        "forward",
+	"fucntion":      "function",
+	"funtion":       "function",
+	"garantee":      "guarantee",
+	"guarentee":     "guarantee",
+	"heigth":        "height",
+	"immediatly":    "immediately",
+	"independant":   "independent",
+	"infomation":    "information",
+	"initalize":     "initialize",
+	"intial":        "initial",
+	"lenght":        "length",
+	"maintainance":  "maintenance",
+	"managment":     "management",
+	"mesage":        "message",
+	"messsage":      "message",
+	"neccessary":    "necessary",
+	"necessery":     "necessary",
+	"occured":       "occurred",
+	"occurence":     "occurrence",
+	"paramter":      "parameter",
+	"parrallel":     "parallel",
+	"persistant":    "persistent",
+	"posible":       "possible",
+	"prefered":      "preferred",
+	"previos":       "previous",
+	"proccess":      "process",
+	"recieve":       "receive",
+	"reciever":      "receiver",
+	"recieved":      "received",
+	"reconnet":      "reconnect",
+	"recursivly":    "recursively",
+	"refered":       "referred",
+	"reponse":       "response",
+	"repsonse":      "response",
+	"requst":        "request",
+	"resouce":       "resource",
+	"responce":      "response",
+	"retreive":      "retrieve",
+	"seperate":      "separate",
+	"seperator":     "separator",
+	"sucess":        "success",
+	"succesful":     "successful",
+	"successfull":   "successful",
+	"supress":       "suppress",
+	"targer":        "target",
+	"threshhold":    "threshold",
+	"tranform":      "transform",
+	"transfered":    "transferred",
+	"trasnport":     "transport",
+	"truely":        "truly",
+	"unneccessary":  "unnecessary",
+	"untill":        "until",
+	"vaule":         "value",
+	"wether":        "whether",
+	"writting":      "writing",
+}
+
+// correctSpelling returns name with each of its camelCase words that is a
+// common misspelling corrected, in the case of the word it replaces, so
+// that "recieveMsg" becomes "receiveMsg" and "MAX_LENGHT" "MAX_LENGTH".
+func correctSpelling(name string) string {
+	return replaceEachWord(name, func(w string) (string, bool) {
+		c, ok := misspellings[strings.ToLower(w)]
+		return c, ok
+	})
+}
+
+// replaceEachWord returns name with each of its camelCase words for which
+// replace returns a word, in lower case, replaced by that word, in the
+// case of the word it replaces.
+func replaceEachWord(name string, replace func(w string) (string, bool)) string {
+	ws := words(name)
+	for i, w := range ws {
+		if c, ok := replace(w); ok {
+			ws[i] = wordCaseLike(c, w)
+		}
+	}
+	return strings.Join(ws, "")
+}
+
+// wordCaseLike returns the lower-case word w in the case of like: upper,
+// capitalized, or lower.
+func wordCaseLike(w, like string) string {
+	switch r, _ := utf8.DecodeRuneInString(like); {
+	case isUpper(like):
+		return strings.ToUpper(w)
+	case unicode.IsUpper(r):
+		r, n := utf8.DecodeRuneInString(w)
+		return string(unicode.ToUpper(r)) + w[n:]
+	}
+	return w
+}
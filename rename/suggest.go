@@ -0,0 +1,103 @@
+package rename
+
+import (
+	"go/ast"
+	"go/token"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// A Suggestion is a poorly named identifier found by Suggest.
+type Suggestion struct {
+	Pos    string `json:"pos"` // of the declaration, file:line:column
+	Name   string `json:"name"`
+	New    string `json:"new,omitempty"` // the name proposed, if there is one
+	Reason string `json:"reason"`
+
+	// Taken is set if the package already declares New at the top level.
+	Taken bool `json:"taken,omitempty"`
+}
+
+// Suggest returns the identifiers declared in files that are poorly named
+// in ways the lint rules don't catch, with better names where it can find
+// them, by position: single-letter names at the top level of a package,
+// which are left to be named by hand; names with words that are common
+// misspellings; and names starting with one of hungarianPrefixes, as
+// HungarianPrefixes are. Generated files, and those with syntax errors,
+// are left out.
+func Suggest(files []*File, hungarianPrefixes []string) []Suggestion {
+	declared := make(map[string]map[string]bool) // top-level names, by directory
+	for _, f := range files {
+		dir := filepath.Dir(f.Path)
+		if declared[dir] == nil {
+			declared[dir] = make(map[string]bool)
+		}
+		for name := range f.Declared() {
+			declared[dir][name] = true
+		}
+	}
+	var (
+		ss  []Suggestion
+		pos = make(map[string]token.Position) // by Pos, for sorting
+	)
+	for _, f := range files {
+		if f.broken || f.Generated() {
+			continue
+		}
+		for _, id := range declIdents(f.f) {
+			p := f.fset.Position(id.Pos())
+			s := Suggestion{Pos: p.String(), Name: id.Name}
+			pos[s.Pos] = p
+			var reasons []string
+			n := id.Name
+			if c := correctSpelling(n); c != n {
+				n = c
+				reasons = append(reasons, "misspelled")
+			}
+			if c := stripHungarian(n, hungarianPrefixes); c != n {
+				n = c
+				reasons = append(reasons, "Hungarian prefix")
+			}
+			topLevel := id.Obj != nil && f.f.Scope.Lookup(id.Name) == id.Obj
+			if len(id.Name) == 1 && topLevel {
+				reasons = append(reasons, "single-letter package-level name")
+				n = ""
+			}
+			if len(reasons) == 0 {
+				continue
+			}
+			s.New, s.Reason = n, strings.Join(reasons, ", ")
+			s.Taken = topLevel && n != "" && declared[filepath.Dir(f.Path)][n]
+			ss = append(ss, s)
+		}
+	}
+	sort.Slice(ss, func(i, j int) bool {
+		a, b := pos[ss[i].Pos], pos[ss[j].Pos]
+		if a.Filename != b.Filename {
+			return a.Filename < b.Filename
+		}
+		return a.Offset < b.Offset
+	})
+	return ss
+}
+
+// declIdents returns the identifiers that declare names in f, other than
+// its package name and blank identifiers.
+func declIdents(f *ast.File) []*ast.Ident {
+	var ids []*ast.Ident
+	ast.Inspect(f, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncDecl:
+			if n.Recv != nil {
+				ids = append(ids, n.Name) // methods have no Obj
+			}
+		case *ast.Ident:
+			if n.Name != "_" && n.Obj != nil && n.Obj.Pos() == n.Pos() {
+				ids = append(ids, n)
+			}
+		}
+		return true
+	})
+	return ids
+}
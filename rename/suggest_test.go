@@ -0,0 +1,24 @@
+package rename_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jeremyschlatter/gorename-global/rename"
+)
+
+func TestSuggestOrder(t *testing.T) {
+	fsys := rename.MemFS{
+		"p/p.go": []byte("package p\n\n" + strings.Repeat("\n", 6) + "var strName string\n\nvar intCount int\n"),
+	}
+	files, _ := changeFS(t, fsys, func([]*rename.File) error { return nil })
+	var got []string
+	for _, s := range rename.Suggest(files, []string{"str", "int"}) {
+		got = append(got, s.Pos+" "+s.Name)
+	}
+	want := []string{"p/p.go:9:5 strName", "p/p.go:11:5 intCount"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Suggest:\n%q\nwant:\n%q", got, want)
+	}
+}
@@ -0,0 +1,62 @@
+package rename
+
+import (
+	"fmt"
+	"strings"
+)
+
+// templateCases are the placeholders of Options.ToTemplate and the case
+// transforms they apply to the name they stand for.
+var templateCases = map[string]func(string) string{
+	"orig":       func(name string) string { return name },
+	"exported":   export,
+	"unexported": unexport,
+	"snake":      func(name string) string { return strings.ToLower(snake(name)) },
+	"SNAKE":      func(name string) string { return strings.ToUpper(snake(name)) },
+}
+
+// snake joins the words of name with underscores.
+func snake(name string) string {
+	var ws []string
+	for _, w := range words(name) {
+		if strings.Trim(w, "_") != "" {
+			ws = append(ws, w)
+		}
+	}
+	return strings.Join(ws, "_")
+}
+
+// checkTemplate reports an unknown or unterminated placeholder in t.
+func checkTemplate(t string) error {
+	for rest := t; ; {
+		i := strings.Index(rest, "{")
+		if i < 0 {
+			return nil
+		}
+		j := strings.Index(rest[i:], "}")
+		if j < 0 {
+			return fmt.Errorf("rename: unterminated placeholder in template %q", t)
+		}
+		if templateCases[rest[i+1:i+j]] == nil {
+			return fmt.Errorf("rename: unknown placeholder %s in template %q", rest[i:i+j+1], t)
+		}
+		rest = rest[i+j+1:]
+	}
+}
+
+// fillTemplate fills in the placeholders of t, which checkTemplate accepts,
+// with name, so that "{orig}Legacy" and "Client" give "ClientLegacy".
+func fillTemplate(t, name string) string {
+	var b strings.Builder
+	for {
+		i := strings.Index(t, "{")
+		if i < 0 {
+			b.WriteString(t)
+			return b.String()
+		}
+		j := strings.Index(t[i:], "}")
+		b.WriteString(t[:i])
+		b.WriteString(templateCases[t[i+1:i+j]](name))
+		t = t[i+j+1:]
+	}
+}
@@ -0,0 +1,228 @@
+package rename
+
+import (
+	"fmt"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"path/filepath"
+)
+
+// A typedPkg is one package of the Files given to RenameField or
+// RenameMethod, type-checked.
+type typedPkg struct {
+	files []*File
+	asts  []*ast.File // files, parsed again into the shared FileSet
+	pkg   *types.Package
+	info  *types.Info
+}
+
+// checkPackages type-checks files, a package per directory and package
+// name, importing other packages from source. Type errors are ignored, so
+// that whatever can be resolved is. The files are parsed into one FileSet
+// under their absolute paths, so that a declaration has the same position
+// whether it is seen by its own package or through an import.
+func checkPackages(files []*File) (*token.FileSet, []*typedPkg, error) {
+	fset := token.NewFileSet()
+	var pkgs []*typedPkg
+	byKey := make(map[string]*typedPkg)
+	for _, f := range files {
+		if f.broken {
+			continue
+		}
+		abs, err := filepath.Abs(f.Path)
+		if err != nil {
+			return nil, nil, err
+		}
+		af, err := parser.ParseFile(fset, abs, f.src, parser.ParseComments)
+		if err != nil {
+			continue
+		}
+		key := filepath.Dir(abs) + " " + af.Name.Name
+		p := byKey[key]
+		if p == nil {
+			p = &typedPkg{}
+			byKey[key] = p
+			pkgs = append(pkgs, p)
+		}
+		p.files = append(p.files, f)
+		p.asts = append(p.asts, af)
+	}
+	imp := importer.ForCompiler(fset, "source", nil)
+	for _, p := range pkgs {
+		conf := types.Config{Importer: imp, Error: func(error) {}}
+		p.info = &types.Info{
+			Defs:       make(map[*ast.Ident]types.Object),
+			Uses:       make(map[*ast.Ident]types.Object),
+			Selections: make(map[*ast.SelectorExpr]*types.Selection),
+		}
+		p.pkg, _ = conf.Check(p.asts[0].Name.Name, fset, p.asts, p.info)
+	}
+	return fset, pkgs, nil
+}
+
+// lookupTypes returns the type named name, in any of pkgs or the packages
+// they import, or only in those called qualifier if it is set.
+func lookupTypes(pkgs []*typedPkg, qualifier, name string) []*types.TypeName {
+	var tns []*types.TypeName
+	seen := make(map[*types.Package]bool)
+	for _, p := range pkgs {
+		if p.pkg == nil {
+			continue
+		}
+		for _, pkg := range append([]*types.Package{p.pkg}, p.pkg.Imports()...) {
+			if seen[pkg] || qualifier != "" && pkg.Name() != qualifier {
+				continue
+			}
+			seen[pkg] = true
+			if tn, ok := pkg.Scope().Lookup(name).(*types.TypeName); ok {
+				tns = append(tns, tn)
+			}
+		}
+	}
+	return tns
+}
+
+// embeddedUses returns the offsets in each of files of the selectors and
+// composite literal keys that refer to a field embedding qualifier.Name,
+// or *qualifier.Name, for a Name that match reports true for, found by
+// type-checking files. Same-named fields and methods of other types are
+// left out.
+func embeddedUses(files []*File, qualifier string, match func(string) bool) (map[*File]map[int]bool, error) {
+	fset, pkgs, err := checkPackages(files)
+	if err != nil {
+		return nil, err
+	}
+	// The embedded fields, by the position of their type's name.
+	fields := make(map[token.Position]bool)
+	for _, p := range pkgs {
+		for _, af := range p.asts {
+			ast.Inspect(af, func(n ast.Node) bool {
+				st, ok := n.(*ast.StructType)
+				if !ok {
+					return true
+				}
+				for _, field := range st.Fields.List {
+					if len(field.Names) > 0 {
+						continue
+					}
+					t := field.Type
+					if star, ok := t.(*ast.StarExpr); ok {
+						t = star.X
+					}
+					if sel, ok := t.(*ast.SelectorExpr); ok && match(sel.Sel.Name) {
+						if x, ok := sel.X.(*ast.Ident); ok && x.Name == qualifier {
+							fields[fset.Position(sel.Sel.Pos())] = true
+						}
+					}
+				}
+				return true
+			})
+		}
+	}
+	uses := make(map[*File]map[int]bool)
+	for _, p := range pkgs {
+		byName := make(map[string]*File)
+		for i, f := range p.files {
+			byName[fset.Position(p.asts[i].Pos()).Filename] = f
+		}
+		for id, obj := range p.info.Uses {
+			v, ok := obj.(*types.Var)
+			if !ok || !v.IsField() || !v.Embedded() || !fields[fset.Position(v.Origin().Pos())] {
+				continue
+			}
+			pos := fset.Position(id.Pos())
+			if f := byName[pos.Filename]; f != nil {
+				if uses[f] == nil {
+					uses[f] = make(map[int]bool)
+				}
+				uses[f][pos.Offset] = true
+			}
+		}
+	}
+	return uses, nil
+}
+
+// renameTyped renames each identifier named from that is, or refers to, an
+// object that is reports true for, to to. Generated files are left alone,
+// except for mocks.
+func renameTyped(fset *token.FileSet, pkgs []*typedPkg, from, to string, is func(types.Object) bool) {
+	for _, p := range pkgs {
+		for i, f := range p.files {
+			if f.Generated() && !f.Mock() {
+				continue
+			}
+			var offsets []int
+			ast.Inspect(p.asts[i], func(n ast.Node) bool {
+				id, ok := n.(*ast.Ident)
+				if !ok || id.Name != from {
+					return true
+				}
+				obj := p.info.Defs[id]
+				if obj == nil {
+					obj = p.info.Uses[id]
+				}
+				if obj != nil && is(obj) {
+					offsets = append(offsets, fset.Position(id.Pos()).Offset)
+				}
+				return true
+			})
+			f.renameAt(offsets, from, to)
+		}
+	}
+}
+
+// renameAt renames the identifiers named from that begin at offsets in f's
+// source to to. The offsets come from type checking the same source in
+// another FileSet.
+func (f *File) renameAt(offsets []int, from, to string) {
+	if len(offsets) == 0 {
+		return
+	}
+	at := make(map[int]bool)
+	for _, o := range offsets {
+		at[o] = true
+	}
+	tf := f.fset.File(f.f.Pos())
+	ast.Inspect(f.f, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Ident); ok && id.Name == from && at[tf.Offset(id.Pos())] {
+			id.Name = to
+			f.renamed(from, to)
+			f.changed = true
+		}
+		return true
+	})
+}
+
+// typedConflict records that obj, a field or method of typ, can't be
+// renamed to to because of with, in the file among files that declares
+// obj. If there is none, it returns the clash as an error.
+func typedConflict(files []*File, fset *token.FileSet, obj, with types.Object, typ, to string) error {
+	pos, wpos := fset.Position(obj.Pos()), fset.Position(with.Pos())
+	var decl *File
+	for _, f := range files {
+		abs, err := filepath.Abs(f.Path)
+		if err != nil {
+			continue
+		}
+		if abs == wpos.Filename {
+			wpos.Filename = f.Path
+		}
+		if abs == pos.Filename {
+			pos.Filename = f.Path
+			decl = f
+		}
+	}
+	if decl == nil {
+		return fmt.Errorf("rename: %s.%s -> %s conflicts with %s at %s", typ, obj.Name(), to, with.Name(), wpos)
+	}
+	decl.conflicts = append(decl.conflicts, Conflict{
+		Old:  obj.Name(),
+		New:  to,
+		Pos:  pos.String(),
+		With: wpos.String(),
+	})
+	return nil
+}
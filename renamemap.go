@@ -0,0 +1,271 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"go/token"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/jeremyschlatter/gorename-global/rename"
+)
+
+// A renameRule is one rename from -e or -map. Kind and Package, from the
+// optional columns of a CSV or TSV -map, limit it to identifiers of that
+// kind, such as "type", declared in packages matching that pattern, such
+// as example.com/api/....
+type renameRule struct {
+	From, To      string
+	Kind, Package string
+}
+
+// The kinds a renameRule can be limited to.
+var ruleKinds = map[string]bool{"const": true, "var": true, "type": true, "func": true}
+
+// readRenameMap reads the renames in the -map file at path, or on standard
+// input if path is "-". Each line is an old name and a new one, separated
+// by spaces; blank lines and those starting with # are skipped. If the
+// first line that isn't skipped has a comma or a tab, the file is instead
+// CSV or TSV, whose columns are the old name, the new one, and optionally
+// a kind and a package pattern, or are named by a header row.
+func readRenameMap(path string) ([]renameRule, error) {
+	r := io.Reader(os.Stdin)
+	name := "standard input"
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r, name = f, path
+	}
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	sc := bufio.NewScanner(bytes.NewReader(b))
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		switch {
+		case strings.Contains(line, "\t"):
+			return readRuleTable(name, b, '\t')
+		case strings.Contains(line, ","):
+			return readRuleTable(name, b, ',')
+		}
+		break
+	}
+
+	var rs []renameRule
+	sc = bufio.NewScanner(bytes.NewReader(b))
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%s:%d: want an old name and a new one, got %q", name, n, line)
+		}
+		if !token.IsIdentifier(fields[0]) || !token.IsIdentifier(fields[1]) {
+			return nil, fmt.Errorf("%s:%d: both names must be identifiers", name, n)
+		}
+		rs = append(rs, renameRule{From: fields[0], To: fields[1]})
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	return rs, nil
+}
+
+// readRuleTable reads the renames in b, a CSV or TSV -map, as comma
+// separates its fields. A header row names the columns old (or from), new
+// (or to), kind, and package, in any order, and any others are ignored.
+// Without one, the columns are in that order and only the first two are
+// required.
+func readRuleTable(name string, b []byte, comma rune) ([]renameRule, error) {
+	cr := csv.NewReader(bytes.NewReader(b))
+	cr.Comma = comma
+	cr.Comment = '#'
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = comma == ','
+	cols := map[string]int{"old": 0, "new": 1, "kind": 2, "package": 3}
+	var rs []renameRule
+	for first := true; ; first = false {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			return rs, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		line, _ := cr.FieldPos(0)
+		if first && isRuleHeader(rec) {
+			cols = make(map[string]int)
+			for i, h := range rec {
+				h = strings.ToLower(strings.TrimSpace(h))
+				switch h {
+				case "from":
+					h = "old"
+				case "to":
+					h = "new"
+				}
+				cols[h] = i
+			}
+			if _, ok := cols["old"]; !ok {
+				return nil, fmt.Errorf("%s:%d: the header has no old column", name, line)
+			}
+			if _, ok := cols["new"]; !ok {
+				return nil, fmt.Errorf("%s:%d: the header has no new column", name, line)
+			}
+			continue
+		}
+		field := func(col string) string {
+			if i, ok := cols[col]; ok && i < len(rec) {
+				return strings.TrimSpace(rec[i])
+			}
+			return ""
+		}
+		r := renameRule{From: field("old"), To: field("new"), Kind: field("kind"), Package: field("package")}
+		if r.From == "" && r.To == "" {
+			continue
+		}
+		if !token.IsIdentifier(r.From) || !token.IsIdentifier(r.To) {
+			return nil, fmt.Errorf("%s:%d: both names must be identifiers", name, line)
+		}
+		if r.Kind != "" && !ruleKinds[r.Kind] {
+			return nil, fmt.Errorf("%s:%d: the kind is %q, but must be const, var, type, or func", name, line, r.Kind)
+		}
+		rs = append(rs, r)
+	}
+}
+
+// isRuleHeader reports whether rec, the first row of a CSV or TSV -map,
+// names the columns rather than giving a rename: whether it has a cell
+// that no rename could, or only the names of columns, in lower case.
+func isRuleHeader(rec []string) bool {
+	names := true
+	for i, h := range rec {
+		h = strings.TrimSpace(h)
+		switch h {
+		case "old", "new", "from", "to", "kind", "package":
+			continue
+		}
+		names = false
+		if i < 2 && h != "" && !token.IsIdentifier(h) {
+			return true
+		}
+	}
+	return names
+}
+
+// matchRules returns the Options.Match that renames each candidate as the
+// first of rules that applies to it says. An identifier's kind and package
+// are taken from the top-level declarations of files when the candidate
+// doesn't say: an unqualified name is looked for in its own package, and
+// pkg.Name in pkg. A rule limited to a kind or package is left out only for
+// identifiers known to be of another, so that the fields and methods that
+// share a renamed type's name, such as an embedded field, follow it.
+func matchRules(rules []renameRule, files []*rename.File) (func(rename.Candidate) (string, error), error) {
+	byName := make(map[string][]renameRule)
+	for _, r := range rules {
+		for _, o := range byName[r.From] {
+			if o.Kind == r.Kind && o.Package == r.Package && o.To != r.To {
+				return nil, fmt.Errorf("%s is renamed to both %s and %s", r.From, o.To, r.To)
+			}
+		}
+		byName[r.From] = append(byName[r.From], r)
+	}
+	patterns := make(map[string]*regexp.Regexp)
+	limited := false
+	for _, r := range rules {
+		if r.Package != "" && patterns[r.Package] == nil {
+			patterns[r.Package] = packagePattern(r.Package)
+		}
+		limited = limited || r.Kind != "" || r.Package != ""
+	}
+
+	// Match is called for several files at once, so the maps are filled
+	// in now and only read after.
+	importPaths := make(map[string]string)         // by directory
+	byDir := make(map[string]map[string]string)    // top-level kinds by name
+	byImport := make(map[string]map[string]string) // the same, by import path
+	for _, f := range files {
+		if !limited {
+			break
+		}
+		dir := filepath.Dir(f.Path)
+		if byDir[dir] == nil {
+			byDir[dir] = make(map[string]string)
+			importPaths[dir] = importPath(dir)
+			if p := importPaths[dir]; p != "" {
+				byImport[p] = byDir[dir]
+			}
+		}
+		for name, kind := range f.Declared() {
+			byDir[dir][name] = kind
+		}
+	}
+
+	return func(c rename.Candidate) (string, error) {
+		rs := byName[c.Name]
+		if len(rs) == 0 {
+			return c.Name, nil
+		}
+		// The kind and package stay "" if they can't be told, as for a
+		// field, and then any rule for the name applies.
+		dir := filepath.Dir(posFile(c.Pos))
+		kind, pkg := c.Kind, c.Qualifier
+		if pkg != "" {
+			if kind == "" {
+				kind = byImport[pkg][c.Name]
+			}
+		} else {
+			if kind == "" {
+				kind = byDir[dir][c.Name]
+			}
+			if kind != "" {
+				pkg = importPaths[dir]
+			}
+		}
+		for _, r := range rs {
+			if r.Kind != "" && kind != "" && kind != r.Kind {
+				continue
+			}
+			if r.Package != "" && pkg != "" && !patterns[r.Package].MatchString(pkg) {
+				continue
+			}
+			return r.To, nil
+		}
+		return c.Name, nil
+	}, nil
+}
+
+// posFile returns the file name of pos, a file:line:column position.
+func posFile(pos string) string {
+	for i := 0; i < 2; i++ {
+		if j := strings.LastIndex(pos, ":"); j >= 0 {
+			pos = pos[:j]
+		}
+	}
+	return pos
+}
+
+// packagePattern returns the regexp matching the import paths that the
+// package pattern p does, as the go command would: ... matches any
+// string, and a trailing /... also matches the path before it.
+func packagePattern(p string) *regexp.Regexp {
+	re := regexp.QuoteMeta(p)
+	re = strings.ReplaceAll(re, `\.\.\.`, `.*`)
+	if strings.HasSuffix(re, `/.*`) {
+		re = strings.TrimSuffix(re, `/.*`) + `(/.*)?`
+	}
+	return regexp.MustCompile(`^` + re + `$`)
+}
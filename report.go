@@ -0,0 +1,419 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/token"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/jeremyschlatter/gorename-global/rename"
+)
+
+// A fileResult says what happened to one file.
+type fileResult struct {
+	Path   string `json:"path"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// The statuses of a fileResult.
+const (
+	statusRenamed     = "renamed"
+	statusWouldRename = "would-rename" // with -check
+	statusCreated     = "created"      // by -compat
+	statusDeprecated  = "deprecated"   // by -deprecate-only
+	statusUnchanged   = "unchanged"
+	statusGenerated   = "skipped-generated"
+	statusRestored    = "restored"
+	statusRemoved     = "removed"    // created, then undone by a failed -verify
+	statusLoadErr     = "load-error" // of a package
+	statusReadErr     = "read-error"
+	statusParseErr    = "parse-error"
+	statusWriteErr    = "write-error"
+	statusModified    = "modified" // since it was read
+	statusInterrupted = "interrupted"
+	statusOutsideRoot = "outside-root" // with -stay-in-root
+	statusDeclined    = "declined"     // in the preview
+)
+
+func (r fileResult) failed() bool {
+	return r.Error != ""
+}
+
+var results = struct {
+	sync.Mutex
+	m map[string]fileResult
+}{
+	m: make(map[string]fileResult),
+}
+
+// record sets the result for the file at path, replacing any earlier one.
+func record(path, status string, err error) {
+	r := fileResult{Path: path, Status: status}
+	if err != nil {
+		r.Error = err.Error()
+	}
+	results.Lock()
+	results.m[path] = r
+	results.Unlock()
+	if r.failed() {
+		logger.Error("failed", "path", path, "status", status, "error", r.Error)
+		if failFast != nil && status != statusInterrupted {
+			failFast(errFailFast)
+		}
+	} else {
+		logger.Debug(status, "path", path)
+	}
+}
+
+// A summary is the document printed by -report=json.
+type summary struct {
+	Changed   []pair               `json:"changed"`
+	Conflicts []rename.Conflict    `json:"conflicts,omitempty"`
+	Messages  []rename.MessageEdit `json:"messages,omitempty"`
+	Branches  []branchConflict     `json:"branch_conflicts,omitempty"`
+	Mentions  []rename.Mention     `json:"comment_mentions,omitempty"`
+	Packages  []pkgChange          `json:"packages,omitempty"` // with -summary=package
+	Partial   bool                 `json:"partial,omitempty"`  // some files or packages failed
+	Files     []fileResult         `json:"files"`
+
+	// The identifiers left alone by -min-confidence.
+	Uncertain []rename.LowConfidence `json:"low_confidence,omitempty"`
+
+	// The identifiers that may be from dot-imported packages.
+	DotImports []rename.DotImportUse `json:"dot_imports,omitempty"`
+
+	// The identifiers renamed as selectors on values, with -warn-selectors.
+	Selectors []rename.SelectorUse `json:"selectors,omitempty"`
+
+	// The identifiers that would be renamed, for the list command.
+	Sites []rename.Change `json:"sites,omitempty"`
+
+	// The package directories moved by -rename-dirs.
+	MovedDirs []dirMove `json:"moved_dirs,omitempty"`
+}
+
+var (
+	conflicts    []rename.Conflict    // found by -auto
+	messageEdits []rename.MessageEdit // made by -messages
+	mentions     []rename.Mention     // found by findMentions
+)
+
+// uncertain holds the identifiers left alone by -min-confidence.
+var uncertain []rename.LowConfidence
+
+// dotImportUses holds the identifiers that may be from dot-imported
+// packages.
+var dotImportUses []rename.DotImportUse
+
+// selectorUses holds the identifiers listed by -warn-selectors.
+var selectorUses []rename.SelectorUse
+
+// findMentions collects the comments in files that still name identifiers
+// by the names they were renamed from, for the summary. Generated files
+// are skipped unless -include-generated is set, since nobody reads them.
+func findMentions(files []*rename.File) {
+	renames := make(map[string]string)
+	for k := range changeLog.m {
+		renames[k[0]] = k[1]
+	}
+	if len(renames) == 0 {
+		return
+	}
+	for _, f := range files {
+		if !*generated && f.Generated() {
+			continue
+		}
+		mentions = append(mentions, f.Mentions(renames)...)
+	}
+}
+
+// A pair is a rename, with how many identifiers it changed in how many
+// files.
+type pair struct {
+	From        string `json:"from"`
+	To          string `json:"to"`
+	Occurrences int    `json:"occurrences,omitempty"`
+	Files       int    `json:"files,omitempty"`
+}
+
+// A pkgChange is how much of one package a run changed.
+type pkgChange struct {
+	Dir         string `json:"dir"`
+	ImportPath  string `json:"import_path,omitempty"`
+	Files       int    `json:"files"`
+	Occurrences int    `json:"occurrences"`
+}
+
+// pkgLog holds the changes by directory, for -summary=package.
+var pkgLog = struct {
+	sync.Mutex
+	m map[string]pkgChange
+}{
+	m: make(map[string]pkgChange),
+}
+
+// logPackage adds f, which was, or would have been, renamed, to pkgLog.
+func logPackage(f *rename.File) {
+	n := 0
+	for _, c := range f.Occurrences() {
+		n += c
+	}
+	dir := filepath.Dir(f.Path)
+	pkgLog.Lock()
+	defer pkgLog.Unlock()
+	p, ok := pkgLog.m[dir]
+	if !ok {
+		p = pkgChange{Dir: dir, ImportPath: importPath(dir)}
+	}
+	p.Files++
+	p.Occurrences += n
+	pkgLog.m[dir] = p
+}
+
+// posLess reports whether the position a, as file:line:column, comes before
+// b: by file name, then by line and column as numbers, so that line 9 comes
+// before line 10.
+func posLess(a, b string) bool {
+	pa, pb := parsePos(a), parsePos(b)
+	if pa.Filename != pb.Filename {
+		return pa.Filename < pb.Filename
+	}
+	if pa.Line != pb.Line {
+		return pa.Line < pb.Line
+	}
+	return pa.Column < pb.Column
+}
+
+// parsePos parses a position printed by token.Position.String. The file name
+// may itself contain colons, so the line and column are taken from the end.
+func parsePos(s string) token.Position {
+	var p token.Position
+	p.Filename = s
+	for _, n := range []*int{&p.Column, &p.Line} {
+		i := strings.LastIndexByte(p.Filename, ':')
+		if i < 0 {
+			break
+		}
+		v, err := strconv.Atoi(p.Filename[i+1:])
+		if err != nil {
+			break
+		}
+		*n, p.Filename = v, p.Filename[:i]
+	}
+	if p.Line == 0 { // file:line, with no column
+		p.Line, p.Column = p.Column, 0
+	}
+	return p
+}
+
+// printReport prints the summary of the run in the given format, and
+// reports whether any file failed and whether any was, or would have been,
+// renamed.
+func printReport(format string) (failed, changed bool) {
+	var s summary
+	for _, p := range changeLog.m {
+		s.Changed = append(s.Changed, p)
+	}
+	sort.Slice(s.Changed, func(i, j int) bool {
+		a, b := s.Changed[i], s.Changed[j]
+		return a.From < b.From || a.From == b.From && a.To < b.To
+	})
+	for _, r := range results.m {
+		s.Files = append(s.Files, r)
+		switch {
+		case r.failed():
+			failed = true
+		case r.Status == statusRenamed, r.Status == statusWouldRename, r.Status == statusCreated, r.Status == statusDeprecated:
+			changed = true
+		}
+	}
+	sort.Slice(s.Files, func(i, j int) bool { return s.Files[i].Path < s.Files[j].Path })
+	s.Partial = failed
+	s.Conflicts = conflicts
+	sort.Slice(s.Conflicts, func(i, j int) bool { return posLess(s.Conflicts[i].Pos, s.Conflicts[j].Pos) })
+	s.Branches = branchConflicts
+	s.Messages = messageEdits
+	s.Mentions = mentions
+	s.Uncertain = uncertain
+	sort.Slice(s.Uncertain, func(i, j int) bool { return posLess(s.Uncertain[i].Pos, s.Uncertain[j].Pos) })
+	s.DotImports = dotImportUses
+	sort.Slice(s.DotImports, func(i, j int) bool { return posLess(s.DotImports[i].Pos, s.DotImports[j].Pos) })
+	s.Selectors = selectorUses
+	sort.Slice(s.Selectors, func(i, j int) bool { return posLess(s.Selectors[i].Pos, s.Selectors[j].Pos) })
+	sort.Slice(s.Mentions, func(i, j int) bool { return posLess(s.Mentions[i].Pos, s.Mentions[j].Pos) })
+	for _, p := range pkgLog.m {
+		s.Packages = append(s.Packages, p)
+	}
+	sort.Slice(s.Packages, func(i, j int) bool { return s.Packages[i].Dir < s.Packages[j].Dir })
+	sort.Slice(s.Messages, func(i, j int) bool { return posLess(s.Messages[i].Pos, s.Messages[j].Pos) })
+	s.Sites = sites.s
+	s.MovedDirs = movedDirs
+	sort.Slice(s.Sites, func(i, j int) bool { return posLess(s.Sites[i].Pos, s.Sites[j].Pos) })
+
+	switch format {
+	case "gh-suggestions":
+		printSuggestions()
+		return failed, changed
+	case "workspace-edit":
+		printWorkspaceEdit()
+		return failed, changed
+	case "idea-patch":
+		printIDEAPatch(os.Stdout, s.Changed)
+		return failed, changed
+	case "json-patch":
+		printJSONPatches()
+		return failed, changed
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "\t")
+		enc.Encode(s)
+		return failed, changed
+	}
+	if listing {
+		printSites(s.Sites)
+		return failed, changed
+	}
+
+	if len(s.Changed) > 0 {
+		fmt.Println(paint(outTheme.heading, "Changed:"))
+		for _, p := range s.Changed {
+			fmt.Printf("\t%s -> %s (%s in %s)\n", paint(outTheme.removed, p.From), paint(outTheme.added, p.To), plural(p.Occurrences, "occurrence"), plural(p.Files, "file"))
+		}
+	}
+	if len(s.Packages) > 0 {
+		fmt.Println(paint(outTheme.heading, "By package:"))
+		for _, p := range s.Packages {
+			name := p.ImportPath
+			if name == "" {
+				name = p.Dir
+			}
+			fmt.Printf("\t%s: %s in %s\n", name, plural(p.Occurrences, "occurrence"), plural(p.Files, "file"))
+		}
+	}
+	if len(s.Conflicts) > 0 {
+		fmt.Println(paint(outTheme.heading, "Not renamed, because the new name is taken:"))
+		for _, c := range s.Conflicts {
+			fmt.Printf("\t%s: %s -> %s conflicts with %s\n", c.Pos, c.Old, c.New, c.With)
+		}
+	}
+	if len(s.Uncertain) > 0 {
+		fmt.Println(paint(outTheme.heading, "Left alone, for review, since a rename by name alone is unsure of them:"))
+		for _, u := range s.Uncertain {
+			fmt.Printf("\t%s: %s -> %s (confidence %.2f)\n", u.Pos, u.Old, u.New, u.Confidence)
+		}
+	}
+	if len(s.DotImports) > 0 {
+		fmt.Println(paint(outTheme.heading, "In files with dot imports, so they may be from the packages dot-imported; check them, or pass -resolve-dot-imports:"))
+		for _, u := range s.DotImports {
+			done := "left alone"
+			if u.Renamed {
+				done = "renamed"
+			}
+			fmt.Printf("\t%s: %s, %s; may be from %s\n", u.Pos, u.Name, done, strings.Join(u.Imports, ", "))
+		}
+	}
+	if len(s.Selectors) > 0 {
+		fmt.Println(paint(outTheme.heading, "Renamed as fields or methods of values whose type isn't known, so they may be another type's:"))
+		for _, u := range s.Selectors {
+			fmt.Printf("\t%s: %s.%s -> %s\n", u.Pos, u.Qualifier, u.Old, u.New)
+		}
+	}
+	if len(s.Messages) > 0 {
+		fmt.Println(paint(outTheme.heading, "Changed messages:"))
+		for _, e := range s.Messages {
+			fmt.Printf("\t%s: %s -> %s\n", e.Pos, e.Old, e.New)
+		}
+	}
+	if len(s.Mentions) > 0 {
+		fmt.Println(paint(outTheme.heading, "Comments that still use the old names:"))
+		for _, c := range s.Mentions {
+			fmt.Printf("\t%s: %s -> %s: %s\n", c.Pos, c.Old, c.New, c.Text)
+		}
+	}
+	if len(s.Branches) > 0 {
+		fmt.Println(paint(outTheme.heading, "Also changed on other branches, so merges will likely conflict:"))
+		for _, c := range s.Branches {
+			fmt.Printf("\t%s: %s\n", c.Branch, c.Path)
+		}
+	}
+	var skipped, created, declined, failures []fileResult
+	renamed, wouldRename, deprecated, files := 0, 0, 0, 0
+	for _, r := range s.Files {
+		if r.Status != statusCreated && r.Status != statusRemoved && r.Status != statusLoadErr {
+			files++
+		}
+		switch {
+		case r.failed():
+			failures = append(failures, r) // logged by record too, as it happened
+		case r.Status == statusGenerated:
+			skipped = append(skipped, r)
+		case r.Status == statusCreated:
+			created = append(created, r)
+		case r.Status == statusDeclined:
+			declined = append(declined, r)
+		case r.Status == statusRenamed:
+			renamed++
+		case r.Status == statusWouldRename:
+			wouldRename++
+		case r.Status == statusDeprecated:
+			deprecated++
+		}
+	}
+	if renamed > 0 {
+		fmt.Printf("Renamed in %d of %d files.\n", renamed, files)
+	}
+	if wouldRename > 0 {
+		fmt.Printf("Would rename in %d of %d files.\n", wouldRename, files)
+	}
+	if deprecated > 0 {
+		fmt.Printf("Marked deprecated in %d of %d files.\n", deprecated, files)
+	}
+	if len(s.MovedDirs) > 0 {
+		fmt.Println(paint(outTheme.heading, "Moved:"))
+		for _, d := range s.MovedDirs {
+			fmt.Printf("\t%s -> %s\n", d.From, d.To)
+		}
+	}
+	if len(created) > 0 {
+		fmt.Println(paint(outTheme.heading, "Created:"))
+		for _, r := range created {
+			fmt.Printf("\t%s\n", r.Path)
+		}
+	}
+	if len(declined) > 0 {
+		fmt.Println(paint(outTheme.heading, "Left alone in the preview:"))
+		for _, r := range declined {
+			fmt.Printf("\t%s\n", r.Path)
+		}
+	}
+	if len(skipped) > 0 {
+		fmt.Println(paint(outTheme.heading, "Skipped generated files:"))
+		for _, r := range skipped {
+			fmt.Printf("\t%s\n", r.Path)
+		}
+	}
+	if len(failures) > 0 {
+		fmt.Fprintln(os.Stderr, paint(errTheme.heading, "Failed:"))
+		for _, r := range failures {
+			fmt.Fprintf(os.Stderr, "\t%s: %s: %s\n", r.Path, r.Status, r.Error)
+		}
+	}
+	if failed {
+		fmt.Println(paint(outTheme.failure, "Partial: some packages or files failed, as listed, and were left as they were."))
+	}
+	return failed, changed
+}
+
+// plural returns n and noun, made plural unless n is 1.
+func plural(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
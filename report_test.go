@@ -0,0 +1,30 @@
+package main
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestPosLess(t *testing.T) {
+	got := []string{
+		"b.go:1:1",
+		"a.go:10:2",
+		"a.go:9:12",
+		"a.go:9:2",
+		"a.go:10",
+		"dir:x/a.go:2:1",
+	}
+	sort.Slice(got, func(i, j int) bool { return posLess(got[i], got[j]) })
+	want := []string{
+		"a.go:9:2",
+		"a.go:9:12",
+		"a.go:10",
+		"a.go:10:2",
+		"b.go:1:1",
+		"dir:x/a.go:2:1",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("sorted:\n%q\nwant:\n%q", got, want)
+	}
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/jeremyschlatter/gorename-global/rename"
+)
+
+// reviewing is set by the review command, which lets the user pick the
+// hunks to write in a full-screen terminal view.
+var reviewing bool
+
+// A reviewRow is a line of the review screen. Rows for a file or for the
+// first line of one of its hunks can be toggled; the rest only show more
+// of the hunk above them.
+type reviewRow struct {
+	file, hunk int // hunk is -1 for the file's own row
+	text       string
+	toggle     bool
+	sgr        string // its color, from ttyTheme
+}
+
+// review shows the hunks of the changed files on the terminal, lets the
+// user toggle them, and returns the files and hunks chosen when the user
+// applies them. Quitting with ^C chooses none.
+//
+// Keys: up and down, or k and j, move; space toggles the hunk or file under
+// the cursor; a toggles everything; enter or q applies the choices.
+func review(files []*rename.File) map[*rename.File][]bool {
+	var (
+		changed []*rename.File
+		hunks   [][]hunk
+		keep    [][]bool
+	)
+	for _, f := range files {
+		if f.Broken() || !f.Changed() || !*generated && f.Generated() && !f.Mock() {
+			continue
+		}
+		src, err := render(f)
+		if err != nil {
+			record(f.Path, statusWriteErr, err)
+			continue
+		}
+		hs := lineHunks(f.Original(), src)
+		ks := make([]bool, len(hs))
+		for i := range ks {
+			ks[i] = true
+		}
+		changed = append(changed, f)
+		hunks = append(hunks, hs)
+		keep = append(keep, ks)
+	}
+	chosen := make(map[*rename.File][]bool)
+	if len(changed) == 0 {
+		return chosen
+	}
+
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		exitOnErr([]error{fmt.Errorf("review needs a terminal: %v", err)})
+	}
+	defer tty.Close()
+	saved, err := stty(tty, "-g")
+	if err != nil {
+		exitOnErr([]error{fmt.Errorf("review needs a terminal: %v", err)})
+	}
+	stty(tty, "raw", "-echo")
+	defer stty(tty, strings.TrimSpace(saved))
+	fmt.Fprint(tty, "\x1b[?1049h\x1b[?25l") // alternate screen, no cursor
+	defer fmt.Fprint(tty, "\x1b[?25h\x1b[?1049l")
+
+	rows := reviewRows(changed, hunks)
+	height, width := terminalSize(tty)
+	top := 0
+	draw := func(cursor int) {
+		if cursor < top {
+			top = cursor
+		}
+		if cursor >= top+height-1 {
+			top = cursor - height + 2
+		}
+		var b strings.Builder
+		b.WriteString("\x1b[H\x1b[2J")
+		for n, r := range rows[top:] {
+			if n == height-1 {
+				break
+			}
+			line := r.line(keep)
+			if len(line) > width {
+				line = line[:width]
+			}
+			if top+n == cursor {
+				line = "\x1b[7m" + line + "\x1b[0m"
+			} else {
+				line = paint(r.sgr, line)
+			}
+			b.WriteString(line + "\r\n")
+		}
+		b.WriteString("\x1b[7m space: toggle  a: all  enter/q: apply  ^C: quit without writing \x1b[0m")
+		fmt.Fprint(tty, b.String())
+	}
+	if !reviewKeys(bufio.NewReader(tty), rows, keep, draw) {
+		return chosen
+	}
+	for i, f := range changed {
+		if anyTrue(keep[i]) {
+			chosen[f] = keep[i]
+		}
+	}
+	return chosen
+}
+
+// line returns the text of r on the screen, marked with whether it is
+// chosen, as keep says, if it can be toggled.
+func (r reviewRow) line(keep [][]bool) string {
+	mark := "   "
+	if r.toggle {
+		on := keep[r.file][max(r.hunk, 0)]
+		if r.hunk < 0 {
+			on = anyTrue(keep[r.file])
+		}
+		mark = "[ ]"
+		if on {
+			mark = "[x]"
+		}
+	}
+	line := mark + " " + r.text
+	if r.hunk >= 0 {
+		line = "  " + line
+	}
+	return line
+}
+
+// reviewRows returns the rows of the review screen for the changed files,
+// with the hunks of each.
+func reviewRows(changed []*rename.File, hunks [][]hunk) []reviewRow {
+	var rows []reviewRow
+	for i, f := range changed {
+		rows = append(rows, reviewRow{file: i, hunk: -1, text: f.Path, toggle: true, sgr: ttyTheme.heading})
+		for j, h := range hunks[i] {
+			for k, l := range h.old {
+				rows = append(rows, reviewRow{file: i, hunk: j, text: fmt.Sprintf("%5d - %s", h.start+k+1, strings.TrimRight(l, "\r\n")), toggle: k == 0, sgr: ttyTheme.removed})
+			}
+			for _, l := range h.new {
+				rows = append(rows, reviewRow{file: i, hunk: j, text: "      + " + strings.TrimRight(l, "\r\n"), toggle: len(h.old) == 0, sgr: ttyTheme.added})
+			}
+		}
+	}
+	return rows
+}
+
+// reviewKeys reads keys from in, drawing the screen with the cursor on a
+// row before each, and toggles the hunks of keep, by file, as they say. It
+// reports whether the user applied the choices, rather than quitting.
+func reviewKeys(in *bufio.Reader, rows []reviewRow, keep [][]bool, draw func(cursor int)) bool {
+	cursor := 0
+	for {
+		draw(cursor)
+		c, err := in.ReadByte()
+		if err != nil || c == 3 { // ^C
+			return false
+		}
+		switch c {
+		case '\r', '\n', 'q':
+			return true
+		case 'j':
+			cursor = nextToggle(rows, cursor, 1)
+		case 'k':
+			cursor = nextToggle(rows, cursor, -1)
+		case 0x1b: // arrow keys are ESC [ A and ESC [ B
+			if b, _ := in.ReadByte(); b == '[' {
+				switch b, _ := in.ReadByte(); b {
+				case 'A':
+					cursor = nextToggle(rows, cursor, -1)
+				case 'B':
+					cursor = nextToggle(rows, cursor, 1)
+				}
+			}
+		case ' ':
+			r := rows[cursor]
+			if r.hunk >= 0 {
+				keep[r.file][r.hunk] = !keep[r.file][r.hunk]
+				break
+			}
+			on := !anyTrue(keep[r.file])
+			for j := range keep[r.file] {
+				keep[r.file][j] = on
+			}
+		case 'a':
+			on := false
+			for _, ks := range keep {
+				if !allTrue(ks) {
+					on = true
+				}
+			}
+			for _, ks := range keep {
+				for j := range ks {
+					ks[j] = on
+				}
+			}
+		}
+	}
+}
+
+// nextToggle returns the index of the next row after i, in direction dir,
+// that can be toggled, or i if there is none.
+func nextToggle(rows []reviewRow, i, dir int) int {
+	for j := i + dir; j >= 0 && j < len(rows); j += dir {
+		if rows[j].toggle {
+			return j
+		}
+	}
+	return i
+}
+
+func anyTrue(bs []bool) bool {
+	for _, b := range bs {
+		if b {
+			return true
+		}
+	}
+	return false
+}
+
+func allTrue(bs []bool) bool {
+	for _, b := range bs {
+		if !b {
+			return false
+		}
+	}
+	return true
+}
+
+// stty runs stty with args on tty and returns its output.
+func stty(tty *os.File, args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = tty
+	out, err := cmd.Output()
+	return string(out), err
+}
+
+// terminalSize returns the rows and columns of tty, or 24 by 80 if stty
+// can't say.
+func terminalSize(tty *os.File) (rows, cols int) {
+	out, err := stty(tty, "size")
+	if err != nil {
+		return 24, 80
+	}
+	if _, err := fmt.Sscan(out, &rows, &cols); err != nil || rows < 2 || cols < 1 {
+		return 24, 80
+	}
+	return rows, cols
+}
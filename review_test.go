@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/jeremyschlatter/gorename-global/rename"
+)
+
+var reviewTests = []struct {
+	name     string
+	old, new string
+	keys     string // typed on the review screen
+}{
+	{
+		// Runs of changed lines are hunks of their own when the number of
+		// lines stays the same; the last two are left out.
+		name: "split",
+		old:  "package p\n\nfunc Old() {}\n\nfunc f() { Old() }\n\nvar _ = Old\nvar x = Old\n",
+		new:  "package p\n\nfunc New() {}\n\nfunc f() { New() }\n\nvar _ = New\nvar x = New\n",
+		keys: "jj j \r",
+	},
+	{
+		// When it changes, everything between the first and the last
+		// difference is one hunk.
+		name: "grow",
+		old:  "package p\n\nimport \"example.com/old\"\n\nvar _ = old.X\n",
+		new:  "package p\n\nimport (\n\t\"example.com/new\"\n)\n\nvar _ = new.X\n",
+		keys: "\r",
+	},
+	{
+		// Arrow keys move too; toggling the file's row leaves all of its
+		// hunks out, and a puts them all back but for the last.
+		name: "file",
+		old:  "package p\n\nvar a = Old\n\nvar b = Old\n\nvar c = Old\n",
+		new:  "package p\n\nvar a = New\n\nvar b = New\n\nvar c = New\n",
+		keys: " a\x1b[B\x1b[B\x1b[B\x1b[A\x1b[B \r",
+	},
+	{
+		name: "quit",
+		old:  "package p\n\nvar a = Old\n",
+		new:  "package p\n\nvar a = New\n",
+		keys: " \x03",
+	},
+}
+
+func TestReview(t *testing.T) {
+	for _, tt := range reviewTests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := rename.ParseFile("p.go", []byte(tt.old))
+			if err != nil {
+				t.Fatal(err)
+			}
+			hs := lineHunks([]byte(tt.old), []byte(tt.new))
+			keep := [][]bool{make([]bool, len(hs))}
+			for i := range keep[0] {
+				keep[0][i] = true
+			}
+			rows := reviewRows([]*rename.File{f}, [][]hunk{hs})
+			var screen strings.Builder
+			applied := reviewKeys(bufio.NewReader(strings.NewReader(tt.keys)), rows, keep, func(cursor int) {
+				screen.Reset()
+				for i, r := range rows {
+					at := "  "
+					if i == cursor {
+						at = "> "
+					}
+					screen.WriteString(at + r.line(keep) + "\n")
+				}
+			})
+			var b strings.Builder
+			fmt.Fprintf(&b, "keys: %q\n\n%s\napplied: %v\n", tt.keys, screen.String(), applied)
+			if applied {
+				fmt.Fprintf(&b, "\n%s", applyHunks([]byte(tt.old), []byte(tt.new), keep[0]))
+			}
+			checkGolden(t, "review/"+tt.name, []byte(b.String()))
+		})
+	}
+}
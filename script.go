@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// A script, written by -record and read by -play, is a line per flag, as
+// -name=value, followed by a line per argument. Blank lines and lines
+// starting with # are ignored.
+const scriptHeader = "# gorename-global script: replay with -play\n"
+
+// recordScript writes the flags set on the command line, other than
+// -record and -play themselves, and args to path.
+func recordScript(path string, args []string) error {
+	var buf bytes.Buffer
+	buf.WriteString(scriptHeader)
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name != "record" && f.Name != "play" {
+			fmt.Fprintf(&buf, "-%s=%s\n", f.Name, f.Value)
+		}
+	})
+	for _, arg := range args {
+		fmt.Fprintln(&buf, arg)
+	}
+	return fsys.WriteFile(path, buf.Bytes(), 0666)
+}
+
+// playScript applies the flags in the script at path, except those set on
+// the command line, which win. It returns args, or the script's arguments
+// if args is empty.
+func playScript(path string, args []string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	set := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	var scriptArgs []string
+	sc := bufio.NewScanner(file)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		switch {
+		case line == "", strings.HasPrefix(line, "#"):
+		case strings.HasPrefix(line, "-"):
+			name, value, ok := strings.Cut(strings.TrimLeft(line, "-"), "=")
+			if !ok {
+				value = "true"
+			}
+			if name == "record" || name == "play" || set[name] {
+				continue
+			}
+			if err := flag.Set(name, value); err != nil {
+				return nil, fmt.Errorf("%s:%d: %v", path, n, err)
+			}
+		default:
+			scriptArgs = append(scriptArgs, line)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if len(args) == 0 {
+		args = scriptArgs
+	}
+	return args, nil
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/jeremyschlatter/gorename-global/rename"
+)
+
+var (
+	goModModule = regexp.MustCompile(`(?m)^module\s+"?([^"\s]+)"?`)
+	semverTag   = regexp.MustCompile(`^v(\d+)\.(\d+)\.(\d+)(-[0-9A-Za-z.-]+)?(\+[0-9A-Za-z.-]+)?$`)
+	majorSuffix = regexp.MustCompile(`/v[0-9]+$`)
+)
+
+// A version is a semantic version from a release tag.
+type version struct {
+	major, minor, patch int
+	pre                 string // the prerelease, such as "-rc.1", if any
+}
+
+func (v version) String() string {
+	return fmt.Sprintf("v%d.%d.%d%s", v.major, v.minor, v.patch, v.pre)
+}
+
+func (v version) less(w version) bool {
+	switch {
+	case v.major != w.major:
+		return v.major < w.major
+	case v.minor != w.minor:
+		return v.minor < w.minor
+	case v.patch != w.patch:
+		return v.patch < w.patch
+	case v.pre == "" || w.pre == "":
+		return v.pre != "" && w.pre == "" // a prerelease comes first
+	}
+	return v.pre < w.pre
+}
+
+// checkSemver stops the run, unless -breaking is set, if files rename
+// exported declarations of the module in the current directory and the
+// module has been released at v1 or later, as found from its git tags,
+// since then the rename breaks its importers and needs a new major
+// version. With -check, or for a module still at v0, it only warns. With
+// -compat, the old names keep working, so there is nothing to check.
+func checkSemver(files []*rename.File) {
+	if *breaking || *compat {
+		return
+	}
+	root, err := moduleRoot()
+	if err != nil {
+		return
+	}
+	renamed := false
+	for _, f := range files {
+		if len(f.APIRenames()) == 0 {
+			continue
+		}
+		if dir, err := filepath.EvalSymlinks(filepath.Dir(f.Path)); err == nil {
+			if _, ok := repoPath(root, dir); ok {
+				renamed = true
+				break
+			}
+		}
+	}
+	if !renamed {
+		return
+	}
+	mod, err := os.ReadFile(filepath.Join(root, "go.mod"))
+	if err != nil {
+		return
+	}
+	m := goModModule.FindSubmatch(mod)
+	if m == nil {
+		return
+	}
+	modPath := string(m[1])
+	latest, ok := latestRelease(root)
+	if !ok {
+		return
+	}
+	if latest.major == 0 {
+		logger.Warn("renaming exported identifiers breaks the API; under v1, release the change as a new minor version",
+			"module", modPath, "latest", latest.String(), "next", version{0, latest.minor + 1, 0, ""}.String())
+		return
+	}
+	next := version{latest.major + 1, 0, 0, ""}
+	nextPath := majorSuffix.ReplaceAllString(modPath, "") + "/v" + strconv.Itoa(next.major)
+	err = fmt.Errorf("renaming exported identifiers breaks the API of %s, whose latest release is %s; "+
+		"release the change as %s, with module path %s, or keep the old names working with -compat, and pass -breaking to go ahead",
+		modPath, latest, next, nextPath)
+	if *check {
+		logger.Warn(err.Error())
+		return
+	}
+	exitOnErr([]error{err})
+}
+
+// releasedV1 reports whether the module in the current directory has been
+// released at v1 or later, so that checkSemver stops a run that renames its
+// exported declarations.
+func releasedV1() bool {
+	root, err := moduleRoot()
+	if err != nil {
+		return false
+	}
+	if _, err := os.Stat(filepath.Join(root, "go.mod")); err != nil {
+		return false
+	}
+	latest, ok := latestRelease(root)
+	return ok && latest.major >= 1
+}
+
+// latestRelease returns the highest semantic version the git tags of the
+// module at root give it, preferring releases to prereleases. The tags of a module in a subdirectory of the
+// repository start with the subdirectory, as in sub/v1.2.3.
+func latestRelease(root string) (version, bool) {
+	out, err := exec.Command("git", "-C", root, "rev-parse", "--show-toplevel").Output()
+	if err != nil {
+		return version{}, false
+	}
+	prefix := ""
+	if top, err := filepath.EvalSymlinks(strings.TrimSpace(string(out))); err == nil {
+		if rel, ok := repoPath(top, root); ok && rel != "." {
+			prefix = filepath.ToSlash(rel) + "/"
+		}
+	}
+	out, err = exec.Command("git", "-C", root, "tag", "--list", prefix+"v*").Output()
+	if err != nil {
+		return version{}, false
+	}
+	// Prereleases count only if there is nothing else, as for the go
+	// command's idea of the latest version.
+	var latest, latestPre version
+	found, foundPre := false, false
+	for _, tag := range strings.Fields(string(out)) {
+		m := semverTag.FindStringSubmatch(strings.TrimPrefix(tag, prefix))
+		if m == nil {
+			continue
+		}
+		var v version
+		v.major, _ = strconv.Atoi(m[1])
+		v.minor, _ = strconv.Atoi(m[2])
+		v.patch, _ = strconv.Atoi(m[3])
+		v.pre = m[4]
+		switch {
+		case v.pre != "":
+			if !foundPre || latestPre.less(v) {
+				latestPre, foundPre = v, true
+			}
+		case !found || latest.less(v):
+			latest, found = v, true
+		}
+	}
+	if !found {
+		return latestPre, foundPre
+	}
+	return latest, true
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io/fs"
+	"sync"
+	"time"
+)
+
+// A stamp is what a file was like when it was read, so that it isn't
+// overwritten if it has changed since, as an editor's autosave might
+// change it while a -preview waits to be approved.
+type stamp struct {
+	size  int64
+	mtime time.Time
+	sum   [sha256.Size]byte
+}
+
+var stamps = struct {
+	sync.Mutex
+	m map[string]stamp
+}{
+	m: make(map[string]stamp),
+}
+
+// errModified is returned by checkUnchanged for a file that has changed.
+var errModified = errors.New("changed since it was read")
+
+// stampFile records the stamp of the file at path, with the FileInfo fi
+// from before it was read and its contents src. Nothing is recorded
+// without fi.
+func stampFile(path string, fi fs.FileInfo, src []byte) {
+	if fi == nil {
+		return
+	}
+	stamps.Lock()
+	stamps.m[path] = stamp{fi.Size(), fi.ModTime(), sha256.Sum256(src)}
+	stamps.Unlock()
+}
+
+// checkUnchanged returns an error wrapping errModified if the file at path
+// isn't as it was when stampFile recorded it. A file whose size and
+// modification time are the same is taken to be; one that was only
+// touched, or changed and changed back, is hashed to find that it is.
+func checkUnchanged(path string) error {
+	stamps.Lock()
+	s, ok := stamps.m[path]
+	stamps.Unlock()
+	if !ok {
+		return nil
+	}
+	fi, err := fs.Stat(fsys, path)
+	if err != nil {
+		return err
+	}
+	if fi.Size() == s.size && fi.ModTime().Equal(s.mtime) {
+		return nil
+	}
+	src, err := fs.ReadFile(fsys, path)
+	if err != nil {
+		return err
+	}
+	if sha256.Sum256(src) != s.sum {
+		return fmt.Errorf("%w, so it was left alone; run again to rename it as it is now", errModified)
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jeremyschlatter/gorename-global/rename"
+)
+
+// readRenamed reads the file at path, stamping it as a run does, and renames
+// Old to New in it.
+func readRenamed(t *testing.T, path string) *rename.File {
+	t.Helper()
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stampFile(path, fi, src)
+	f, err := rename.ParseFile(path, src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rename.Rename([]*rename.File{f}, rename.Options{From: "Old", To: "New"}); err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func TestWriteModified(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a.go")
+	if err := os.WriteFile(path, []byte("package a\n\nfunc Old() {}\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	f := readRenamed(t, path)
+	edited := "package a\n\nfunc Old() {}\n\nfunc Other() {}\n"
+	if err := os.WriteFile(path, []byte(edited), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if wrote, err := write(f, nil); wrote || !errors.Is(err, errModified) {
+		t.Errorf("write = %v, %v; want false, %v", wrote, err, errModified)
+	}
+	if got, _ := os.ReadFile(path); string(got) != edited {
+		t.Errorf("%s was overwritten:\n%s\nwant:\n%s", path, got, edited)
+	}
+}
+
+func TestWriteTouched(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a.go")
+	if err := os.WriteFile(path, []byte("package a\n\nfunc Old() {}\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	f := readRenamed(t, path)
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if wrote, err := write(f, nil); !wrote || err != nil {
+		t.Fatalf("write = %v, %v; want true, nil", wrote, err)
+	}
+	want := "package a\n\nfunc New() {}\n"
+	if got, _ := os.ReadFile(path); string(got) != want {
+		t.Errorf("%s:\n%s\nwant:\n%s", path, got, want)
+	}
+}
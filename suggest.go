@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/jeremyschlatter/gorename-global/rename"
+)
+
+// suggestMain runs the suggest command, which prints renames of the poorly
+// named identifiers in the packages named by args as a -map file.
+func suggestMain(args []string) {
+	setupColor(*colorMode)
+	setupLog(*logFormat, logLevel)
+	checkOutputFlags()
+	setupPool(*jobs, *maxMemory)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	ctx = withErrorPolicy(ctx)
+	files := load(ctx, args)
+	ss := rename.Suggest(files, hungarianPrefixes())
+	printNameSuggestions(os.Stdout, ss)
+	results.Lock()
+	defer results.Unlock()
+	for _, r := range results.m {
+		if r.failed() {
+			os.Exit(exitFailed)
+		}
+	}
+	if len(ss) > 0 {
+		os.Exit(exitChanged)
+	}
+}
+
+// printNameSuggestions prints ss to w as a -map file: a comment giving the
+// position and reason for each, followed, the first time each rename is
+// suggested, by its line. Renames to names already taken, and names with
+// nothing proposed, are left commented out, to be settled by hand.
+func printNameSuggestions(w io.Writer, ss []rename.Suggestion) {
+	seen := make(map[[2]string]bool)
+	for _, s := range ss {
+		fmt.Fprintf(w, "# %s: %s: %s\n", s.Pos, s.Name, s.Reason)
+		switch k := [2]string{s.Name, s.New}; {
+		case s.New == "":
+			fmt.Fprintf(w, "# %s ?\n", s.Name)
+		case s.Taken:
+			fmt.Fprintf(w, "# %s %s (%s is already declared)\n", s.Name, s.New, s.New)
+		case !seen[k]:
+			seen[k] = true
+			fmt.Fprintf(w, "%s %s\n", s.Name, s.New)
+		}
+	}
+}
+
+// hungarianPrefixes returns the prefixes that -hungarian-prefixes lists.
+func hungarianPrefixes() []string {
+	var ps []string
+	for _, p := range strings.Split(*hungarian, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			ps = append(ps, p)
+		}
+	}
+	return ps
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// A suggestion is a GitHub pull request review comment that suggests
+// replacing lines StartLine through Line of Path with the body's
+// suggestion block. StartLine is omitted for a single line.
+type suggestion struct {
+	Path      string `json:"path"`
+	StartLine int    `json:"start_line,omitempty"`
+	Line      int    `json:"line"`
+	Side      string `json:"side"`
+	Body      string `json:"body"`
+	CommitID  string `json:"commit_id,omitempty"`
+}
+
+// suggestions are collected for -report=gh-suggestions.
+var suggestions = struct {
+	sync.Mutex
+	list []suggestion
+}{}
+
+var (
+	gitTopOnce sync.Once
+	gitTop     string // the top of the git work tree, if any
+)
+
+// suggest records suggestions that turn old, the source of the file at
+// path, into new, one per hunk.
+func suggest(path string, old, new []byte) {
+	path = topRelative(path)
+	var ss []suggestion
+	for _, h := range lineHunks(old, new) {
+		s := suggestion{Path: path, Line: h.end, Side: "RIGHT"}
+		if h.end-h.start > 1 {
+			s.StartLine = h.start + 1
+		}
+		body := strings.Join(h.new, "")
+		if body != "" && !strings.HasSuffix(body, "\n") {
+			body += "\n"
+		}
+		s.Body = "```suggestion\n" + body + "```"
+		ss = append(ss, s)
+	}
+	suggestions.Lock()
+	suggestions.list = append(suggestions.list, ss...)
+	suggestions.Unlock()
+}
+
+// topRelative returns path relative to the top of the git work tree, with
+// slashes, or just with slashes outside one.
+func topRelative(path string) string {
+	gitTopOnce.Do(func() {
+		if out, err := git("rev-parse", "--show-toplevel"); err == nil {
+			gitTop = strings.TrimSpace(out)
+		}
+	})
+	if rel, ok := repoPath(gitTop, path); ok && gitTop != "" {
+		path = rel
+	}
+	return filepath.ToSlash(path)
+}
+
+// printSuggestions prints the suggestions as a JSON array, in order, each
+// on the commit -commit-id gives, or HEAD.
+func printSuggestions() {
+	id := *commitID
+	if id == "" {
+		if out, err := git("rev-parse", "HEAD"); err == nil {
+			id = strings.TrimSpace(out)
+		}
+	}
+	list := suggestions.list
+	for i := range list {
+		list[i].CommitID = id
+	}
+	sort.Slice(list, func(i, j int) bool {
+		a, b := list[i], list[j]
+		return a.Path < b.Path || a.Path == b.Path && a.Line < b.Line
+	})
+	if list == nil {
+		list = []suggestion{}
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "\t")
+	enc.SetEscapeHTML(false)
+	enc.Encode(list)
+}
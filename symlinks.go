@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// claimed holds the real paths of the files being renamed, so that a file
+// reached twice, through a symlink, is read and written only once.
+var claimed = struct {
+	sync.Mutex
+	m map[string]string // real path -> path as first reached
+}{
+	m: make(map[string]string),
+}
+
+// claim reports whether the file at path should be renamed: it is the
+// first path to reach its file, and with -stay-in-root, the file is inside
+// the root. It records any file that is outside.
+func claim(path string) bool {
+	real, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		// Reading it will fail and say why.
+		return true
+	}
+	if real, err = filepath.Abs(real); err != nil {
+		return true
+	}
+	if *stayInRoot {
+		root, err := moduleRoot()
+		if err != nil {
+			record(path, statusReadErr, err)
+			return false
+		}
+		if rel, err := filepath.Rel(root, real); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			record(path, statusOutsideRoot, fmt.Errorf("links to %s, outside %s", real, root))
+			return false
+		}
+	}
+	claimed.Lock()
+	defer claimed.Unlock()
+	if first, ok := claimed.m[real]; ok {
+		logger.Debug("duplicate", "path", path, "first", first)
+		return false
+	}
+	claimed.m[real] = path
+	return true
+}
+
+// claimedDirs holds the real paths of the package directories loaded, by
+// the import path that first reached each, so that patterns naming the same
+// package, such as ./... and ./sub or a relative and an import path, load
+// it only once.
+var claimedDirs = struct {
+	sync.Mutex
+	m map[string]string
+}{
+	m: make(map[string]string),
+}
+
+// claimDir reports whether the package in dir, reached by pkgPath, should
+// be loaded: no other import path has reached it before.
+func claimDir(dir, pkgPath string) bool {
+	real, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return true
+	}
+	if real, err = filepath.Abs(real); err != nil {
+		return true
+	}
+	claimedDirs.Lock()
+	defer claimedDirs.Unlock()
+	if first, ok := claimedDirs.m[real]; ok && first != pkgPath {
+		logger.Debug("duplicate package", "path", pkgPath, "first", first)
+		return false
+	}
+	claimedDirs.m[real] = pkgPath
+	return true
+}
+
+var (
+	rootOnce sync.Once
+	root     string
+	rootErr  error
+)
+
+// moduleRoot returns the real path of the nearest directory at or above
+// the current one that has a go.mod file, or of the current directory if
+// there is none.
+func moduleRoot() (string, error) {
+	rootOnce.Do(func() {
+		var wd string
+		if wd, rootErr = os.Getwd(); rootErr != nil {
+			return
+		}
+		if wd, rootErr = filepath.EvalSymlinks(wd); rootErr != nil {
+			return
+		}
+		root = wd
+		for dir := wd; ; dir = filepath.Dir(dir) {
+			if _, err := os.Stat(filepath.Join(dir, "go.mod")); err == nil {
+				root = dir
+				return
+			}
+			if filepath.Dir(dir) == dir {
+				return
+			}
+		}
+	})
+	return root, rootErr
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/jeremyschlatter/gorename-global/rename"
+)
+
+// undo holds what the files that a run writes besides the renamed ones,
+// such as the -compat forwarders, the files -regenerate writes, and the
+// -changelog-entry, were like before, so that restore can put them back:
+// the contents of those that existed, by path, in the order first written.
+var undo = struct {
+	sync.Mutex
+	m     map[string]undoFile
+	order []string
+}{
+	m: make(map[string]undoFile),
+}
+
+// An undoFile is what a file was like before the run wrote it.
+type undoFile struct {
+	existed bool
+	src     []byte
+	perm    fs.FileMode
+}
+
+// keepForUndo notes what the file at path is like before the run writes
+// it, unless it already has. A file that can't be read is taken not to
+// exist, and is removed on undo.
+func keepForUndo(path string) {
+	var u undoFile
+	if fi, err := fs.Stat(fsys, path); err == nil && fi.Mode().IsRegular() {
+		if src, err := fs.ReadFile(fsys, path); err == nil {
+			u = undoFile{existed: true, src: src, perm: fi.Mode().Perm()}
+		}
+	}
+	keepUndoFile(path, u)
+}
+
+// keepUndoFile notes u as what the file at path was like, unless one was
+// already noted.
+func keepUndoFile(path string, u undoFile) {
+	undo.Lock()
+	defer undo.Unlock()
+	if _, ok := undo.m[path]; ok {
+		return
+	}
+	undo.m[path] = u
+	undo.order = append(undo.order, path)
+}
+
+// regenerateForUndo runs go generate as goInChangedDirs does, noting first
+// what the files in the changed directories are like, and then that those
+// new to them were created, so that restore can undo what it wrote.
+func regenerateForUndo(files []*rename.File) []error {
+	dirs := make(map[string]bool)
+	for _, f := range files {
+		if f.Changed() {
+			dirs[filepath.Dir(f.Path)] = true
+		}
+	}
+	before := make(map[string]bool)
+	for dir := range dirs {
+		entries, _ := fs.ReadDir(fsys, dir)
+		for _, e := range entries {
+			path := filepath.Join(dir, e.Name())
+			before[path] = true
+			if e.Type().IsRegular() {
+				keepForUndo(path)
+			}
+		}
+	}
+	errs := goInChangedDirs(files, "generate")
+	for dir := range dirs {
+		entries, _ := fs.ReadDir(fsys, dir)
+		for _, e := range entries {
+			if path := filepath.Join(dir, e.Name()); !before[path] {
+				keepUndoFile(path, undoFile{})
+			}
+		}
+	}
+	return errs
+}
+
+// undoWrites puts back the files noted by keepForUndo as they were, latest
+// first, and reports how many it did, with the errors.
+func undoWrites() (int, []error) {
+	undo.Lock()
+	defer undo.Unlock()
+	var errs []error
+	n := 0
+	for i := len(undo.order) - 1; i >= 0; i-- {
+		path := undo.order[i]
+		u := undo.m[path]
+		if !u.existed {
+			if _, err := os.Lstat(path); os.IsNotExist(err) {
+				continue // never written
+			}
+			if err := os.RemoveAll(path); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			record(path, statusRemoved, nil)
+			n++
+			continue
+		}
+		if err := fsys.WriteFile(path, u.src, u.perm); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		record(path, statusRestored, nil)
+		n++
+	}
+	return n, errs
+}
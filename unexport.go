@@ -0,0 +1,184 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/jeremyschlatter/gorename-global/rename"
+)
+
+// topLevelRenames returns the renames of from to to where from is declared
+// at the top level of the packages of files, as -unexport and -export make
+// them. It is an error if none of them declares it.
+func topLevelRenames(files []*rename.File, from, to string) ([]renameRule, error) {
+	seen := make(map[renameRule]bool)
+	var rs []renameRule
+	for _, f := range files {
+		kind, ok := f.Declared()[from]
+		if !ok {
+			continue
+		}
+		r := renameRule{From: from, To: to, Kind: kind, Package: importPath(filepath.Dir(f.Path))}
+		if !seen[r] {
+			seen[r] = true
+			rs = append(rs, r)
+		}
+	}
+	if len(rs) == 0 {
+		return nil, fmt.Errorf("no package named declares %s at the top level", from)
+	}
+	return rs, nil
+}
+
+// checkUnexport stops the run if any other package of the module in the
+// current directory, named on the command line or not, refers to -unexport's
+// name in a package of files that declares it, since those references
+// would no longer compile. References in the declaring packages are
+// renamed with the declaration.
+func checkUnexport(files []*rename.File) {
+	root, err := moduleRoot()
+	if err != nil {
+		exitOnErr([]error{err})
+	}
+	modPath := modulePath(root)
+	declaring := make(map[string]bool) // by import path
+	for _, f := range files {
+		if _, ok := f.Declared()[*unexportName]; !ok {
+			continue
+		}
+		dir := filepath.Dir(f.Path)
+		if real, err := filepath.EvalSymlinks(dir); err == nil {
+			dir = real
+		}
+		if p := modImportPath(root, modPath, dir); p != "" {
+			declaring[p] = true
+		}
+	}
+
+	var uses []string
+	fset := token.NewFileSet()
+	err = walkModule(root, func(path string) error {
+		f, err := parser.ParseFile(fset, path, nil, parser.SkipObjectResolution)
+		if err != nil {
+			return nil // left for the build to report
+		}
+		uses = append(uses, qualifiedUses(fset, f, declaring, *unexportName)...)
+		return nil
+	})
+	if err != nil {
+		exitOnErr([]error{err})
+	}
+	if len(uses) > 0 {
+		exitOnErr([]error{fmt.Errorf("%s is used outside the package declaring it, at %s; unexporting it would break those uses",
+			*unexportName, strings.Join(uses, ", "))})
+	}
+}
+
+// walkModule calls fn with the path, from the current directory if it can,
+// of each .go file of the module at root, leaving out vendor and testdata
+// directories, those the go command ignores, and nested modules.
+func walkModule(root string, fn func(path string) error) error {
+	wd, _ := os.Getwd()
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			name := d.Name()
+			if path != root && (name == "vendor" || name == "testdata" || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_")) {
+				return filepath.SkipDir
+			}
+			if _, err := os.Stat(filepath.Join(path, "go.mod")); path != root && err == nil {
+				return filepath.SkipDir // another module
+			}
+			return nil
+		}
+		if !strings.HasSuffix(path, ".go") {
+			return nil
+		}
+		if rel, err := filepath.Rel(wd, path); err == nil && wd != "" {
+			path = rel
+		}
+		return fn(path)
+	})
+}
+
+// modulePath returns the module path in the go.mod file at root, or "" if
+// there is none.
+func modulePath(root string) string {
+	mod, err := os.ReadFile(filepath.Join(root, "go.mod"))
+	if err != nil {
+		return ""
+	}
+	if m := goModModule.FindSubmatch(mod); m != nil {
+		return string(m[1])
+	}
+	return ""
+}
+
+// modImportPath returns the import path of the package in dir, in the
+// module at root with path modPath, or by where it is in GOPATH if there is
+// no go.mod.
+func modImportPath(root, modPath, dir string) string {
+	if modPath == "" {
+		return gopathImportPath(dir)
+	}
+	rel, ok := repoPath(root, dir)
+	switch {
+	case !ok:
+		return ""
+	case rel == ".":
+		return modPath
+	}
+	return modPath + "/" + rel
+}
+
+// gopathImportPath returns the import path of the package in dir, outside
+// any module, by the src directory of GOPATH, or GOROOT, that it is under,
+// or "" if it is under none.
+func gopathImportPath(dir string) string {
+	for _, src := range build.Default.SrcDirs() {
+		if rel, ok := repoPath(src, dir); ok && rel != "." {
+			return rel
+		}
+	}
+	return ""
+}
+
+// qualifiedUses returns the positions of the pkg.name selectors in f whose
+// pkg is an import of one of pkgs.
+func qualifiedUses(fset *token.FileSet, f *ast.File, pkgs map[string]bool, name string) []string {
+	locals := make(map[string]bool)
+	for _, imp := range f.Imports {
+		p, err := strconv.Unquote(imp.Path.Value)
+		if err != nil || !pkgs[p] {
+			continue
+		}
+		local := p[strings.LastIndex(p, "/")+1:]
+		if imp.Name != nil {
+			local = imp.Name.Name
+		}
+		locals[local] = true
+	}
+	if len(locals) == 0 {
+		return nil
+	}
+	var uses []string
+	ast.Inspect(f, func(n ast.Node) bool {
+		if sel, ok := n.(*ast.SelectorExpr); ok && sel.Sel.Name == name {
+			if x, ok := sel.X.(*ast.Ident); ok && locals[x.Name] {
+				uses = append(uses, fset.Position(sel.Pos()).String())
+			}
+		}
+		return true
+	})
+	return uses
+}
@@ -0,0 +1,71 @@
+//go:build js && wasm
+
+// Command wasm exposes the rename engine to JavaScript. Build it with
+//
+//	GOOS=js GOARCH=wasm go build -o gorename-global.wasm ./wasm
+//
+// and load it with the wasm_exec.js shim from the Go distribution. It
+// installs a global function
+//
+//	gorenameGlobal(files, options)
+//
+// where files maps file names to Go source and options has the fields from,
+// to, and auto, with the same meaning as the command-line flags. It returns
+// an object with either a files field, mapping the name of each changed file
+// to its new source, or an error field.
+package main
+
+import (
+	"syscall/js"
+
+	"github.com/jeremyschlatter/gorename-global/rename"
+)
+
+func main() {
+	js.Global().Set("gorenameGlobal", js.FuncOf(renameFiles))
+	select {}
+}
+
+func renameFiles(this js.Value, args []js.Value) interface{} {
+	if len(args) != 2 {
+		return result(nil, "usage: gorenameGlobal(files, options)")
+	}
+	files, options := args[0], args[1]
+	srcs := make(map[string][]byte)
+	keys := js.Global().Get("Object").Call("keys", files)
+	for i := 0; i < keys.Length(); i++ {
+		name := keys.Index(i).String()
+		srcs[name] = []byte(files.Get(name).String())
+	}
+	opts := rename.Options{
+		From: stringField(options, "from"),
+		To:   stringField(options, "to"),
+		Auto: options.Get("auto").Truthy(),
+	}
+	out, err := rename.Source(srcs, opts)
+	if err != nil {
+		return result(nil, err.Error())
+	}
+	return result(out, "")
+}
+
+func stringField(v js.Value, name string) string {
+	if f := v.Get(name); f.Type() == js.TypeString {
+		return f.String()
+	}
+	return ""
+}
+
+func result(files map[string][]byte, err string) js.Value {
+	r := js.Global().Get("Object").New()
+	if err != "" {
+		r.Set("error", err)
+		return r
+	}
+	obj := js.Global().Get("Object").New()
+	for name, src := range files {
+		obj.Set(name, string(src))
+	}
+	r.Set("files", obj)
+	return r
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unicode/utf16"
+)
+
+// A workspaceEdit is an LSP WorkspaceEdit: the text edits to make, by
+// document URI.
+type workspaceEdit struct {
+	Changes map[string][]textEdit `json:"changes"`
+}
+
+type textEdit struct {
+	Range   lspRange `json:"range"`
+	NewText string   `json:"newText"`
+}
+
+type lspRange struct {
+	Start lspPosition `json:"start"`
+	End   lspPosition `json:"end"`
+}
+
+// An lspPosition counts lines from 0, and characters in UTF-16 code units,
+// as LSP does by default.
+type lspPosition struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+// textEdits are collected for -report=workspace-edit.
+var textEdits = struct {
+	sync.Mutex
+	m map[string][]textEdit
+}{
+	m: make(map[string][]textEdit),
+}
+
+// editText records the edits that turn old, the source of the file at
+// path, into new, one per hunk of whole lines.
+func editText(path string, old, new []byte) error {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	ol := strings.SplitAfter(string(old), "\n")
+	var es []textEdit
+	for _, h := range lineHunks(old, new) {
+		end := lspPosition{Line: h.end}
+		if h.end == len(ol) {
+			// The last line has no line ending to end the range after.
+			end = lspPosition{Line: h.end - 1, Character: len(utf16.Encode([]rune(ol[h.end-1])))}
+		}
+		es = append(es, textEdit{lspRange{lspPosition{Line: h.start}, end}, strings.Join(h.new, "")})
+	}
+	textEdits.Lock()
+	textEdits.m[fileURI(abs)] = es
+	textEdits.Unlock()
+	return nil
+}
+
+// fileURI returns the file URI of the absolute path abs.
+func fileURI(abs string) string {
+	p := filepath.ToSlash(abs)
+	if !strings.HasPrefix(p, "/") {
+		p = "/" + p // a Windows path such as C:/src
+	}
+	u := strings.NewReplacer("%", "%25", " ", "%20", "#", "%23", "?", "%3F").Replace(p)
+	return "file://" + u
+}
+
+// printWorkspaceEdit prints the edits as a WorkspaceEdit.
+func printWorkspaceEdit() {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "\t")
+	enc.SetEscapeHTML(false)
+	enc.Encode(workspaceEdit{textEdits.m})
+}